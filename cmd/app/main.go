@@ -9,10 +9,15 @@ import (
 	"log/slog"
 	"messenger/internal/app"
 	"messenger/internal/app/wsserver"
+	"messenger/internal/auth"
 	"messenger/internal/config"
 	"messenger/internal/handler"
+	"messenger/internal/services/access"
 	"messenger/internal/services/chat"
+	"messenger/internal/services/file"
 	"messenger/internal/services/message"
+	"messenger/internal/services/presence"
+	"messenger/internal/storages/blob"
 	"messenger/internal/storages/postgres"
 	redisrepo "messenger/internal/storages/redis"
 	"os"
@@ -34,13 +39,16 @@ func main() {
 	configPath := config.FetchConfigPath()
 	cfg := config.MustConfig[config.Config](configPath)
 
-	pgClient, redisClient, log, server := setupDependencies(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pgClient, redisClient, log, server := setupDependencies(ctx, cfg)
 	defer pgClient.Close()
 	defer redisClient.Close()
 
 	application := app.New(log, server)
 	if err := application.Start(); err != nil {
-		log.Error("error starting application", err)
+		log.Error("error starting application", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
@@ -49,18 +57,19 @@ func main() {
 
 	sign := <-quit
 	log.Info("stopping application", slog.String("signal", sign.String()))
+	cancel()
 	if err := application.Stop(context.Background()); err != nil {
-		log.Error("error stopping application", err)
+		log.Error("error stopping application", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 }
 
-func setupDependencies(cfg config.Config) (*sqlx.DB, *redis.Client, *slog.Logger, wsserver.WSServer) {
+func setupDependencies(ctx context.Context, cfg config.Config) (*sqlx.DB, *redis.Client, *slog.Logger, wsserver.WSServer) {
 	log := setupLogger(cfg.Env)
 
 	pgClient, err := setupPostgres("./config/postgres.yaml")
 	if err != nil {
-		log.Error("failed to setup", err)
+		log.Error("failed to setup", slog.String("error", err.Error()))
 	}
 	redisClient := setupRedis("./config/redis.yaml")
 
@@ -68,27 +77,83 @@ func setupDependencies(cfg config.Config) (*sqlx.DB, *redis.Client, *slog.Logger
 	messageCacheRepo := redisrepo.NewMessageRepository(redisClient)
 	chatRepo := postgres.NewChatRepository(pgClient)
 	chatCacheRepo := redisrepo.NewChatRepository(redisClient)
+	fileRepo := postgres.NewFileRepository(pgClient)
+
+	broker := redisrepo.NewBroker(log, redisClient)
+
+	authenticator, err := setupAuthenticator("./config/auth.yaml")
+	if err != nil {
+		log.Error("failed to setup", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	presenceRepo := redisrepo.NewPresenceRepository(redisClient)
+
+	blobStore, err := setupBlobStore("./config/blob.yaml")
+	if err != nil {
+		log.Error("failed to setup", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 
-	server := setupServer(log, messageRepo, messageCacheRepo,
-		chatRepo, chatCacheRepo, "./config/wsserver.yaml")
+	uploadCfg := config.MustConfig[file.UploadConfig]("./config/uploads.yaml")
+
+	server := setupServer(ctx, log, messageRepo, messageCacheRepo,
+		chatRepo, chatCacheRepo, chatRepo, presenceRepo, chatRepo, fileRepo, fileRepo, blobStore, uploadCfg, broker,
+		authenticator, "./config/wsserver.yaml")
 
 	return pgClient, redisClient, log, server
 }
 
-func setupServer(log *slog.Logger,
+func setupServer(ctx context.Context, log *slog.Logger,
 	messageRepository message.Repository, messageCacheRepository message.CacheRepository,
-	chatRepository chat.Repository, chatCacheRepository chat.CacheRepository,
-	configPath string) wsserver.WSServer {
+	chatRepository chat.Repository, chatCacheRepository chat.CacheRepository, accessRepository access.Repository,
+	presenceRepository presence.Repository, contactRepository presence.ContactRepository,
+	fileRepository file.Repository, attachmentRepository message.FileRepository, blobStore file.BlobStore,
+	uploadCfg file.UploadConfig, broker handler.Broker, authenticator auth.Authenticator, configPath string) wsserver.WSServer {
 	serverConfig := config.MustConfig[wsserver.Config](configPath)
 
-	messageService := message.NewMessageService(log, messageCacheRepository, messageRepository)
-	chatService := chat.NewChatService(log, chatRepository, chatCacheRepository)
-	messengerHandler := handler.NewHandler(log, messageService, chatService)
+	accessService := access.NewAccessService(log, accessRepository)
+	messageService := message.NewMessageService(log, messageCacheRepository, messageRepository, attachmentRepository,
+		accessService)
+	chatService := chat.NewChatService(log, chatRepository, chatCacheRepository, accessService,
+		messageService)
+	presenceService := presence.NewPresenceService(log, presenceRepository, contactRepository,
+		serverConfig.Client.PresenceTTL)
+	fileService := file.NewFileService(log, fileRepository, blobStore, uploadCfg)
+	go fileService.RunCollector(ctx)
+	go fileService.MoveLegacyFiles()
+	messengerHandler := handler.NewHandler(log, messageService, chatService, presenceService, fileService, broker,
+		authenticator, serverConfig.Client)
+
+	messengerHandler.InitRoutes()
 
 	server := wsserver.New(log, messengerHandler, serverConfig)
 	return server
 }
 
+func setupAuthenticator(configPath string) (auth.Authenticator, error) {
+	authCfg := config.MustConfig[auth.Config](configPath)
+	authCfg.JWT.Secret = os.Getenv("JWT_SECRET")
+
+	authenticator, err := auth.New(authCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up authenticator: %w", err)
+	}
+	return authenticator, nil
+}
+
+func setupBlobStore(configPath string) (blob.Store, error) {
+	blobCfg := config.MustConfig[blob.Config](configPath)
+	blobCfg.S3.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	blobCfg.S3.SecretKey = os.Getenv("S3_SECRET_KEY")
+
+	store, err := blob.New(blobCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up blob store: %w", err)
+	}
+	return store, nil
+}
+
 func setupRedis(configPath string) *redis.Client {
 	redisCfg := config.MustConfig[redisrepo.Config](configPath)
 	redisCfg.Password = os.Getenv("REDIS_PASSWORD")
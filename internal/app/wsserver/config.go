@@ -2,8 +2,38 @@ package wsserver
 
 import "time"
 
+const (
+	OverflowDropOldest = "drop_oldest"
+	OverflowDisconnect = "disconnect"
+)
+
 type Config struct {
 	Addr    string        `yaml:"addr"`
 	Port    int           `yaml:"port" env-required:"true"`
 	Timeout time.Duration `yaml:"timeout" env-required:"true"`
+	Client  ClientConfig  `yaml:"client"`
+}
+
+// ClientConfig controls every WebSocket connection.
+type ClientConfig struct {
+	// WriteWait and PongWait bound how long a write and a pong may take;
+	// the server pings every PingPeriod.
+	WriteWait      time.Duration `yaml:"write_wait" env-default:"10s"`
+	PongWait       time.Duration `yaml:"pong_wait" env-default:"60s"`
+	PingPeriod     time.Duration `yaml:"ping_period" env-default:"50s"`
+	MaxMessageSize int64         `yaml:"max_message_size" env-default:"65536"`
+	// SendQueueSize outbound frames are buffered before OverflowPolicy applies.
+	SendQueueSize  int    `yaml:"send_queue_size" env-default:"256"`
+	OverflowPolicy string `yaml:"overflow_policy" env-default:"drop_oldest"`
+	// ReplayLimit caps how many missed messages a reconnecting client gets.
+	ReplayLimit int `yaml:"replay_limit" env-default:"1000"`
+	// TypingTimeout is how long a typing indicator lasts without a refresh.
+	TypingTimeout time.Duration `yaml:"typing_timeout" env-default:"5s"`
+	// TypingRate is how many typing events per second a connection may send,
+	// with bursts of up to TypingBurst.
+	TypingRate  float64 `yaml:"typing_rate" env-default:"1"`
+	TypingBurst int     `yaml:"typing_burst" env-default:"5"`
+	// PresenceTTL is how long a session stays online without a heartbeat;
+	// heartbeats are sent three times per PresenceTTL.
+	PresenceTTL time.Duration `yaml:"presence_ttl" env-default:"90s"`
 }
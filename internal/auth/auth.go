@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+type Authenticator interface {
+	Authenticate(token string) (uuid.UUID, error)
+}
+
+type userIdKey struct{}
+
+func WithUserId(ctx context.Context, userId uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIdKey{}, userId)
+}
+
+func UserId(ctx context.Context) (uuid.UUID, bool) {
+	userId, ok := ctx.Value(userIdKey{}).(uuid.UUID)
+	return userId, ok
+}
+
+func New(cfg Config) (Authenticator, error) {
+	const op = "auth.New"
+
+	switch cfg.Mode {
+	case ModeJWT:
+		authenticator, err := NewJWT(cfg.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return authenticator, nil
+	case ModeStatic:
+		tokens := make(map[string]uuid.UUID, len(cfg.Tokens))
+		for token, id := range cfg.Tokens {
+			userId, err := uuid.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("%s: user id for static token: %w", op, err)
+			}
+			tokens[token] = userId
+		}
+		return NewStatic(tokens), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown mode %q", op, cfg.Mode)
+	}
+}
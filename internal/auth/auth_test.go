@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJWT_HMAC(t *testing.T) {
+	userId := uuid.New()
+	secret := []byte("secret")
+
+	authenticator, err := NewJWT(JWTConfig{
+		Algorithm: "HS256",
+		Secret:    string(secret),
+		Issuer:    "ural",
+	})
+	require.NoError(t, err)
+
+	sign := func(claims jwt.RegisteredClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+
+	cases := []struct {
+		name          string
+		token         string
+		expectedId    uuid.UUID
+		expectedError error
+	}{
+		{
+			name: "Валидный токен",
+			token: sign(jwt.RegisteredClaims{
+				Subject:   userId.String(),
+				Issuer:    "ural",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			}, secret),
+			expectedId: userId,
+		},
+		{
+			name: "Истёкший токен",
+			token: sign(jwt.RegisteredClaims{
+				Subject:   userId.String(),
+				Issuer:    "ural",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			}, secret),
+			expectedError: ErrInvalidToken,
+		},
+		{
+			name: "Чужой издатель",
+			token: sign(jwt.RegisteredClaims{
+				Subject:   userId.String(),
+				Issuer:    "other",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			}, secret),
+			expectedError: ErrInvalidToken,
+		},
+		{
+			name: "Неверная подпись",
+			token: sign(jwt.RegisteredClaims{
+				Subject:   userId.String(),
+				Issuer:    "ural",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			}, []byte("other")),
+			expectedError: ErrInvalidToken,
+		},
+		{
+			name:          "Пустой токен",
+			token:         "",
+			expectedError: ErrMissingToken,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := authenticator.Authenticate(tt.token)
+			require.ErrorIs(t, err, tt.expectedError)
+			require.Equal(t, tt.expectedId, id)
+		})
+	}
+}
+
+func TestJWT_RSA(t *testing.T) {
+	userId := uuid.New()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "public.pem")
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	require.NoError(t, err)
+
+	authenticator, err := NewJWT(JWTConfig{
+		Algorithm:     "RS256",
+		PublicKeyPath: keyPath,
+	})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Subject:   userId.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(key)
+	require.NoError(t, err)
+
+	id, err := authenticator.Authenticate(token)
+	require.NoError(t, err)
+	require.Equal(t, userId, id)
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   userId.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(der)
+	require.NoError(t, err)
+
+	_, err = authenticator.Authenticate(hmacToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNew_Static(t *testing.T) {
+	userId := uuid.New()
+
+	authenticator, err := New(Config{
+		Mode: ModeStatic,
+		Tokens: map[string]string{
+			"test-token": userId.String(),
+		},
+	})
+	require.NoError(t, err)
+
+	id, err := authenticator.Authenticate("test-token")
+	require.NoError(t, err)
+	require.Equal(t, userId, id)
+
+	_, err = authenticator.Authenticate("other-token")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,22 @@
+package auth
+
+const (
+	ModeJWT    = "jwt"
+	ModeStatic = "static"
+)
+
+type Config struct {
+	Mode   string            `yaml:"mode" env-default:"jwt"`
+	JWT    JWTConfig         `yaml:"jwt"`
+	Tokens map[string]string `yaml:"tokens"`
+}
+
+// JWTConfig describes how access tokens are verified. HMAC algorithms use
+// Secret, RSA algorithms use the PEM encoded public key at PublicKeyPath.
+type JWTConfig struct {
+	Algorithm     string `yaml:"algorithm" env-default:"HS256"`
+	Secret        string `yaml:"-"`
+	PublicKeyPath string `yaml:"public_key_path"`
+	Issuer        string `yaml:"issuer"`
+	Audience      string `yaml:"audience"`
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"os"
+	"strings"
+)
+
+// JWT authenticates tokens signed with HMAC or RSA. The user id is taken
+// from the subject claim.
+type JWT struct {
+	key    any
+	parser *jwt.Parser
+}
+
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	const op = "auth.NewJWT"
+
+	var key any
+	switch {
+	case strings.HasPrefix(cfg.Algorithm, "HS"):
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("%s: secret is required for %s", op, cfg.Algorithm)
+		}
+		key = []byte(cfg.Secret)
+	case strings.HasPrefix(cfg.Algorithm, "RS"):
+		pem, err := os.ReadFile(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		key, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported algorithm %q", op, cfg.Algorithm)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWT{
+		key:    key,
+		parser: jwt.NewParser(options...),
+	}, nil
+}
+
+func (j *JWT) Authenticate(token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.Nil, ErrMissingToken
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := j.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return j.key, nil
+	})
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errors.Join(ErrInvalidToken, err)
+	}
+	return userId, nil
+}
@@ -0,0 +1,27 @@
+package auth
+
+import "github.com/google/uuid"
+
+// Static authenticates a fixed set of tokens. It is meant for tests and
+// local development only.
+type Static struct {
+	tokens map[string]uuid.UUID
+}
+
+func NewStatic(tokens map[string]uuid.UUID) *Static {
+	return &Static{
+		tokens: tokens,
+	}
+}
+
+func (s *Static) Authenticate(token string) (uuid.UUID, error) {
+	if token == "" {
+		return uuid.Nil, ErrMissingToken
+	}
+
+	userId, ok := s.tokens[token]
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userId, nil
+}
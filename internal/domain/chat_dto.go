@@ -15,6 +15,15 @@ type UpdateChat struct {
 }
 
 type GetChat struct {
+	Id          uuid.UUID      `json:"id" db:"id"`
 	Name        string         `json:"name" db:"name"`
 	LastMessage models.Message `json:"lastMessage"`
+	UnreadCount uint           `json:"unreadCount" db:"unread_count"`
+	LatestPin   *models.Pin    `json:"latestPin,omitempty"`
+}
+
+type ChangeRole struct {
+	ChatId   uuid.UUID   `json:"chatId"`
+	PersonId uuid.UUID   `json:"personId"`
+	Role     models.Role `json:"role"`
 }
@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"messenger/internal/domain/models"
+)
+
+// ProtocolVersion is the version of the WebSocket envelope protocol
+// spoken by the server. Envelopes without a version are treated as current.
+const ProtocolVersion = 1
+
+// Client to server event types.
+const (
+	EventMessageSend    = "message.send"
+	EventMessageEdit    = "message.edit"
+	EventMessageDelete  = "message.delete"
+	EventTypingStart    = "typing.start"
+	EventTypingStop     = "typing.stop"
+	EventRead           = "read"
+	EventDelivered      = "delivered"
+	EventReactionAdd    = "reaction.add"
+	EventReactionRemove = "reaction.remove"
+	EventSubscribe      = "subscribe"
+	EventUnsubscribe    = "unsubscribe"
+	EventPing           = "ping"
+)
+
+// Server to client event types.
+const (
+	EventAck              = "ack"
+	EventError            = "error"
+	EventPong             = "pong"
+	EventMessageNew       = "message.new"
+	EventMessageEdited    = "message.edited"
+	EventMessageDeleted   = "message.deleted"
+	EventMessageStatus    = "message.status"
+	EventSyncDone         = "sync.done"
+	EventPresence         = "presence"
+	EventThreadMessage    = "thread.message"
+	EventThreadUpdated    = "thread.updated"
+	EventMessageReactions = "message.reactions"
+	EventMessagePinned    = "message.pinned"
+	EventMessageUnpinned  = "message.unpinned"
+)
+
+// Aggregated delivery statuses of a message: delivered and read mean every
+// recipient has received or read it.
+const (
+	DeliverySent      = "sent"
+	DeliveryDelivered = "delivered"
+	DeliveryRead      = "read"
+)
+
+// Error codes sent in error frames.
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeInternal           = "internal"
+)
+
+type Envelope struct {
+	Version int             `json:"v,omitempty"`
+	Type    string          `json:"type"`
+	Id      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Membership changes carried by events between instances.
+const (
+	MembershipJoin   = "join"
+	MembershipLeave  = "leave"
+	MembershipRemove = "remove"
+)
+
+// Event is a server initiated envelope addressed to the members of a chat,
+// or only to the sessions of UserId when it is set. Sessions of SkipUserId
+// do not receive it, which keeps a user's own typing from echoing back.
+//
+// An event with Membership set carries no envelope: every instance applies
+// the change to the chat subscriptions of its sessions instead, so that
+// members connected elsewhere join and leave chats as well.
+type Event struct {
+	ChatId     uuid.UUID `json:"chatId"`
+	UserId     uuid.UUID `json:"userId"`
+	SkipUserId uuid.UUID `json:"skipUserId"`
+	Membership string    `json:"membership,omitempty"`
+	Envelope   Envelope  `json:"envelope"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewMessagePayload is a message pushed to clients together with its history
+// cursor, which a client presents as since when it reconnects.
+type NewMessagePayload struct {
+	models.Message
+	Cursor string `json:"cursor"`
+}
+
+// SyncPayload ends the replay of missed messages. Cursor points at the last
+// replayed message; Truncated is set when ReplayLimit stopped the replay and
+// the rest has to be fetched from the history endpoint.
+type SyncPayload struct {
+	Cursor    string `json:"cursor"`
+	Count     int    `json:"count"`
+	Truncated bool   `json:"truncated"`
+}
+
+type MessageEditPayload struct {
+	MessageId uuid.UUID `json:"messageId"`
+	Message   string    `json:"message"`
+}
+
+// Delete scopes. A message deleted for me is hidden only from the caller's
+// history; deleted for everyone it becomes a tombstone for all members.
+const (
+	DeleteForMe       = "me"
+	DeleteForEveryone = "everyone"
+)
+
+type MessageDeletePayload struct {
+	MessageId uuid.UUID `json:"messageId"`
+	Scope     string    `json:"scope,omitempty"`
+}
+
+type MessageDeletedPayload struct {
+	MessageId uuid.UUID `json:"messageId"`
+	ChatId    uuid.UUID `json:"chatId"`
+	Scope     string    `json:"scope"`
+}
+
+// TypingPayload is sent with typing.start and typing.stop. Typing events are
+// ephemeral: they are only fanned out to the other members and never stored.
+type TypingPayload struct {
+	ChatId   uuid.UUID `json:"chatId"`
+	PersonId uuid.UUID `json:"personId"`
+}
+
+type ReadPayload struct {
+	ChatId    uuid.UUID `json:"chatId"`
+	MessageId uuid.UUID `json:"messageId"`
+	PersonId  uuid.UUID `json:"personId"`
+}
+
+// DeliveredPayload acknowledges that messages reached the client.
+type DeliveredPayload struct {
+	MessageIds []uuid.UUID `json:"messageIds"`
+}
+
+// MessageStatus is the aggregated delivery state of a message pushed to its sender.
+type MessageStatus struct {
+	MessageId  uuid.UUID `json:"messageId" db:"message_id"`
+	ChatId     uuid.UUID `json:"chatId" db:"chat_id"`
+	SenderId   uuid.UUID `json:"senderId" db:"sender_id"`
+	Recipients uint      `json:"recipients" db:"recipients"`
+	Delivered  uint      `json:"delivered" db:"delivered"`
+	Read       uint      `json:"read" db:"read"`
+	Status     string    `json:"status" db:"-"`
+}
+
+// ReactionPayload adds or removes the caller's reaction to a message.
+type ReactionPayload struct {
+	MessageId uuid.UUID `json:"messageId"`
+	Emoji     string    `json:"emoji"`
+}
+
+// ReactionsPayload tells chat members that PersonId added or removed a
+// reaction; Reactions are the counts of the message after the change.
+type ReactionsPayload struct {
+	MessageId uuid.UUID        `json:"messageId"`
+	ChatId    uuid.UUID        `json:"chatId"`
+	PersonId  uuid.UUID        `json:"personId"`
+	Emoji     string           `json:"emoji"`
+	Added     bool             `json:"added"`
+	Reactions models.Reactions `json:"reactions"`
+}
+
+// PinPayload tells chat members that PersonId pinned or unpinned a message.
+type PinPayload struct {
+	ChatId    uuid.UUID `json:"chatId"`
+	MessageId uuid.UUID `json:"messageId"`
+	PersonId  uuid.UUID `json:"personId"`
+	Pinned    bool      `json:"pinned"`
+}
+
+type SubscribePayload struct {
+	ChatId uuid.UUID `json:"chatId"`
+}
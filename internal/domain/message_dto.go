@@ -1,11 +1,27 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"messenger/internal/domain/models"
+	"messenger/pkg/cursor"
+	"time"
+)
 
 type MessageAdd struct {
-	PersonId uuid.UUID `json:"personId"`
-	ChatId   uuid.UUID `json:"chatId"`
-	Message  string    `json:"message"`
+	PersonId uuid.UUID  `json:"personId"`
+	ChatId   uuid.UUID  `json:"chatId"`
+	Message  string     `json:"message"`
+	ReplyTo  *uuid.UUID `json:"replyTo,omitempty"`
+	// ThreadRootId posts the message in the thread of that root message.
+	ThreadRootId *uuid.UUID `json:"threadRootId,omitempty"`
+	// Files are the ids of uploaded files attached to the message.
+	Files []uuid.UUID `json:"files,omitempty"`
+}
+
+// MessageForward copies the messages into each of the chats.
+type MessageForward struct {
+	MessageIds []uuid.UUID `json:"messageIds"`
+	ChatIds    []uuid.UUID `json:"chatIds"`
 }
 
 type MessageUpdate struct {
@@ -13,3 +29,52 @@ type MessageUpdate struct {
 	Message string    `json:"message"`
 	Status  string    `json:"status"`
 }
+
+// HistoryQuery selects a page of chat history. Without a cursor the newest
+// messages are returned; with one, messages before or after it.
+type HistoryQuery struct {
+	ChatId uuid.UUID
+	Cursor *cursor.Cursor
+	After  bool
+	Limit  uint
+}
+
+// MessagePage holds messages in chronological order. Before is set while
+// older messages remain; After points past the newest message on the page.
+type MessagePage struct {
+	Messages []models.Message `json:"messages"`
+	Before   string           `json:"before,omitempty"`
+	After    string           `json:"after,omitempty"`
+}
+
+// ReadResult is the outcome of moving a read pointer: the receipt for the
+// chat and the new status of every message that became read.
+type ReadResult struct {
+	Receipt  ReadPayload
+	Advanced bool
+	Statuses []MessageStatus
+}
+
+// ThreadSummary is the state of a thread shown on its root message.
+// LastReplyAt is empty once every reply has been deleted.
+type ThreadSummary struct {
+	RootId      uuid.UUID  `json:"rootId" db:"id"`
+	ChatId      uuid.UUID  `json:"chatId" db:"chat_id"`
+	ReplyCount  uint       `json:"replyCount" db:"reply_count"`
+	LastReplyAt *time.Time `json:"lastReplyAt" db:"last_reply_at"`
+}
+
+// ThreadReply is a message posted in a thread together with the updated
+// thread and the members who follow it.
+type ThreadReply struct {
+	Message      models.Message
+	Thread       ThreadSummary
+	Participants []uuid.UUID
+}
+
+// DeletedMessage is the tombstone of a message deleted for everyone together
+// with the updated thread when the message was a thread reply.
+type DeletedMessage struct {
+	Message models.Message
+	Thread  *ThreadSummary
+}
@@ -1,8 +1,25 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
 
 type Chat struct {
 	Id   uuid.UUID `json:"id" db:"id"`
 	Name string    `json:"name" db:"name"`
 }
+
+type ChatMember struct {
+	PersonId uuid.UUID `json:"personId" db:"person_id"`
+	Role     Role      `json:"role" db:"role"`
+	JoinedAt time.Time `json:"joinedAt" db:"joined_at"`
+}
@@ -1,9 +1,82 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
+// File is an uploaded attachment. Messages refer to files through links, so
+// a forwarded message shares the files of the original. The content is kept
+// in a blob store under StorageKey and streamed on download; Checksum is
+// its hex encoded SHA-256. Images carry their dimensions as displayed, a
+// dominant color to fill their place while loading and thumbnails.
 type File struct {
+	Id         uuid.UUID `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	MimeType   string    `json:"mime_type" db:"mime_type"`
+	Size       int64     `json:"size" db:"size"`
+	Checksum   string    `json:"checksum" db:"checksum"`
+	StorageKey string    `json:"-" db:"storage_key"`
+	UploadedBy uuid.UUID `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	Width         int        `json:"width,omitempty" db:"width"`
+	Height        int        `json:"height,omitempty" db:"height"`
+	DominantColor string     `json:"dominant_color,omitempty" db:"dominant_color"`
+	Thumbnails    Thumbnails `json:"thumbnails,omitempty" db:"thumbnails"`
+}
+
+// Thumbnail is a JPEG copy of an image file scaled down to fit in a square
+// of Size pixels.
+type Thumbnail struct {
+	Size   int `json:"size"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Thumbnails are the thumbnails of a file, smallest first. They are read from
+// the database as a JSON array.
+type Thumbnails []Thumbnail
+
+func (t *Thumbnails) Scan(src any) error {
+	return scanJSON(src, t, "thumbnails")
+}
+
+// Preview lets a client lay out an image attached to a message and show a
+// thumbnail of it without downloading the file.
+type Preview struct {
+	FileId        uuid.UUID   `json:"file_id"`
+	Width         int         `json:"width"`
+	Height        int         `json:"height"`
+	DominantColor string      `json:"dominant_color"`
+	Thumbnails    []Thumbnail `json:"thumbnails"`
+}
+
+// Previews are the previews of the image attachments of a message in their
+// order. They are read from the database as a JSON array.
+type Previews []Preview
+
+func (p *Previews) Scan(src any) error {
+	return scanJSON(src, p, "previews")
+}
+
+// Upload is a resumable upload of a file of Length bytes, of which the first
+// Offset have been received. An upload nobody continues is dropped after
+// ExpiresAt.
+type Upload struct {
 	Id        uuid.UUID `json:"id" db:"id"`
-	File      byte      `json:"file" db:"file"`
-	MessageId uuid.UUID `json:"message_id" db:"message_id"`
+	PersonId  uuid.UUID `json:"person_id" db:"person_id"`
+	Name      string    `json:"name" db:"name"`
+	Length    int64     `json:"length" db:"upload_length"`
+	Offset    int64     `json:"offset" db:"upload_offset"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// UploadChunk is a received part of an upload, kept as a blob of its own.
+type UploadChunk struct {
+	UploadId   uuid.UUID `db:"upload_id"`
+	Start      int64     `db:"start_offset"`
+	Size       int64     `db:"size"`
+	StorageKey string    `db:"storage_key"`
 }
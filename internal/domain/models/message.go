@@ -1,15 +1,118 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
 
+const (
+	MessageStatusNotRead = "not read"
+	MessageStatusRead    = "read it"
+	MessageStatusDeleted = "deleted"
+)
+
 type Message struct {
-	Id          uuid.UUID `json:"id"`
+	Id          uuid.UUID  `json:"id"`
+	PersonId    uuid.UUID  `json:"person_id" db:"person_id"`
+	Chat        Chat       `json:"chat" db:"chat"`
+	MessageText string     `json:"message" db:"message"`
+	SendingTime time.Time  `json:"time" db:"time"`
+	Status      string     `json:"status" db:"status"`
+	EditedAt    *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ReplyTo     *uuid.UUID `json:"reply_to,omitempty" db:"reply_to"`
+	Quote       *Quote     `json:"quote,omitempty" db:"-"`
+
+	// ThreadRootId is set on messages posted in a thread, which are kept out
+	// of the main timeline. A root carries the number of thread messages and
+	// the time of the latest one.
+	ThreadRootId *uuid.UUID `json:"thread_root_id,omitempty" db:"thread_root_id"`
+	ReplyCount   uint       `json:"reply_count,omitempty" db:"reply_count"`
+	LastReplyAt  *time.Time `json:"last_reply_at,omitempty" db:"last_reply_at"`
+
+	Reactions Reactions `json:"reactions,omitempty" db:"reactions"`
+
+	// A forwarded message keeps the sender, chat and sending time of the
+	// message it was first forwarded from.
+	ForwardedFrom     *uuid.UUID `json:"forwarded_from,omitempty" db:"forwarded_from"`
+	ForwardedFromChat *uuid.UUID `json:"forwarded_from_chat,omitempty" db:"forwarded_from_chat"`
+	ForwardedTime     *time.Time `json:"forwarded_time,omitempty" db:"forwarded_time"`
+
+	Files    FileIds  `json:"files,omitempty" db:"files"`
+	Previews Previews `json:"previews,omitempty" db:"previews"`
+}
+
+// ReactionCount is how many users reacted to a message with the emoji.
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count uint   `json:"count"`
+}
+
+// Reactions are the reaction counts of a message, most used first. They are
+// read from the database as a JSON array.
+type Reactions []ReactionCount
+
+func (r *Reactions) Scan(src any) error {
+	return scanJSON(src, r, "reactions")
+}
+
+// FileIds are the attachments of a message in their order. They are read
+// from the database as a JSON array.
+type FileIds []uuid.UUID
+
+func (f *FileIds) Scan(src any) error {
+	return scanJSON(src, f, "file ids")
+}
+
+// scanJSON reads a column aggregated as JSON into dst, a pointer to a slice
+// that is left nil for NULL.
+func scanJSON[S ~[]E, E any](src any, dst *S, what string) error {
+	switch v := src.(type) {
+	case nil:
+		*dst = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("unsupported %s type %T", what, src)
+	}
+}
+
+// Quote is a short preview of the message a reply points at. It is built
+// when the reply is read, so it always shows the current text of the quoted
+// message, and an empty text with Deleted set once that was deleted.
+type Quote struct {
+	Id          uuid.UUID `json:"id" db:"id"`
 	PersonId    uuid.UUID `json:"person_id" db:"person_id"`
-	Chat        Chat      `json:"chat" db:"chat"`
+	ChatId      uuid.UUID `json:"-" db:"chat_id"`
+	MessageText string    `json:"message" db:"message"`
+	Edited      bool      `json:"edited" db:"edited"`
+	Deleted     bool      `json:"deleted" db:"deleted"`
+}
+
+// Pin is a message pinned in its chat by PinnedBy.
+type Pin struct {
+	Message
+	PinnedBy uuid.UUID `json:"pinned_by" db:"pinned_by"`
+	PinnedAt time.Time `json:"pinned_at" db:"pinned_at"`
+}
+
+// MessageEdit is a previous version of a message, replaced at EditedAt.
+type MessageEdit struct {
+	Id          uuid.UUID `json:"id" db:"id"`
+	MessageId   uuid.UUID `json:"message_id" db:"message_id"`
 	MessageText string    `json:"message" db:"message"`
-	SendingTime time.Time `json:"time" db:"time"`
-	Status      string    `json:"status" db:"status"`
+	EditedAt    time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// Receipt is the delivery state of a message for one recipient. A message
+// that was read is always delivered too.
+type Receipt struct {
+	PersonId    uuid.UUID  `json:"person_id" db:"person_id"`
+	DeliveredAt time.Time  `json:"delivered_at" db:"delivered_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty" db:"read_at"`
 }
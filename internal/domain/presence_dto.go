@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Presence tells whether a user has a live session and when they were last
+// seen online. LastSeen is nil for users who have never connected.
+type Presence struct {
+	UserId   uuid.UUID  `json:"userId"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
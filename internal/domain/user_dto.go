@@ -1,6 +1,18 @@
 package domain
 
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
 type UserInfo struct {
 	Image []byte `json:"image"`
 	Name  string `json:"login"`
 }
+
+type Session struct {
+	Id          uuid.UUID `json:"id"`
+	Device      string    `json:"device"`
+	RemoteAddr  string    `json:"remoteAddr"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
@@ -0,0 +1,30 @@
+package handler
+
+import "messenger/internal/domain"
+
+//go:generate mockery --name=Broker --output=./mocks --case=underscore
+type Broker interface {
+	Publish(event *domain.Event) error
+	Subscribe() (<-chan *domain.Event, error)
+}
+
+// LocalBroker delivers events inside a single process. It is meant for
+// running one instance without Redis and for tests; it supports one subscriber.
+type LocalBroker struct {
+	events chan *domain.Event
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{
+		events: make(chan *domain.Event, 256),
+	}
+}
+
+func (b *LocalBroker) Publish(event *domain.Event) error {
+	b.events <- event
+	return nil
+}
+
+func (b *LocalBroker) Subscribe() (<-chan *domain.Event, error) {
+	return b.events, nil
+}
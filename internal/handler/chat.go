@@ -4,25 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
+	"github.com/gorilla/mux"
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
 	"net/http"
+	"slices"
 	"strconv"
 )
 
 //go:generate mockery --name=ChatService --output=./mocks --case=underscore
 type ChatService interface {
-	Add(chat domain.AddChat) (uuid.UUID, error)
-	AddNewUser(chatId uuid.UUID, userId uuid.UUID) error
-	RemoveUser(chatId uuid.UUID, userId uuid.UUID) error
+	Add(ownerId uuid.UUID, chat domain.AddChat) (uuid.UUID, error)
+	AddNewUser(callerId, chatId, userId uuid.UUID) error
+	RemoveUser(callerId, chatId, userId uuid.UUID) error
 	GetInfoUserChats(userId uuid.UUID, page, count uint) ([]domain.GetChat, error)
 	GetUserChats(userId uuid.UUID) ([]uuid.UUID, error)
-	GetUsers(chatId uuid.UUID) ([]uuid.UUID, error)
+	GetUsers(callerId, chatId uuid.UUID) ([]uuid.UUID, error)
+	GetMembers(callerId, chatId uuid.UUID) ([]models.ChatMember, error)
+	ChangeRole(callerId, chatId, userId uuid.UUID, role models.Role) error
 	GetUserInfo(id uuid.UUID) (domain.UserInfo, error)
-	Update(chat models.Chat) error
+	Update(callerId uuid.UUID, chat models.Chat) error
 	Delete(chatId, userId uuid.UUID) error
+	Pin(callerId, chatId, messageId uuid.UUID) (domain.PinPayload, bool, error)
+	Unpin(callerId, chatId, messageId uuid.UUID) (domain.PinPayload, bool, error)
+	GetPins(callerId, chatId uuid.UUID) ([]models.Pin, error)
 }
 
 func (h *Handler) addChat(w http.ResponseWriter, r *http.Request) {
@@ -31,39 +37,34 @@ func (h *Handler) addChat(w http.ResponseWriter, r *http.Request) {
 		slog.String("op", op),
 	)
 
-	io, err := r.GetBody()
-	if err != nil {
-		log.Error("Error with reading body", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
 	var chat domain.AddChat
-	decoder := json.NewDecoder(io)
-	err = decoder.Decode(&chat)
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&chat)
 	if err != nil {
 		log.Error("Error with decoding body", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	chatId, err := h.chatService.Add(chat)
+	userId := currentUser(r)
+	if !slices.Contains(chat.PersonIds, userId) {
+		chat.PersonIds = append(chat.PersonIds, userId)
+	}
+
+	chatId, err := h.chatService.Add(userId, chat)
 	if err != nil {
 		log.Error("Error with creating chat", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	h.mu.Lock()
-	h.clients[chatId] = make(map[uuid.UUID]*websocket.Conn)
 	for _, id := range chat.PersonIds {
-		for _, users := range h.clients {
-			if _, ok := users[id]; ok {
-				h.clients[chatId][id] = users[id]
-			}
+		if err = h.publishMembership(id, chatId, domain.MembershipJoin); err != nil {
+			log.Error("Error with publishing membership", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 	}
-	h.mu.Unlock()
 
 	_, err = w.Write([]byte(fmt.Sprintf("id: %v", chatId)))
 	if err != nil {
@@ -97,9 +98,14 @@ func (h *Handler) addNewUserChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("adding new user")
-	err = h.chatService.AddNewUser(chatId, personId)
+	err = h.chatService.AddNewUser(currentUser(r), chatId, personId)
 	if err != nil {
 		log.Error("Error with adding new user", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	if err = h.publishMembership(personId, chatId, domain.MembershipJoin); err != nil {
+		log.Error("Error with publishing membership", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -129,12 +135,7 @@ func (h *Handler) getInfoUserChats(w http.ResponseWriter, r *http.Request) {
 		slog.String("op", op),
 	)
 
-	userId, err := uuid.Parse(r.URL.Query().Get("userId"))
-	if err != nil {
-		log.Error("Error with parsing userId", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	userId := currentUser(r)
 
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
 	if err != nil || page < 1 {
@@ -179,12 +180,14 @@ func (h *Handler) getPersons(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("getting chats for user")
-	ids, err := h.chatService.GetUsers(chatId)
+	log.Info("getting persons of chat")
+	ids, err := h.chatService.GetUsers(currentUser(r), chatId)
 	if err != nil {
 		log.Error("Error with getting persons", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
 	}
-	log.Info("got chats for user")
+	log.Info("got persons of chat")
 
 	log.Info("getting info about user")
 	users := make([]domain.UserInfo, len(ids))
@@ -200,6 +203,65 @@ func (h *Handler) getPersons(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(users); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) getMembers(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getMembers"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	chatId, err := uuid.Parse(r.URL.Query().Get("chatId"))
+	if err != nil {
+		log.Error("Error with parsing chatId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Info("getting members of chat")
+	members, err := h.chatService.GetMembers(currentUser(r), chatId)
+	if err != nil {
+		log.Error("Error with getting members", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("got members of chat")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(members); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) changeRole(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.changeRole"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	var change domain.ChangeRole
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		log.Error("Error with decoding body", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Info("changing role", slog.String("chatId", change.ChatId.String()),
+		slog.String("role", string(change.Role)))
+	err = h.chatService.ChangeRole(currentUser(r), change.ChatId, change.PersonId, change.Role)
+	if err != nil {
+		log.Error("Error with changing role", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("successfully changed role")
+
+	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) removeUser(w http.ResponseWriter, r *http.Request) {
@@ -223,9 +285,14 @@ func (h *Handler) removeUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("removing user")
-	err = h.chatService.RemoveUser(chatId, userId)
+	err = h.chatService.RemoveUser(currentUser(r), chatId, userId)
 	if err != nil {
 		log.Error("Error with removing user", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	if err = h.publishMembership(userId, chatId, domain.MembershipLeave); err != nil {
+		log.Error("Error with publishing membership", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -248,10 +315,10 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("updating chat", slog.String("chatId", chat.Id.String()))
-	err = h.chatService.Update(chat)
+	err = h.chatService.Update(currentUser(r), chat)
 	if err != nil {
 		log.Error("Error with updating chat", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(statusFromError(err))
 		return
 	}
 	log.Info("successfully updated chat", slog.String("chatId", chat.Id.String()))
@@ -272,17 +339,17 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId, err := uuid.Parse(r.URL.Query().Get("userId"))
-	if err != nil {
-		log.Error("Error with parsing userId", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	userId := currentUser(r)
 
 	log.Info("deleting chat", slog.String("chatId", chatId.String()))
 	err = h.chatService.Delete(chatId, userId)
 	if err != nil {
 		log.Error("Error with deleting chat", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	if err = h.publishMembership(uuid.Nil, chatId, domain.MembershipRemove); err != nil {
+		log.Error("Error with publishing membership", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -290,3 +357,94 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *Handler) getPins(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getPins"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	chatId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing chatId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Info("getting pinned messages", slog.String("chatId", chatId.String()))
+	pins, err := h.chatService.GetPins(currentUser(r), chatId)
+	if err != nil {
+		log.Error("Error with getting pinned messages", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(pins); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) pin(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.pin"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	h.writePin(w, r, log, true)
+}
+
+func (h *Handler) unpin(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.unpin"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	h.writePin(w, r, log, false)
+}
+
+// writePin pins or unpins the message from the path and, if that changed
+// anything, tells the chat about it.
+func (h *Handler) writePin(w http.ResponseWriter, r *http.Request, log *slog.Logger, pin bool) {
+	vars := mux.Vars(r)
+	chatId, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Error("Error with parsing chatId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	messageId, err := uuid.Parse(vars["messageId"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	change, event := h.chatService.Unpin, domain.EventMessageUnpinned
+	if pin {
+		change, event = h.chatService.Pin, domain.EventMessagePinned
+	}
+
+	payload, changed, err := change(currentUser(r), chatId, messageId)
+	if err != nil {
+		log.Error("Error with changing pin", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	if changed {
+		if err = h.publish(chatId, event, payload); err != nil {
+			log.Error("Error with publishing pin", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(payload); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
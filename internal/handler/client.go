@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"messenger/internal/app/wsserver"
+	"messenger/internal/domain"
+	"sync"
+	"time"
+)
+
+var (
+	errClientClosed  = errors.New("client closed")
+	errSendQueueFull = errors.New("send queue full")
+)
+
+// client owns a single WebSocket connection. Frames are queued on send and
+// written by writePump, so a slow peer never blocks the goroutine
+// delivering events to other clients.
+type client struct {
+	conn        *websocket.Conn
+	userId      uuid.UUID
+	sessionId   uuid.UUID
+	device      string
+	remoteAddr  string
+	connectedAt time.Time
+	cfg         wsserver.ClientConfig
+	send        chan []byte
+	done        chan struct{}
+
+	// drained is signalled by writePump whenever it takes a frame off send,
+	// so push can wait for room instead of dropping frames.
+	drained chan struct{}
+
+	// typingLimiter throttles typing events sent by the session.
+	typingLimiter *rateLimiter
+
+	// chats is the set of chats the session is subscribed to, guarded by hub.mu.
+	chats map[uuid.UUID]struct{}
+
+	mu        sync.Mutex
+	closed    bool
+	closeCode int
+
+	// While syncing, broadcast events are held in pending until the missed
+	// messages have been replayed, so live events never overtake them.
+	syncing bool
+	pending []domain.Envelope
+}
+
+func newClient(conn *websocket.Conn, userId uuid.UUID, cfg wsserver.ClientConfig) *client {
+	return &client{
+		conn:          conn,
+		userId:        userId,
+		sessionId:     uuid.New(),
+		connectedAt:   time.Now(),
+		cfg:           cfg,
+		send:          make(chan []byte, cfg.SendQueueSize),
+		done:          make(chan struct{}),
+		drained:       make(chan struct{}, 1),
+		typingLimiter: newRateLimiter(cfg.TypingRate, cfg.TypingBurst),
+		chats:         make(map[uuid.UUID]struct{}),
+		closeCode:     websocket.CloseNormalClosure,
+	}
+}
+
+func (c *client) session() domain.Session {
+	return domain.Session{
+		Id:          c.sessionId,
+		Device:      c.device,
+		RemoteAddr:  c.remoteAddr,
+		ConnectedAt: c.connectedAt,
+	}
+}
+
+func (c *client) write(envelope domain.Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(data)
+}
+
+func (c *client) enqueue(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+	}
+
+	if c.cfg.OverflowPolicy == wsserver.OverflowDisconnect {
+		c.closeLocked(websocket.CloseTryAgainLater)
+		return errSendQueueFull
+	}
+
+	// Only enqueue adds to send and it holds mu, so after dropping the
+	// oldest frame there is always room for the new one.
+	select {
+	case <-c.send:
+	default:
+	}
+	c.send <- data
+	return nil
+}
+
+// deliver writes a broadcast event, or holds it back while the session is
+// catching up. A session whose backlog outgrows the send queue is
+// disconnected and has to catch up again.
+func (c *client) deliver(envelope domain.Envelope) error {
+	c.mu.Lock()
+	if !c.syncing {
+		c.mu.Unlock()
+		return c.write(envelope)
+	}
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+	if len(c.pending) >= c.cfg.SendQueueSize {
+		c.closeLocked(websocket.CloseTryAgainLater)
+		return errSendQueueFull
+	}
+	c.pending = append(c.pending, envelope)
+	return nil
+}
+
+// push queues a frame, waiting for room instead of applying the overflow
+// policy. Replay uses it so that no part of the backlog is dropped.
+func (c *client) push(envelope domain.Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return errClientClosed
+		}
+		select {
+		case c.send <- data:
+			c.mu.Unlock()
+			return nil
+		default:
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.drained:
+		case <-c.done:
+			return errClientClosed
+		}
+	}
+}
+
+// startSync holds back broadcast events until finishSync.
+func (c *client) startSync() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncing = true
+}
+
+// finishSync writes the events held back during the catch-up, except those
+// skip reports as already replayed, and switches the session to live delivery.
+func (c *client) finishSync(skip func(domain.Envelope) bool) error {
+	for {
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		if len(pending) == 0 {
+			c.syncing = false
+			c.mu.Unlock()
+			return nil
+		}
+		c.mu.Unlock()
+
+		for _, envelope := range pending {
+			if skip(envelope) {
+				continue
+			}
+			if err := c.push(envelope); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *client) close() {
+	c.closeWith(websocket.CloseNormalClosure)
+}
+
+func (c *client) closeWith(code int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked(code)
+}
+
+func (c *client) closeLocked(code int) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeCode = code
+	close(c.done)
+}
+
+// prepareRead prepares the connection for reading: it limits frame size and
+// extends the read deadline every time the peer answers a ping.
+func (c *client) prepareRead() {
+	c.conn.SetReadLimit(c.cfg.MaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
+	})
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(c.cfg.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			select {
+			case c.drained <- struct{}{}:
+			default:
+			}
+
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			c.mu.Lock()
+			code := c.closeCode
+			c.mu.Unlock()
+
+			_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""),
+				time.Now().Add(c.cfg.WriteWait))
+			return
+		}
+	}
+}
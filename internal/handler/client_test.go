@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"messenger/internal/app/wsserver"
+	"testing"
+)
+
+func TestClient_Enqueue(t *testing.T) {
+	cases := []struct {
+		name           string
+		policy         string
+		frames         []string
+		expectedErr    error
+		expectedQueue  []string
+		expectedClosed bool
+	}{
+		{
+			name:          "Очередь не переполнена",
+			policy:        wsserver.OverflowDropOldest,
+			frames:        []string{"1", "2"},
+			expectedQueue: []string{"1", "2"},
+		},
+		{
+			name:          "Переполнение: удаляется самый старый кадр",
+			policy:        wsserver.OverflowDropOldest,
+			frames:        []string{"1", "2", "3"},
+			expectedQueue: []string{"2", "3"},
+		},
+		{
+			name:           "Переполнение: клиент отключается",
+			policy:         wsserver.OverflowDisconnect,
+			frames:         []string{"1", "2", "3"},
+			expectedErr:    errSendQueueFull,
+			expectedQueue:  []string{"1", "2"},
+			expectedClosed: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testClientConfig
+			cfg.SendQueueSize = 2
+			cfg.OverflowPolicy = tt.policy
+			c := newClient(nil, uuid.New(), cfg)
+
+			var err error
+			for _, frame := range tt.frames {
+				err = c.enqueue([]byte(frame))
+			}
+			require.Equal(t, tt.expectedErr, err)
+
+			queue := make([]string, 0, len(c.send))
+			for len(c.send) > 0 {
+				queue = append(queue, string(<-c.send))
+			}
+			require.Equal(t, tt.expectedQueue, queue)
+			require.Equal(t, tt.expectedClosed, c.closed)
+
+			if tt.expectedClosed {
+				require.Equal(t, errClientClosed, c.enqueue([]byte("4")))
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"messenger/internal/domain"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/access"
+	"messenger/internal/services/message"
+	"messenger/pkg/cursor"
+	"slices"
+)
+
+// maxDeliveredBatch caps how many messages a client may acknowledge at once.
+const maxDeliveredBatch = 100
+
+type eventHandler func(c *client, payload json.RawMessage) (any, error)
+
+type protocolError struct {
+	code    string
+	message string
+}
+
+func (e *protocolError) Error() string {
+	return e.message
+}
+
+func newProtocolError(code, format string, args ...any) error {
+	return &protocolError{
+		code:    code,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (h *Handler) initEvents() {
+	h.events = map[string]eventHandler{
+		domain.EventMessageSend:    h.onMessageSend,
+		domain.EventMessageEdit:    h.onMessageEdit,
+		domain.EventMessageDelete:  h.onMessageDelete,
+		domain.EventTypingStart:    h.onTypingStart,
+		domain.EventTypingStop:     h.onTypingStop,
+		domain.EventRead:           h.onRead,
+		domain.EventDelivered:      h.onDelivered,
+		domain.EventReactionAdd:    h.onReactionAdd,
+		domain.EventReactionRemove: h.onReactionRemove,
+		domain.EventSubscribe:      h.onSubscribe,
+		domain.EventUnsubscribe:    h.onUnsubscribe,
+	}
+}
+
+func (h *Handler) dispatch(c *client, envelope domain.Envelope) {
+	const op = "handler.dispatch"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("type", envelope.Type),
+	)
+
+	if envelope.Version != 0 && envelope.Version != domain.ProtocolVersion {
+		h.writeError(c, envelope.Id, newProtocolError(domain.ErrCodeUnsupportedVersion,
+			"unsupported protocol version %d", envelope.Version))
+		return
+	}
+
+	if envelope.Type == domain.EventPing {
+		h.writeFrame(c, domain.Envelope{Type: domain.EventPong, Id: envelope.Id})
+		return
+	}
+
+	handle, ok := h.events[envelope.Type]
+	if !ok {
+		h.writeError(c, envelope.Id, newProtocolError(domain.ErrCodeUnknownType,
+			"unknown event type %q", envelope.Type))
+		return
+	}
+
+	result, err := handle(c, envelope.Payload)
+	if err != nil {
+		log.Error("Error with handling event", slog.String("err", err.Error()))
+		h.writeError(c, envelope.Id, err)
+		return
+	}
+
+	ack := domain.Envelope{
+		Version: domain.ProtocolVersion,
+		Type:    domain.EventAck,
+		Id:      envelope.Id,
+	}
+	if result != nil {
+		ack.Payload, err = json.Marshal(result)
+		if err != nil {
+			log.Error("Error with encoding ack", slog.String("err", err.Error()))
+			h.writeError(c, envelope.Id, err)
+			return
+		}
+	}
+	h.writeFrame(c, ack)
+}
+
+func (h *Handler) writeError(c *client, id string, err error) {
+	payload := domain.ErrorPayload{
+		Code:    domain.ErrCodeInternal,
+		Message: "internal error",
+	}
+
+	var protoErr *protocolError
+	switch {
+	case errors.As(err, &protoErr):
+		payload.Code = protoErr.code
+		payload.Message = protoErr.message
+	case errors.Is(err, access.ErrForbidden):
+		payload.Code = domain.ErrCodeForbidden
+		payload.Message = "forbidden"
+	case errors.Is(err, message.ErrDeleted):
+		payload.Code = domain.ErrCodeNotFound
+		payload.Message = "message deleted"
+	case errors.Is(err, message.ErrInvalidReply):
+		payload.Code = domain.ErrCodeBadRequest
+		payload.Message = "invalid reply"
+	case errors.Is(err, message.ErrInvalidThread):
+		payload.Code = domain.ErrCodeBadRequest
+		payload.Message = "invalid thread"
+	case errors.Is(err, message.ErrInvalidReaction):
+		payload.Code = domain.ErrCodeBadRequest
+		payload.Message = "invalid reaction"
+	case errors.Is(err, message.ErrInvalidAttachment):
+		payload.Code = domain.ErrCodeBadRequest
+		payload.Message = "invalid attachment"
+	}
+
+	data, _ := json.Marshal(payload)
+	h.writeFrame(c, domain.Envelope{
+		Version: domain.ProtocolVersion,
+		Type:    domain.EventError,
+		Id:      id,
+		Payload: data,
+	})
+}
+
+func (h *Handler) writeFrame(c *client, envelope domain.Envelope) {
+	if err := c.write(envelope); err != nil {
+		h.log.Warn("Error with writing frame", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) publish(chatId uuid.UUID, eventType string, payload any) error {
+	return h.publishEvent(&domain.Event{ChatId: chatId}, eventType, payload)
+}
+
+// publishToUser sends the event to every session of the user instead of the chat.
+func (h *Handler) publishToUser(userId, chatId uuid.UUID, eventType string, payload any) error {
+	return h.publishEvent(&domain.Event{ChatId: chatId, UserId: userId}, eventType, payload)
+}
+
+// publishMembership tells every instance to subscribe the sessions of the
+// user to the chat or to unsubscribe them. Without a user the chat is
+// dropped from all sessions.
+func (h *Handler) publishMembership(userId, chatId uuid.UUID, change string) error {
+	return h.broker.Publish(&domain.Event{ChatId: chatId, UserId: userId, Membership: change})
+}
+
+func (h *Handler) publishEvent(event *domain.Event, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event.Envelope = domain.Envelope{
+		Version: domain.ProtocolVersion,
+		Type:    eventType,
+		Payload: data,
+	}
+	return h.broker.Publish(event)
+}
+
+func newMessagePayload(msg models.Message) domain.NewMessagePayload {
+	return domain.NewMessagePayload{
+		Message: msg,
+		Cursor:  cursor.New(msg.SendingTime, msg.Id).Encode(),
+	}
+}
+
+func decodePayload(payload json.RawMessage, v any) error {
+	if len(payload) == 0 {
+		return newProtocolError(domain.ErrCodeBadRequest, "payload is required")
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return newProtocolError(domain.ErrCodeBadRequest, "invalid payload: %v", err)
+	}
+	return nil
+}
+
+func (h *Handler) onMessageSend(c *client, payload json.RawMessage) (any, error) {
+	var msg domain.MessageAdd
+	if err := decodePayload(payload, &msg); err != nil {
+		return nil, err
+	}
+	msg.PersonId = c.userId
+
+	if msg.ThreadRootId != nil {
+		return h.addToThread(msg)
+	}
+
+	addedMsg, err := h.messageService.Add(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	newMsg := newMessagePayload(addedMsg)
+	if err = h.publish(addedMsg.Chat.Id, domain.EventMessageNew, newMsg); err != nil {
+		return nil, err
+	}
+	return newMsg, nil
+}
+
+func (h *Handler) onMessageEdit(c *client, payload json.RawMessage) (any, error) {
+	var edit domain.MessageEditPayload
+	if err := decodePayload(payload, &edit); err != nil {
+		return nil, err
+	}
+
+	msg, err := h.messageService.Edit(c.userId, edit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.publish(msg.Chat.Id, domain.EventMessageEdited, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (h *Handler) onMessageDelete(c *client, payload json.RawMessage) (any, error) {
+	var del domain.MessageDeletePayload
+	if err := decodePayload(payload, &del); err != nil {
+		return nil, err
+	}
+
+	scope, ok := deleteScope(del.Scope)
+	if !ok {
+		return nil, newProtocolError(domain.ErrCodeBadRequest, "unknown delete scope %q", del.Scope)
+	}
+
+	return h.deleteMessage(c.userId, del.MessageId, scope)
+}
+
+func (h *Handler) onTypingStart(c *client, payload json.RawMessage) (any, error) {
+	chatId, err := h.decodeTyping(c, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients refresh the indicator while the user keeps typing; only the
+	// first start is fanned out.
+	if !h.typing.start(c, chatId) {
+		return nil, nil
+	}
+	if err = h.publishTyping(chatId, c.userId, domain.EventTypingStart); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (h *Handler) onTypingStop(c *client, payload json.RawMessage) (any, error) {
+	chatId, err := h.decodeTyping(c, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if !h.typing.stop(c, chatId) {
+		return nil, nil
+	}
+	if err = h.publishTyping(chatId, c.userId, domain.EventTypingStop); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (h *Handler) decodeTyping(c *client, payload json.RawMessage) (uuid.UUID, error) {
+	if !c.typingLimiter.allow() {
+		return uuid.Nil, newProtocolError(domain.ErrCodeRateLimited, "too many typing events")
+	}
+
+	var typing domain.TypingPayload
+	if err := decodePayload(payload, &typing); err != nil {
+		return uuid.Nil, err
+	}
+	if !h.hub.isSubscribed(c, typing.ChatId) {
+		return uuid.Nil, newProtocolError(domain.ErrCodeNotFound, "chat %v not found", typing.ChatId)
+	}
+	return typing.ChatId, nil
+}
+
+func (h *Handler) onTypingExpired(chatId, userId uuid.UUID) {
+	if err := h.publishTyping(chatId, userId, domain.EventTypingStop); err != nil {
+		h.log.Warn("Error with publishing typing stop", slog.String("err", err.Error()))
+	}
+}
+
+// publishTyping fans a typing event out to the other members of the chat.
+func (h *Handler) publishTyping(chatId, userId uuid.UUID, eventType string) error {
+	event := &domain.Event{ChatId: chatId, SkipUserId: userId}
+	return h.publishEvent(event, eventType, domain.TypingPayload{
+		ChatId:   chatId,
+		PersonId: userId,
+	})
+}
+
+func (h *Handler) onRead(c *client, payload json.RawMessage) (any, error) {
+	var read domain.ReadPayload
+	if err := decodePayload(payload, &read); err != nil {
+		return nil, err
+	}
+
+	return h.markRead(c.userId, read.MessageId)
+}
+
+func (h *Handler) onDelivered(c *client, payload json.RawMessage) (any, error) {
+	var delivered domain.DeliveredPayload
+	if err := decodePayload(payload, &delivered); err != nil {
+		return nil, err
+	}
+
+	if len(delivered.MessageIds) == 0 || len(delivered.MessageIds) > maxDeliveredBatch {
+		return nil, newProtocolError(domain.ErrCodeBadRequest,
+			"messageIds must hold from 1 to %d ids", maxDeliveredBatch)
+	}
+
+	statuses, err := h.messageService.MarkDelivered(c.userId, delivered.MessageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.publishStatuses(statuses); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (h *Handler) onReactionAdd(c *client, payload json.RawMessage) (any, error) {
+	var reaction domain.ReactionPayload
+	if err := decodePayload(payload, &reaction); err != nil {
+		return nil, err
+	}
+
+	return h.react(c.userId, reaction, true)
+}
+
+func (h *Handler) onReactionRemove(c *client, payload json.RawMessage) (any, error) {
+	var reaction domain.ReactionPayload
+	if err := decodePayload(payload, &reaction); err != nil {
+		return nil, err
+	}
+
+	return h.react(c.userId, reaction, false)
+}
+
+func (h *Handler) onSubscribe(c *client, payload json.RawMessage) (any, error) {
+	var sub domain.SubscribePayload
+	if err := decodePayload(payload, &sub); err != nil {
+		return nil, err
+	}
+
+	chatIds, err := h.chatService.GetUserChats(c.userId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(chatIds, sub.ChatId) {
+		return nil, newProtocolError(domain.ErrCodeNotFound, "chat %v not found", sub.ChatId)
+	}
+
+	h.hub.subscribe(c, sub.ChatId)
+	return nil, nil
+}
+
+func (h *Handler) onUnsubscribe(c *client, payload json.RawMessage) (any, error) {
+	var sub domain.SubscribePayload
+	if err := decodePayload(payload, &sub); err != nil {
+		return nil, err
+	}
+
+	h.hub.unsubscribe(c, sub.ChatId)
+	return nil, nil
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/file"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// uploadOverhead leaves room for the multipart framing around the file.
+const uploadOverhead = 1 << 20
+
+//go:generate mockery --name=FileService --output=./mocks --case=underscore
+type FileService interface {
+	Upload(userId uuid.UUID, name string, r io.Reader) (models.File, error)
+	Open(userId, id uuid.UUID) (models.File, io.ReadCloser, error)
+	OpenThumbnail(userId, id uuid.UUID, size int) (models.Thumbnail, io.ReadCloser, error)
+	CreateUpload(userId uuid.UUID, name string, length int64) (models.Upload, error)
+	GetUpload(userId, id uuid.UUID) (models.Upload, error)
+	AppendChunk(userId, id uuid.UUID, offset int64, r io.Reader) (int64, error)
+	FinishUpload(userId, id uuid.UUID) (models.File, error)
+	CancelUpload(userId, id uuid.UUID) error
+}
+
+// uploadFile stores the "file" part of a multipart form. The part is streamed
+// to the service, so the upload is never buffered by the handler.
+func (h *Handler) uploadFile(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.uploadFile"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	r.Body = http.MaxBytesReader(w, r.Body, file.MaxFileSize+uploadOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		log.Error("Error with reading multipart form", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			log.Error("Error with reading file part", slog.String("err", err.Error()))
+			w.WriteHeader(uploadStatus(err))
+			return
+		}
+		if part.FormName() != "file" {
+			_ = part.Close()
+			continue
+		}
+
+		uploaded, err := h.fileService.Upload(currentUser(r), part.FileName(), part)
+		_ = part.Close()
+		if err != nil {
+			log.Error("Error with uploading file", slog.String("err", err.Error()))
+			w.WriteHeader(uploadStatus(err))
+			return
+		}
+		log.Info("file uploaded", slog.String("fileId", uploaded.Id.String()))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err = json.NewEncoder(w).Encode(uploaded); err != nil {
+			log.Error("Error writing response", slog.String("err", err.Error()))
+		}
+		return
+	}
+}
+
+// uploadStatus maps upload errors, including a body over the size limit.
+func uploadStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, io.EOF):
+		return http.StatusBadRequest
+	default:
+		return statusFromError(err)
+	}
+}
+
+func (h *Handler) downloadFile(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.downloadFile"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing fileId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f, content, err := h.fileService.Open(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with opening file", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, content); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) downloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.downloadThumbnail"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Error("Error with parsing fileId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.Atoi(vars["size"])
+	if err != nil {
+		log.Error("Error with parsing size", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, content, err := h.fileService.OpenThumbnail(currentUser(r), id, size)
+	if err != nil {
+		log.Error("Error with opening thumbnail", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, content); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
@@ -1,27 +1,38 @@
 package handler
 
 import (
-	"github.com/google/uuid"
+	"errors"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"log/slog"
-	"messenger/internal/domain/models"
+	"messenger/internal/app/wsserver"
+	"messenger/internal/auth"
+	"messenger/internal/services/access"
+	"messenger/internal/services/chat"
+	"messenger/internal/services/file"
+	"messenger/internal/services/message"
 	"net/http"
-	"sync"
 )
 
 type Handler struct {
-	mux            *mux.Router
-	wsUpg          *websocket.Upgrader
-	log            *slog.Logger
-	mu             sync.RWMutex
-	messageService MessageService
-	chatService    ChatService
-	clients        map[uuid.UUID]map[uuid.UUID]*websocket.Conn
-	broadcast      chan *models.Message
+	mux             *mux.Router
+	wsUpg           *websocket.Upgrader
+	log             *slog.Logger
+	messageService  MessageService
+	chatService     ChatService
+	presenceService PresenceService
+	fileService     FileService
+	hub             *hub
+	broker          Broker
+	authenticator   auth.Authenticator
+	clientCfg       wsserver.ClientConfig
+	events          map[string]eventHandler
+	typing          *typingTracker
 }
 
-func NewHandler(log *slog.Logger, messengerService MessageService, chatService ChatService) *Handler {
+func NewHandler(log *slog.Logger, messengerService MessageService, chatService ChatService,
+	presenceService PresenceService, fileService FileService, broker Broker,
+	authenticator auth.Authenticator, clientCfg wsserver.ClientConfig) *Handler {
 	return &Handler{
 		mux: mux.NewRouter(),
 		log: log,
@@ -30,15 +41,21 @@ func NewHandler(log *slog.Logger, messengerService MessageService, chatService C
 				return true
 			},
 		},
-		mu:             sync.RWMutex{},
-		messageService: messengerService,
-		chatService:    chatService,
-		broadcast:      make(chan *models.Message),
-		clients:        make(map[uuid.UUID]map[uuid.UUID]*websocket.Conn),
+		messageService:  messengerService,
+		chatService:     chatService,
+		presenceService: presenceService,
+		fileService:     fileService,
+		broker:          broker,
+		authenticator:   authenticator,
+		clientCfg:       clientCfg,
+		hub:             newHub(),
 	}
 }
 
 func (h *Handler) InitRoutes() {
+	h.initEvents()
+	h.typing = newTypingTracker(h.clientCfg.TypingTimeout, h.onTypingExpired)
+	h.mux.Use(h.authenticate)
 	h.mux.HandleFunc("/ws", h.wsHandler)
 	h.mux.HandleFunc("/chat/add", h.addChat).Methods(http.MethodPost)
 	h.mux.HandleFunc("/chat/info", h.getInfoUserChats).Methods(http.MethodGet)
@@ -47,10 +64,61 @@ func (h *Handler) InitRoutes() {
 	h.mux.HandleFunc("/chat", h.delete).Methods(http.MethodDelete)
 	h.mux.HandleFunc("/chat/persons/add", h.addNewUserChat).Methods(http.MethodPost)
 	h.mux.HandleFunc("/chat/persons", h.getPersons).Methods(http.MethodGet)
+	h.mux.HandleFunc("/chat/members", h.getMembers).Methods(http.MethodGet)
+	h.mux.HandleFunc("/chat/persons/role", h.changeRole).Methods(http.MethodPut)
+	h.mux.HandleFunc("/chat/{id}/messages", h.getHistory).Methods(http.MethodGet)
+	h.mux.HandleFunc("/chat/{id}/pins", h.getPins).Methods(http.MethodGet)
+	h.mux.HandleFunc("/chat/{id}/pins/{messageId}", h.pin).Methods(http.MethodPost)
+	h.mux.HandleFunc("/chat/{id}/pins/{messageId}", h.unpin).Methods(http.MethodDelete)
+	h.mux.HandleFunc("/messages/forward", h.forward).Methods(http.MethodPost)
+	h.mux.HandleFunc("/messages/{id}", h.editMessage).Methods(http.MethodPut)
+	h.mux.HandleFunc("/messages/{id}", h.removeMessage).Methods(http.MethodDelete)
+	h.mux.HandleFunc("/messages/{id}/edits", h.getEdits).Methods(http.MethodGet)
+	h.mux.HandleFunc("/messages/{id}/thread", h.getThread).Methods(http.MethodGet)
+	h.mux.HandleFunc("/messages/{id}/reactions", h.addReaction).Methods(http.MethodPost)
+	h.mux.HandleFunc("/messages/{id}/reactions", h.removeReaction).Methods(http.MethodDelete)
+	h.mux.HandleFunc("/messages/{id}/read", h.readMessages).Methods(http.MethodPost)
+	h.mux.HandleFunc("/messages/{id}/receipts", h.getReceipts).Methods(http.MethodGet)
 	h.mux.HandleFunc("/send", h.send).Methods(http.MethodPost)
+	h.mux.HandleFunc("/files", h.uploadFile).Methods(http.MethodPost)
+	h.mux.HandleFunc("/files/{id}", h.downloadFile).Methods(http.MethodGet)
+	h.mux.HandleFunc("/files/{id}/thumbnails/{size}", h.downloadThumbnail).Methods(http.MethodGet)
+	h.mux.HandleFunc("/uploads", h.createUpload).Methods(http.MethodPost)
+	h.mux.HandleFunc("/uploads/{id}", h.getUpload).Methods(http.MethodHead)
+	h.mux.HandleFunc("/uploads/{id}", h.appendChunk).Methods(http.MethodPatch)
+	h.mux.HandleFunc("/uploads/{id}", h.cancelUpload).Methods(http.MethodDelete)
+	h.mux.HandleFunc("/uploads/{id}/finish", h.finishUpload).Methods(http.MethodPost)
+	h.mux.HandleFunc("/sessions", h.getSessions).Methods(http.MethodGet)
+	h.mux.HandleFunc("/users/presence", h.getPresences).Methods(http.MethodGet)
+	h.mux.HandleFunc("/users/{id}/presence", h.getPresence).Methods(http.MethodGet)
 	go h.writeToClientsBroadcast()
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.mux.ServeHTTP(w, r)
 }
+
+// statusFromError maps service errors to HTTP status codes.
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, access.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, chat.ErrInvalidRole), errors.Is(err, message.ErrInvalidReply),
+		errors.Is(err, message.ErrInvalidThread), errors.Is(err, message.ErrInvalidReaction),
+		errors.Is(err, chat.ErrInvalidPin), errors.Is(err, message.ErrInvalidForward),
+		errors.Is(err, message.ErrInvalidAttachment), errors.Is(err, file.ErrInvalidLength):
+		return http.StatusBadRequest
+	case errors.Is(err, message.ErrDeleted):
+		return http.StatusGone
+	case errors.Is(err, file.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, file.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, file.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, file.ErrOffsetMismatch), errors.Is(err, file.ErrUploadIncomplete):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"messenger/internal/domain"
+	"slices"
+	"sync"
+)
+
+// hub is the registry of WebSocket connections on this instance. A user may
+// be connected from several devices at once; every connection is a session
+// with its own chat subscriptions.
+type hub struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]map[uuid.UUID]*client
+	chats    map[uuid.UUID]map[*client]struct{}
+}
+
+func newHub() *hub {
+	return &hub{
+		sessions: make(map[uuid.UUID]map[uuid.UUID]*client),
+		chats:    make(map[uuid.UUID]map[*client]struct{}),
+	}
+}
+
+// register adds the session and subscribes it to chatIds. If the user already
+// had a session with the same id, that session is unregistered and returned
+// so the caller can close it.
+func (h *hub) register(c *client, chatIds []uuid.UUID) *client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	userSessions, ok := h.sessions[c.userId]
+	if !ok {
+		userSessions = make(map[uuid.UUID]*client)
+		h.sessions[c.userId] = userSessions
+	}
+
+	replaced := userSessions[c.sessionId]
+	if replaced != nil {
+		h.unregisterLocked(replaced)
+	}
+
+	userSessions[c.sessionId] = c
+	for _, chatId := range chatIds {
+		h.subscribeLocked(c, chatId)
+	}
+	return replaced
+}
+
+func (h *hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unregisterLocked(c)
+}
+
+func (h *hub) unregisterLocked(c *client) {
+	userSessions := h.sessions[c.userId]
+	if userSessions[c.sessionId] != c {
+		return
+	}
+
+	delete(userSessions, c.sessionId)
+	if len(userSessions) == 0 {
+		delete(h.sessions, c.userId)
+	}
+
+	for chatId := range c.chats {
+		h.unsubscribeLocked(c, chatId)
+	}
+}
+
+func (h *hub) subscribe(c *client, chatId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribeLocked(c, chatId)
+}
+
+func (h *hub) subscribeLocked(c *client, chatId uuid.UUID) {
+	subscribers, ok := h.chats[chatId]
+	if !ok {
+		subscribers = make(map[*client]struct{})
+		h.chats[chatId] = subscribers
+	}
+	subscribers[c] = struct{}{}
+	c.chats[chatId] = struct{}{}
+}
+
+func (h *hub) unsubscribe(c *client, chatId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unsubscribeLocked(c, chatId)
+}
+
+func (h *hub) unsubscribeLocked(c *client, chatId uuid.UUID) {
+	delete(c.chats, chatId)
+
+	subscribers := h.chats[chatId]
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		delete(h.chats, chatId)
+	}
+}
+
+// subscribeUser subscribes every session of the user to the chat.
+func (h *hub) subscribeUser(userId, chatId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range h.sessions[userId] {
+		h.subscribeLocked(c, chatId)
+	}
+}
+
+// unsubscribeUser unsubscribes every session of the user from the chat.
+func (h *hub) unsubscribeUser(userId, chatId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range h.sessions[userId] {
+		h.unsubscribeLocked(c, chatId)
+	}
+}
+
+func (h *hub) removeChat(chatId uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.chats[chatId] {
+		delete(c.chats, chatId)
+	}
+	delete(h.chats, chatId)
+}
+
+// isSubscribed reports whether the session is subscribed to the chat.
+func (h *hub) isSubscribed(c *client, chatId uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, ok := c.chats[chatId]
+	return ok
+}
+
+// chatClients returns a snapshot of the sessions subscribed to the chat.
+func (h *hub) chatClients(chatId uuid.UUID) []*client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*client, 0, len(h.chats[chatId]))
+	for c := range h.chats[chatId] {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// userClients returns a snapshot of the user's connected sessions.
+func (h *hub) userClients(userId uuid.UUID) []*client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*client, 0, len(h.sessions[userId]))
+	for _, c := range h.sessions[userId] {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func (h *hub) userSessions(userId uuid.UUID) []domain.Session {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sessions := make([]domain.Session, 0, len(h.sessions[userId]))
+	for _, c := range h.sessions[userId] {
+		sessions = append(sessions, c.session())
+	}
+	slices.SortFunc(sessions, func(a, b domain.Session) int {
+		return a.ConnectedAt.Compare(b.ConnectedAt)
+	})
+	return sessions
+}
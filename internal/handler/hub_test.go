@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHub_MultipleSessions(t *testing.T) {
+	h := newHub()
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	phone := newClient(nil, userId, testClientConfig)
+	laptop := newClient(nil, userId, testClientConfig)
+
+	require.Nil(t, h.register(phone, []uuid.UUID{chatId}))
+	require.Nil(t, h.register(laptop, []uuid.UUID{chatId}))
+	require.ElementsMatch(t, []*client{phone, laptop}, h.chatClients(chatId))
+	require.Len(t, h.userSessions(userId), 2)
+
+	h.unregister(phone)
+	require.Equal(t, []*client{laptop}, h.chatClients(chatId))
+	require.Len(t, h.userSessions(userId), 1)
+
+	h.unregister(laptop)
+	require.Empty(t, h.chatClients(chatId))
+	require.Empty(t, h.userSessions(userId))
+	require.Empty(t, h.sessions)
+	require.Empty(t, h.chats)
+}
+
+func TestHub_ReplaceSession(t *testing.T) {
+	h := newHub()
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	old := newClient(nil, userId, testClientConfig)
+	reconnected := newClient(nil, userId, testClientConfig)
+	reconnected.sessionId = old.sessionId
+
+	require.Nil(t, h.register(old, []uuid.UUID{chatId}))
+	require.Equal(t, old, h.register(reconnected, []uuid.UUID{chatId}))
+	require.Equal(t, []*client{reconnected}, h.chatClients(chatId))
+
+	h.unregister(old)
+	require.Equal(t, []*client{reconnected}, h.chatClients(chatId))
+}
+
+func TestHub_Subscriptions(t *testing.T) {
+	h := newHub()
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	phone := newClient(nil, userId, testClientConfig)
+	laptop := newClient(nil, userId, testClientConfig)
+	h.register(phone, nil)
+	h.register(laptop, nil)
+
+	h.subscribeUser(userId, chatId)
+	require.ElementsMatch(t, []*client{phone, laptop}, h.chatClients(chatId))
+
+	h.unsubscribe(phone, chatId)
+	require.Equal(t, []*client{laptop}, h.chatClients(chatId))
+
+	h.removeChat(chatId)
+	require.Empty(t, h.chatClients(chatId))
+	require.Empty(t, laptop.chats)
+}
@@ -2,21 +2,43 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"io"
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/pkg/cursor"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
+// replayBatchSize is how many missed messages are fetched per query on reconnect.
+const replayBatchSize = 100
+
 //go:generate mockery --name=MessageService --output=./mocks --case=underscore
 type MessageService interface {
 	Add(message domain.MessageAdd) (models.Message, error)
-	GetByChat(chatId uuid.UUID) ([]models.Message, error)
-	GetById(id uuid.UUID) (models.Message, error)
-	Update(message domain.MessageUpdate) error
-	Delete(id uuid.UUID) error
+	GetHistory(userId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error)
+	GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error)
+	GetThread(userId, rootId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error)
+	AddToThread(message domain.MessageAdd) (domain.ThreadReply, error)
+	Forward(userId uuid.UUID, forward domain.MessageForward) ([]models.Message, error)
+	GetById(userId, id uuid.UUID) (models.Message, error)
+	Update(userId uuid.UUID, message domain.MessageUpdate) error
+	Edit(userId uuid.UUID, edit domain.MessageEditPayload) (models.Message, error)
+	GetEdits(userId, id uuid.UUID) ([]models.MessageEdit, error)
+	Delete(userId, id uuid.UUID) (domain.DeletedMessage, error)
+	Hide(userId, id uuid.UUID) (models.Message, error)
+	AddReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error)
+	RemoveReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error)
+	MarkRead(userId, id uuid.UUID) (domain.ReadResult, error)
+	MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]domain.MessageStatus, error)
+	GetReceipts(userId, id uuid.UUID) ([]models.Receipt, error)
 }
 
 func (h *Handler) wsHandler(w http.ResponseWriter, r *http.Request) {
@@ -25,83 +47,196 @@ func (h *Handler) wsHandler(w http.ResponseWriter, r *http.Request) {
 		slog.String("op", op),
 	)
 
+	var since *cursor.Cursor
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		c, err := cursor.Decode(raw)
+		if err != nil {
+			log.Error("Error with parsing since cursor", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		since = &c
+	}
+
 	conn, err := h.wsUpg.Upgrade(w, r, nil)
 	if err != nil {
 		log.Error("upgrade websocket error", "error", err)
 		return
 	}
 
-	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	userID := currentUser(r)
+	chatIds, err := h.getUserChats(userID)
 	if err != nil {
-		log.Error("parse user id error", slog.String("err", err.Error()))
+		log.Error("get user chats error", slog.String("err", err.Error()))
 		_ = conn.Close()
 		return
 	}
 
-	chatIds, err := h.getUserChats(userID)
+	c := newClient(conn, userID, h.clientCfg)
+	c.device = r.URL.Query().Get("device")
+	if c.device == "" {
+		c.device = r.UserAgent()
+	}
+	c.remoteAddr = r.RemoteAddr
+	if sessionId := r.URL.Query().Get("session_id"); sessionId != "" {
+		c.sessionId, err = uuid.Parse(sessionId)
+		if err != nil {
+			log.Error("parse session id error", slog.String("err", err.Error()))
+			_ = conn.Close()
+			return
+		}
+	}
+
+	// Events published from now on are held back until the replay is done,
+	// so nothing falls between the replayed history and live delivery.
+	if since != nil {
+		c.startSync()
+	}
+
+	if replaced := h.hub.register(c, chatIds); replaced != nil {
+		log.Info("session replaced", slog.String("sessionId", c.sessionId.String()))
+		replaced.close()
+	}
+
+	go c.writePump()
+	go h.conn(c)
+	go h.heartbeat(c)
+	if since != nil {
+		go h.replay(c, *since)
+	}
+}
+
+// replay sends the messages the client missed after since, oldest first,
+// then a sync.done frame, and switches the client to live delivery. Live
+// messages that were already replayed are not sent twice.
+func (h *Handler) replay(c *client, since cursor.Cursor) {
+	const op = "handler.replay"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("sessionId", c.sessionId.String()),
+	)
+
+	replayed := make(map[uuid.UUID]struct{})
+	last, truncated := since, false
+	for {
+		limit := min(replayBatchSize, h.clientCfg.ReplayLimit-len(replayed))
+		if limit <= 0 {
+			truncated = true
+			break
+		}
+
+		messages, err := h.messageService.GetSince(c.userId, last, uint(limit))
+		if err != nil {
+			log.Error("Error with getting missed messages", slog.String("err", err.Error()))
+			c.closeWith(websocket.CloseInternalServerErr)
+			return
+		}
+
+		for _, msg := range messages {
+			payload := newMessagePayload(msg)
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Error("Error with encoding message", slog.String("err", err.Error()))
+				c.closeWith(websocket.CloseInternalServerErr)
+				return
+			}
+			eventType := domain.EventMessageNew
+			if msg.ThreadRootId != nil {
+				eventType = domain.EventThreadMessage
+			}
+			err = c.push(domain.Envelope{
+				Version: domain.ProtocolVersion,
+				Type:    eventType,
+				Payload: data,
+			})
+			if err != nil {
+				return
+			}
+			replayed[msg.Id] = struct{}{}
+			last = cursor.New(msg.SendingTime, msg.Id)
+		}
+
+		if len(messages) < limit {
+			break
+		}
+	}
+
+	data, _ := json.Marshal(domain.SyncPayload{
+		Cursor:    last.Encode(),
+		Count:     len(replayed),
+		Truncated: truncated,
+	})
+	err := c.push(domain.Envelope{
+		Version: domain.ProtocolVersion,
+		Type:    domain.EventSyncDone,
+		Payload: data,
+	})
 	if err != nil {
-		log.Error("get user chats error", slog.String("err", err.Error()))
-		_ = conn.Close()
 		return
 	}
 
-	h.mu.Lock()
-	for _, chatId := range chatIds {
-		if h.clients[chatId] == nil {
-			h.clients[chatId] = make(map[uuid.UUID]*websocket.Conn)
+	err = c.finishSync(func(envelope domain.Envelope) bool {
+		if envelope.Type != domain.EventMessageNew && envelope.Type != domain.EventThreadMessage {
+			return false
 		}
-		h.clients[chatId][userID] = conn
+		var msg domain.NewMessagePayload
+		if err := json.Unmarshal(envelope.Payload, &msg); err != nil {
+			return false
+		}
+		_, ok := replayed[msg.Id]
+		return ok
+	})
+	if err != nil {
+		return
 	}
-	h.mu.Unlock()
-
-	go h.conn(conn, userID)
+	log.Info("client caught up", slog.Int("replayed", len(replayed)), slog.Bool("truncated", truncated))
 }
 
-func (h *Handler) conn(conn *websocket.Conn, userId uuid.UUID) {
+func (h *Handler) conn(c *client) {
 	const op = "handler.conn"
 	log := h.log.With(
 		slog.String("op", op),
 	)
 
 	defer func() {
-		log.Info("User disconnection: ", slog.String("userId", userId.String()))
-		h.disconnection(conn, userId)
+		log.Info("User disconnection: ", slog.String("userId", c.userId.String()),
+			slog.String("sessionId", c.sessionId.String()))
+		h.disconnection(c)
 	}()
 
+	c.prepareRead()
 	for {
-		msg := new(domain.MessageAdd)
-		if err := conn.ReadJSON(msg); err != nil {
+		var envelope domain.Envelope
+		if err := c.conn.ReadJSON(&envelope); err != nil {
+			// A frame cut short, like a lone "{", ends the decoder with
+			// io.ErrUnexpectedEOF; a broken connection is a close error instead.
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+				h.writeError(c, "", newProtocolError(domain.ErrCodeBadRequest, "malformed envelope"))
+				continue
+			}
 			log.Error("Error with reading from WebSocket: ", slog.String("err", err.Error()))
 			break
 		}
 
-		addedMsg, err := h.messageService.Add(*msg)
-		if err != nil {
-			log.Error("Error with adding message to Messenger: ", slog.String("err", err.Error()))
-			continue
-		}
-		h.broadcast <- &addedMsg
+		h.dispatch(c, envelope)
 	}
 }
 
-func (h *Handler) disconnection(conn *websocket.Conn, userId uuid.UUID) {
+func (h *Handler) disconnection(c *client) {
 	const op = "handler.disconnection"
 	log := h.log.With(
 		slog.String("op", op),
 	)
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	for chatId, subscribers := range h.clients {
-		if _, ok := subscribers[userId]; ok {
-			delete(h.clients, chatId)
-			if len(h.clients) == 0 {
-				delete(h.clients, chatId)
-			}
+	h.hub.unregister(c)
+	c.close()
+	for _, chatId := range h.typing.stopSession(c) {
+		if err := h.publishTyping(chatId, c.userId, domain.EventTypingStop); err != nil {
+			log.Warn("Error with publishing typing stop", slog.String("err", err.Error()))
 		}
 	}
-	_ = conn.Close()
 	log.Info("close websocket connection")
 }
 
@@ -111,52 +246,584 @@ func (h *Handler) send(w http.ResponseWriter, r *http.Request) {
 		slog.String("op", op),
 	)
 
-	io, err := r.GetBody()
+	var message domain.MessageAdd
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&message)
 	if err != nil {
-		log.Error("Error with reading body", slog.String("err", err.Error()))
+		log.Error("Error with decoding body", slog.String("err", err.Error()))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	message.PersonId = currentUser(r)
 
-	var message domain.MessageAdd
-	decoder := json.NewDecoder(io)
-	err = decoder.Decode(&message)
-	if err != nil {
-		log.Error("Error with decoding body", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusBadRequest)
+	if message.ThreadRootId != nil {
+		if _, err = h.addToThread(message); err != nil {
+			log.Error("Error with adding message to thread: ", slog.String("err", err.Error()))
+			w.WriteHeader(statusFromError(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	msg, err := h.messageService.Add(message)
 	if err != nil {
 		log.Error("Error with adding message to Messenger: ", slog.String("err", err.Error()))
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(statusFromError(err))
 		return
 	}
 
-	h.broadcast <- &msg
+	if err = h.publish(msg.Chat.Id, domain.EventMessageNew, newMessagePayload(msg)); err != nil {
+		log.Error("Error with encoding message", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
 	log.Info("Success added message to Messenger")
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
+func (h *Handler) forward(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.forward"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	var forward domain.MessageForward
+	if err := json.NewDecoder(r.Body).Decode(&forward); err != nil {
+		log.Error("Error with decoding body", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	forwarded, err := h.messageService.Forward(currentUser(r), forward)
+	if err != nil {
+		log.Error("Error with forwarding messages", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	for _, msg := range forwarded {
+		if err = h.publish(msg.Chat.Id, domain.EventMessageNew, newMessagePayload(msg)); err != nil {
+			log.Error("Error with publishing message", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Info("messages forwarded", slog.Int("count", len(forwarded)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(forwarded); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) getHistory(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getHistory"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	chatId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing chatId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		log.Error("Error with parsing history query", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	query.ChatId = chatId
+
+	log.Info("getting chat history", slog.String("chatId", chatId.String()))
+	page, err := h.messageService.GetHistory(currentUser(r), query)
+	if err != nil {
+		log.Error("Error with getting chat history", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("got chat history", slog.Int("count", len(page.Messages)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(page); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) getThread(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getThread"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	rootId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		log.Error("Error with parsing history query", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Info("getting thread", slog.String("rootId", rootId.String()))
+	page, err := h.messageService.GetThread(currentUser(r), rootId, query)
+	if err != nil {
+		log.Error("Error with getting thread", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("got thread", slog.Int("count", len(page.Messages)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(page); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// parseHistoryQuery reads the before or after cursor and the page limit.
+func parseHistoryQuery(params url.Values) (domain.HistoryQuery, error) {
+	var query domain.HistoryQuery
+
+	before, after := params.Get("before"), params.Get("after")
+	if before != "" && after != "" {
+		return query, errors.New("both before and after cursors are set")
+	}
+
+	if raw := before + after; raw != "" {
+		c, err := cursor.Decode(raw)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = &c
+		query.After = after != ""
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit %q", raw)
+		}
+		query.Limit = uint(limit)
+	}
+	return query, nil
+}
+
+// addToThread posts the message in its thread, sends it to the thread
+// participants and the updated thread summary to the whole chat.
+func (h *Handler) addToThread(message domain.MessageAdd) (domain.NewMessagePayload, error) {
+	reply, err := h.messageService.AddToThread(message)
+	if err != nil {
+		return domain.NewMessagePayload{}, err
+	}
+
+	payload := newMessagePayload(reply.Message)
+	for _, participant := range reply.Participants {
+		err = h.publishToUser(participant, reply.Thread.ChatId, domain.EventThreadMessage, payload)
+		if err != nil {
+			return domain.NewMessagePayload{}, err
+		}
+	}
+
+	if err = h.publish(reply.Thread.ChatId, domain.EventThreadUpdated, reply.Thread); err != nil {
+		return domain.NewMessagePayload{}, err
+	}
+	return payload, nil
+}
+
+func (h *Handler) editMessage(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.editMessage"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var edit domain.MessageEditPayload
+	if err = json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		log.Error("Error with decoding body", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	edit.MessageId = id
+
+	msg, err := h.messageService.Edit(currentUser(r), edit)
+	if err != nil {
+		log.Error("Error with editing message", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	if err = h.publish(msg.Chat.Id, domain.EventMessageEdited, msg); err != nil {
+		log.Error("Error with publishing edit", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Info("message edited", slog.String("messageId", id.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(msg); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) getEdits(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getEdits"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	edits, err := h.messageService.GetEdits(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with getting message edits", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(edits); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) removeMessage(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.removeMessage"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	scope, ok := deleteScope(r.URL.Query().Get("scope"))
+	if !ok {
+		log.Error("Unknown delete scope")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.deleteMessage(currentUser(r), id, scope)
+	if err != nil {
+		log.Error("Error with deleting message", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("message deleted", slog.String("messageId", id.String()), slog.String("scope", scope))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(deleted); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) addReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.addReaction"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var reaction domain.ReactionPayload
+	if err = json.NewDecoder(r.Body).Decode(&reaction); err != nil {
+		log.Error("Error with decoding body", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	reaction.MessageId = id
+
+	h.writeReaction(w, log, currentUser(r), reaction, true)
+}
+
+func (h *Handler) removeReaction(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.removeReaction"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reaction := domain.ReactionPayload{
+		MessageId: id,
+		Emoji:     r.URL.Query().Get("emoji"),
+	}
+	h.writeReaction(w, log, currentUser(r), reaction, false)
+}
+
+func (h *Handler) writeReaction(w http.ResponseWriter, log *slog.Logger, userId uuid.UUID,
+	reaction domain.ReactionPayload, add bool) {
+	payload, err := h.react(userId, reaction, add)
+	if err != nil {
+		log.Error("Error with changing reaction", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(payload); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// react adds or removes the reaction and, if that changed anything, tells
+// the chat about it.
+func (h *Handler) react(userId uuid.UUID, reaction domain.ReactionPayload, add bool) (domain.ReactionsPayload, error) {
+	change := h.messageService.RemoveReaction
+	if add {
+		change = h.messageService.AddReaction
+	}
+
+	payload, changed, err := change(userId, reaction)
+	if err != nil {
+		return domain.ReactionsPayload{}, err
+	}
+
+	if changed {
+		if err = h.publish(payload.ChatId, domain.EventMessageReactions, payload); err != nil {
+			return domain.ReactionsPayload{}, err
+		}
+	}
+	return payload, nil
+}
+
+// deleteScope validates a delete scope; an empty scope means for everyone.
+func deleteScope(scope string) (string, bool) {
+	switch scope {
+	case "", domain.DeleteForEveryone:
+		return domain.DeleteForEveryone, true
+	case domain.DeleteForMe:
+		return domain.DeleteForMe, true
+	default:
+		return "", false
+	}
+}
+
+// deleteMessage deletes the message in the scope and notifies the chat, or
+// only the caller's sessions when the message is deleted just for them. A
+// thread reply deleted for everyone also sends the updated thread summary.
+func (h *Handler) deleteMessage(userId, id uuid.UUID, scope string) (domain.MessageDeletedPayload, error) {
+	if scope == domain.DeleteForMe {
+		msg, err := h.messageService.Hide(userId, id)
+		if err != nil {
+			return domain.MessageDeletedPayload{}, err
+		}
+
+		deleted := domain.MessageDeletedPayload{
+			MessageId: msg.Id,
+			ChatId:    msg.Chat.Id,
+			Scope:     scope,
+		}
+		if err = h.publishToUser(userId, msg.Chat.Id, domain.EventMessageDeleted, deleted); err != nil {
+			return domain.MessageDeletedPayload{}, err
+		}
+		return deleted, nil
+	}
+
+	tombstone, err := h.messageService.Delete(userId, id)
+	if err != nil {
+		return domain.MessageDeletedPayload{}, err
+	}
+
+	deleted := domain.MessageDeletedPayload{
+		MessageId: tombstone.Message.Id,
+		ChatId:    tombstone.Message.Chat.Id,
+		Scope:     scope,
+	}
+	if err = h.publish(deleted.ChatId, domain.EventMessageDeleted, deleted); err != nil {
+		return domain.MessageDeletedPayload{}, err
+	}
+	if tombstone.Thread != nil {
+		if err = h.publish(deleted.ChatId, domain.EventThreadUpdated, tombstone.Thread); err != nil {
+			return domain.MessageDeletedPayload{}, err
+		}
+	}
+	return deleted, nil
+}
+
+func (h *Handler) readMessages(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.readMessages"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	read, err := h.markRead(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with marking messages read", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(read); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// markRead moves the user's read pointer up to the message and, if it moved,
+// sends a read receipt to the chat and the new statuses to the senders.
+func (h *Handler) markRead(userId, id uuid.UUID) (domain.ReadPayload, error) {
+	result, err := h.messageService.MarkRead(userId, id)
+	if err != nil {
+		return domain.ReadPayload{}, err
+	}
+
+	if result.Advanced {
+		if err = h.publish(result.Receipt.ChatId, domain.EventRead, result.Receipt); err != nil {
+			return domain.ReadPayload{}, err
+		}
+	}
+
+	if err = h.publishStatuses(result.Statuses); err != nil {
+		return domain.ReadPayload{}, err
+	}
+	return result.Receipt, nil
+}
+
+// publishStatuses pushes each message status to the sessions of its sender.
+func (h *Handler) publishStatuses(statuses []domain.MessageStatus) error {
+	for _, status := range statuses {
+		if err := h.publishToUser(status.SenderId, status.ChatId, domain.EventMessageStatus, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) getReceipts(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getReceipts"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing messageId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	receipts, err := h.messageService.GetReceipts(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with getting receipts", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(receipts); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
 func (h *Handler) writeToClientsBroadcast() {
 	const op = "handler.writeToClientsBroadcast"
 	log := h.log.With(
 		slog.String("op", op),
 	)
 
-	for msg := range h.broadcast {
-		h.mu.RLock()
-		sub, ok := h.clients[msg.Chat.Id]
-		if ok {
-			for _, client := range sub {
-				if err := client.WriteJSON(msg); err != nil {
-					log.Warn("Error with adding message to Messenger: ", slog.String("err", err.Error()))
-				}
+	events, err := h.broker.Subscribe()
+	if err != nil {
+		log.Error("Error with subscribing to chat events: ", slog.String("err", err.Error()))
+		return
+	}
+
+	for event := range events {
+		if event.Membership != "" {
+			h.applyMembership(event)
+			continue
+		}
+
+		clients := h.hub.chatClients(event.ChatId)
+		if event.UserId != uuid.Nil {
+			clients = h.hub.userClients(event.UserId)
+		}
+
+		for _, client := range clients {
+			if event.SkipUserId != uuid.Nil && client.userId == event.SkipUserId {
+				continue
+			}
+			if err := client.deliver(event.Envelope); err != nil {
+				log.Warn("Error with adding message to Messenger: ", slog.String("err", err.Error()))
 			}
 		}
-		h.mu.RUnlock()
+	}
+}
+
+// applyMembership brings the local sessions in line with a membership change
+// made on any instance.
+func (h *Handler) applyMembership(event *domain.Event) {
+	switch event.Membership {
+	case domain.MembershipJoin:
+		h.hub.subscribeUser(event.UserId, event.ChatId)
+	case domain.MembershipLeave:
+		h.hub.unsubscribeUser(event.UserId, event.ChatId)
+	case domain.MembershipRemove:
+		h.hub.removeChat(event.ChatId)
+	}
+}
+
+func (h *Handler) getSessions(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getSessions"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	sessions := h.hub.userSessions(currentUser(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
 	}
 }
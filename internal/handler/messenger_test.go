@@ -2,18 +2,29 @@ package handler
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"io"
 	"log/slog"
+	"messenger/internal/app/wsserver"
+	"messenger/internal/auth"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
 	"messenger/internal/handler/mocks"
+	"messenger/internal/services/access"
+	"messenger/internal/services/chat"
+	"messenger/internal/services/file"
+	"messenger/internal/services/message"
+	"messenger/pkg/cursor"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -21,6 +32,42 @@ import (
 	"time"
 )
 
+var testClientConfig = wsserver.ClientConfig{
+	WriteWait:      time.Second,
+	PongWait:       time.Minute,
+	PingPeriod:     time.Minute,
+	MaxMessageSize: 1 << 16,
+	SendQueueSize:  16,
+	OverflowPolicy: wsserver.OverflowDropOldest,
+	ReplayLimit:    100,
+	TypingTimeout:  time.Second,
+	TypingRate:     10,
+	TypingBurst:    10,
+	PresenceTTL:    time.Minute,
+}
+
+// testPresence keeps every session silently online, for tests that are not about presence.
+func testPresence(t *testing.T) *mocks.PresenceService {
+	presence := mocks.NewPresenceService(t)
+	presence.On("Heartbeat", mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	presence.On("Disconnect", mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	return presence
+}
+
+func testAuthenticator(users ...uuid.UUID) *auth.Static {
+	tokens := make(map[string]uuid.UUID, len(users))
+	for _, id := range users {
+		tokens[id.String()] = id
+	}
+	return auth.NewStatic(tokens)
+}
+
+func authHeader(userId uuid.UUID) http.Header {
+	return http.Header{
+		"Authorization": []string{"Bearer " + userId.String()},
+	}
+}
+
 func TestWsConnection(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -38,14 +85,15 @@ func TestWsConnection(t *testing.T) {
 			wg.Done()
 		})
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
 	h.InitRoutes()
 
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws?user_id=%v", userId)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(userId))
 	require.NoError(t, err)
 	conn.Close()
 
@@ -81,23 +129,30 @@ func TestWsWrite_Success(t *testing.T) {
 		chatId,
 	}, nil)
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
 	h.InitRoutes()
 
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws?user_id=%v", person1)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
 	require.NoError(t, err)
 	defer conn.Close()
 
-	msg := domain.MessageAdd{
+	payload, err := json.Marshal(domain.MessageAdd{
 		PersonId: person1,
 		ChatId:   chatId,
 		Message:  textMsg,
-	}
-	err = conn.WriteJSON(msg)
+	})
+	require.NoError(t, err)
+
+	err = conn.WriteJSON(domain.Envelope{
+		Type:    domain.EventMessageSend,
+		Id:      "1",
+		Payload: payload,
+	})
 	require.NoError(t, err)
 
 	wg.Wait()
@@ -128,227 +183,2121 @@ func TestWsWriteReadForOneChat_Success(t *testing.T) {
 		chatId,
 	}, nil)
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), testClientConfig)
 	h.InitRoutes()
 
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws?user_id=%v", person1)
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
 	require.NoError(t, err)
 	defer conn.Close()
 
-	wsURL2 := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws?user_id=%v", person2)
-	conn2, _, err := websocket.DefaultDialer.Dial(wsURL2, nil)
+	wsURL2 := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn2, _, err := websocket.DefaultDialer.Dial(wsURL2, authHeader(person2))
 	require.NoError(t, err)
 	defer conn2.Close()
 
-	msg := domain.MessageAdd{
+	payload, err := json.Marshal(domain.MessageAdd{
 		PersonId: person1,
 		ChatId:   chatId,
 		Message:  textMsg,
-	}
-	err = conn.WriteJSON(msg)
+	})
 	require.NoError(t, err)
 
-	var readMsg models.Message
+	err = conn.WriteJSON(domain.Envelope{
+		Type:    domain.EventMessageSend,
+		Id:      "1",
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	var envelope domain.Envelope
 	_ = conn2.SetReadDeadline(time.Now().Add(time.Second * 5))
-	err = conn2.ReadJSON(&readMsg)
+	err = conn2.ReadJSON(&envelope)
+	require.NoError(t, err)
+	require.Equal(t, domain.EventMessageNew, envelope.Type)
+
+	var readMsg models.Message
+	err = json.Unmarshal(envelope.Payload, &readMsg)
 	require.NoError(t, err)
 	require.Equal(t, textMsg, readMsg.MessageText)
 }
 
-func TestWsGetInfoUserChats(t *testing.T) {
-	type args struct {
-		userId      uuid.UUID
-		page, count uint
-	}
-
+func TestWsDeleteScopes(t *testing.T) {
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
 
-	userId := uuid.New()
+	person1 := uuid.New()
+	person2 := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+	rootId := uuid.New()
+	msg := models.Message{
+		Id:       msgId,
+		PersonId: person2,
+		Chat: models.Chat{
+			Id: chatId,
+		},
+		ThreadRootId: &rootId,
+	}
+	// The deleted message was the only reply in its thread.
+	thread := domain.ThreadSummary{RootId: rootId, ChatId: chatId}
 
 	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("Hide", person1, msgId).Return(msg, nil).Once()
+	mockMessengerService.On("Delete", person2, msgId).Return(domain.DeletedMessage{Message: msg, Thread: &thread}, nil).Once()
+
 	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{
+		chatId,
+	}, nil)
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), testClientConfig)
 	h.InitRoutes()
 
-	cases := []struct {
-		name            string
-		input           args
-		mockChatsReturn []domain.GetChat
-		mockChatsError  error
-		expectedChats   []domain.GetChat
-		expectedStatus  int
-	}{
-		{
-			name: "Возвращение одного чата",
-			input: args{
-				userId: userId,
-				page:   1,
-				count:  1,
-			},
-			mockChatsReturn: []domain.GetChat{
-				{
-					Name: "34 сквад",
-					LastMessage: models.Message{
-						PersonId:    userId,
-						MessageText: "Тест",
-					},
-				},
-			},
-			mockChatsError: nil,
-			expectedChats: []domain.GetChat{
-				{
-					Name: "34 сквад",
-					LastMessage: models.Message{
-						PersonId:    userId,
-						MessageText: "Тест",
-					},
-				},
-			},
-			expectedStatus: http.StatusOK,
-		},
-	}
-
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	for _, tt := range cases {
-		t.Run(tt.name, func(t *testing.T) {
-			mockChatService.On("GetInfoUserChats", tt.input.userId, tt.input.page, tt.input.count).Return(tt.mockChatsReturn, tt.mockChatsError)
-			url := fmt.Sprintf("%s/chat/info?userId=%v&page=%d&count=%d", server.URL, tt.input.userId, tt.input.page, tt.input.count)
-			resp, err := http.Get(url)
-			require.NoError(t, err)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn1, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn1.Close()
 
-			defer resp.Body.Close()
-			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+	conn2, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn2.Close()
 
-			var chats []domain.GetChat
-			decoder := json.NewDecoder(resp.Body)
-			err = decoder.Decode(&chats)
-			if err != nil {
-				t.Error(err)
-			}
+	conn3, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer conn3.Close()
 
-			require.Equal(t, tt.expectedChats, chats)
-		})
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(chatId)) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	readDeleted := func(conn *websocket.Conn) domain.MessageDeletedPayload {
+		var envelope domain.Envelope
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, conn.ReadJSON(&envelope))
+		require.Equal(t, domain.EventMessageDeleted, envelope.Type)
+
+		var deleted domain.MessageDeletedPayload
+		require.NoError(t, json.Unmarshal(envelope.Payload, &deleted))
+		return deleted
 	}
+
+	payload, err := json.Marshal(domain.MessageDeletePayload{
+		MessageId: msgId,
+		Scope:     domain.DeleteForMe,
+	})
+	require.NoError(t, err)
+
+	err = conn1.WriteJSON(domain.Envelope{
+		Type:    domain.EventMessageDelete,
+		Id:      "1",
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	// The other session of the same user learns about the hidden message.
+	require.Equal(t, domain.DeleteForMe, readDeleted(conn2).Scope)
+
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/messages/%v?scope=everyone", server.URL, msgId), nil)
+	require.NoError(t, err)
+	req.Header = authHeader(person2)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The other member never saw the delete for me, only the tombstone.
+	deleted := readDeleted(conn3)
+	require.Equal(t, domain.DeleteForEveryone, deleted.Scope)
+	require.Equal(t, msgId, deleted.MessageId)
+
+	var envelope domain.Envelope
+	_ = conn3.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, conn3.ReadJSON(&envelope))
+	require.Equal(t, domain.EventThreadUpdated, envelope.Type)
+
+	var got domain.ThreadSummary
+	require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+	require.Equal(t, thread, got)
 }
 
-func TestWsUpdate(t *testing.T) {
-	type args struct {
-		chat models.Chat
+func TestWsDelivered(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	sender := uuid.New()
+	recipient := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+
+	status := domain.MessageStatus{
+		MessageId:  msgId,
+		ChatId:     chatId,
+		SenderId:   sender,
+		Recipients: 1,
+		Delivered:  1,
+		Status:     domain.DeliveryDelivered,
 	}
 
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("MarkDelivered", recipient, []uuid.UUID{msgId}).
+		Return([]domain.MessageStatus{status}, nil).Once()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(sender, recipient), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	senderConn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(sender))
+	require.NoError(t, err)
+	defer senderConn.Close()
+
+	recipientConn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(recipient))
+	require.NoError(t, err)
+	defer recipientConn.Close()
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.userSessions(sender)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	payload, err := json.Marshal(domain.DeliveredPayload{
+		MessageIds: []uuid.UUID{msgId},
+	})
+	require.NoError(t, err)
+
+	err = recipientConn.WriteJSON(domain.Envelope{
+		Type:    domain.EventDelivered,
+		Id:      "1",
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	var envelope domain.Envelope
+	_ = recipientConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, recipientConn.ReadJSON(&envelope))
+	require.Equal(t, domain.EventAck, envelope.Type)
+
+	_ = senderConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, senderConn.ReadJSON(&envelope))
+	require.Equal(t, domain.EventMessageStatus, envelope.Type)
+
+	var got domain.MessageStatus
+	require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+	require.Equal(t, status, got)
+}
+
+func TestWsTyping(t *testing.T) {
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
 
+	person1 := uuid.New()
+	person2 := uuid.New()
+	chatId := uuid.New()
+
 	mockMessengerService := mocks.NewMessageService(t)
 	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{chatId}, nil)
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
-	h.InitRoutes()
+	cfg := testClientConfig
+	cfg.TypingTimeout = 200 * time.Millisecond
+	cfg.TypingRate = 1
+	cfg.TypingBurst = 4
 
-	cases := []struct {
-		name           string
-		input          args
-		mockChatsError error
-		expectedStatus int
-	}{
-		{
-			name: "Успешное обновление чата",
-			input: args{
-				chat: models.Chat{
-					Id:   uuid.New(),
-					Name: "Test",
-				},
-			},
-			mockChatsError: nil,
-			expectedStatus: http.StatusOK,
-		},
-	}
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), cfg)
+	h.InitRoutes()
 
 	server := httptest.NewServer(h)
 	defer server.Close()
 
-	for _, tt := range cases {
-		t.Run(tt.name, func(t *testing.T) {
-			mockChatService.On("Update", mock.AnythingOfType("models.Chat")).Return(tt.mockChatsError)
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	typistConn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer typistConn.Close()
 
-			body, err := json.Marshal(tt.input.chat)
-			if err != nil {
-				t.Error(err)
-			}
+	readerConn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer readerConn.Close()
 
-			url := fmt.Sprintf("%s/chat", server.URL)
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(chatId)) == 2
+	}, time.Second, 10*time.Millisecond)
 
-			req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
-			if err != nil {
-				t.Error(err)
-			}
+	payload, err := json.Marshal(domain.TypingPayload{ChatId: chatId})
+	require.NoError(t, err)
 
-			resp, err := http.DefaultClient.Do(req)
-			require.NoError(t, err)
-			require.Equal(t, tt.expectedStatus, resp.StatusCode)
-		})
+	send := func(id, eventType string) domain.Envelope {
+		err := typistConn.WriteJSON(domain.Envelope{Type: eventType, Id: id, Payload: payload})
+		require.NoError(t, err)
+
+		var envelope domain.Envelope
+		_ = typistConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, typistConn.ReadJSON(&envelope))
+		require.Equal(t, id, envelope.Id)
+		return envelope
 	}
-}
+	receive := func(eventType string) {
+		var envelope domain.Envelope
+		_ = readerConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, readerConn.ReadJSON(&envelope))
+		require.Equal(t, eventType, envelope.Type)
 
-func TestWsDelete(t *testing.T) {
-	type args struct {
-		chatId uuid.UUID
-		userId uuid.UUID
+		var typing domain.TypingPayload
+		require.NoError(t, json.Unmarshal(envelope.Payload, &typing))
+		require.Equal(t, domain.TypingPayload{ChatId: chatId, PersonId: person1}, typing)
 	}
 
+	// A refresh is acknowledged but not fanned out again.
+	require.Equal(t, domain.EventAck, send("1", domain.EventTypingStart).Type)
+	require.Equal(t, domain.EventAck, send("2", domain.EventTypingStart).Type)
+	require.Equal(t, domain.EventAck, send("3", domain.EventTypingStop).Type)
+	receive(domain.EventTypingStart)
+	receive(domain.EventTypingStop)
+
+	// The server stops an indicator that is not refreshed.
+	require.Equal(t, domain.EventAck, send("4", domain.EventTypingStart).Type)
+	receive(domain.EventTypingStart)
+	receive(domain.EventTypingStop)
+
+	envelope := send("5", domain.EventTypingStart)
+	require.Equal(t, domain.EventError, envelope.Type)
+
+	var errPayload domain.ErrorPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &errPayload))
+	require.Equal(t, domain.ErrCodeRateLimited, errPayload.Code)
+}
+
+func TestWsPresence(t *testing.T) {
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
 
+	person1 := uuid.New()
+	person2 := uuid.New()
+	stranger := uuid.New()
+	lastSeen := time.Now().UTC().Truncate(time.Millisecond)
+	online := domain.Presence{UserId: person1, Online: true, LastSeen: &lastSeen}
+
 	mockMessengerService := mocks.NewMessageService(t)
 	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{}, nil)
+
+	mockPresence := mocks.NewPresenceService(t)
+	mockPresence.On("Heartbeat", person2, mock.Anything).Return(false, nil).Maybe()
+	mockPresence.On("Heartbeat", person1, mock.Anything).Return(true, nil).Once()
+	mockPresence.On("Disconnect", mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	mockPresence.On("Get", []uuid.UUID{person1}).Return([]domain.Presence{online}, nil)
+	mockPresence.On("GetContacts", person1).Return([]uuid.UUID{person2}, nil).Once()
+	mockPresence.On("GetContacts", person2).Return([]uuid.UUID{person1}, nil).Once()
+	mockPresence.On("GetContacts", stranger).Return([]uuid.UUID{}, nil).Once()
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, mockPresence, nil, NewLocalBroker(),
+		testAuthenticator(person1, person2, stranger), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	contactConn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer contactConn.Close()
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.userSessions(person2)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var envelope domain.Envelope
+	_ = contactConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, contactConn.ReadJSON(&envelope))
+	require.Equal(t, domain.EventPresence, envelope.Type)
+
+	var got domain.Presence
+	require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+	require.Equal(t, online, got)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+person1.String()+"/presence", nil)
+	req.Header = authHeader(person2)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	got = domain.Presence{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Equal(t, online, got)
+
+	req = httptest.NewRequest(http.MethodGet, "/users/"+person1.String()+"/presence", nil)
+	req.Header = authHeader(stranger)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetPresences(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	person2 := uuid.New()
+	stranger := uuid.New()
+	presences := []domain.Presence{{UserId: person1, Online: true}, {UserId: person2}}
 
-	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService)
+	mockPresence := mocks.NewPresenceService(t)
+	mockPresence.On("GetContacts", person1).Return([]uuid.UUID{person2}, nil)
+	mockPresence.On("Get", []uuid.UUID{person1, person2}).Return(presences, nil).Once()
+	mockPresence.On("Get", []uuid.UUID{person1}).Return(presences[:1], nil).Once()
+
+	h := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mocks.NewChatService(t), mockPresence, nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
 	h.InitRoutes()
 
 	cases := []struct {
-		name           string
-		input          args
-		mockChatsError error
-		expectedStatus int
+		name         string
+		ids          string
+		expectedCode int
+		expected     []domain.Presence
 	}{
 		{
-			name: "Успешное удаление",
-			input: args{
-				chatId: uuid.New(),
-				userId: uuid.New(),
-			},
-			mockChatsError: nil,
-			expectedStatus: http.StatusOK,
+			name:         "Несколько пользователей",
+			ids:          person1.String() + "," + person2.String(),
+			expectedCode: http.StatusOK,
+			expected:     presences,
+		},
+		{
+			name:         "Пользователь без общих чатов",
+			ids:          person1.String() + "," + stranger.String(),
+			expectedCode: http.StatusOK,
+			expected:     presences[:1],
+		},
+		{
+			name:         "Только пользователи без общих чатов",
+			ids:          stranger.String(),
+			expectedCode: http.StatusOK,
+			expected:     []domain.Presence{},
+		},
+		{
+			name:         "Неверный идентификатор",
+			ids:          person1.String() + ",bad",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Слишком много пользователей",
+			ids:          strings.Repeat(person1.String()+",", maxPresenceBatch) + person1.String(),
+			expectedCode: http.StatusBadRequest,
 		},
 	}
 
-	server := httptest.NewServer(h)
-	defer server.Close()
-
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			mockChatService.On("Delete", mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("uuid.UUID")).Return(tt.mockChatsError)
+			req := httptest.NewRequest(http.MethodGet, "/users/presence?ids="+tt.ids, nil)
+			req.Header = authHeader(person1)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
 
-			url := fmt.Sprintf("%s/chat?chatId=%v&userId=%v", server.URL, tt.input.chatId, tt.input.userId)
-			req, err := http.NewRequest("DELETE", url, nil)
-			if err != nil {
-				t.Error(err)
+			if tt.expectedCode == http.StatusOK {
+				var got []domain.Presence
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				require.Equal(t, tt.expected, got)
 			}
+		})
+	}
+}
+
+func TestWsThreadMessage(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	author := uuid.New()
+	follower := uuid.New()
+	bystander := uuid.New()
+	chatId := uuid.New()
+	rootId := uuid.New()
+	lastReplyAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	add := domain.MessageAdd{
+		PersonId:     author,
+		ChatId:       chatId,
+		Message:      "in thread",
+		ThreadRootId: &rootId,
+	}
+	reply := domain.ThreadReply{
+		Message: models.Message{
+			Id:           uuid.New(),
+			PersonId:     author,
+			Chat:         models.Chat{Id: chatId},
+			MessageText:  "in thread",
+			SendingTime:  time.Now().UTC(),
+			ThreadRootId: &rootId,
+		},
+		Thread: domain.ThreadSummary{
+			RootId:      rootId,
+			ChatId:      chatId,
+			ReplyCount:  3,
+			LastReplyAt: &lastReplyAt,
+		},
+		Participants: []uuid.UUID{author, follower},
+	}
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("AddToThread", add).Return(reply, nil).Once()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{chatId}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(author, follower, bystander), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conns := make(map[uuid.UUID]*websocket.Conn)
+	for _, user := range []uuid.UUID{author, follower, bystander} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(user))
+		require.NoError(t, err)
+		defer conn.Close()
+		conns[user] = conn
+	}
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(chatId)) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	payload, err := json.Marshal(add)
+	require.NoError(t, err)
+	err = conns[author].WriteJSON(domain.Envelope{Type: domain.EventMessageSend, Id: "1", Payload: payload})
+	require.NoError(t, err)
+
+	read := func(user uuid.UUID) domain.Envelope {
+		var envelope domain.Envelope
+		_ = conns[user].SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, conns[user].ReadJSON(&envelope))
+		return envelope
+	}
+
+	types := func(user uuid.UUID, n int) []string {
+		var got []string
+		for range n {
+			got = append(got, read(user).Type)
+		}
+		return got
+	}
+
+	require.ElementsMatch(t, []string{domain.EventAck, domain.EventThreadMessage, domain.EventThreadUpdated},
+		types(author, 3))
+	require.ElementsMatch(t, []string{domain.EventThreadMessage, domain.EventThreadUpdated}, types(follower, 2))
+
+	envelope := read(bystander)
+	require.Equal(t, domain.EventThreadUpdated, envelope.Type)
+
+	var summary domain.ThreadSummary
+	require.NoError(t, json.Unmarshal(envelope.Payload, &summary))
+	require.Equal(t, reply.Thread, summary)
+}
+
+func TestWsReactions(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	person2 := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+
+	reaction := domain.ReactionPayload{MessageId: msgId, Emoji: "🔥"}
+	added := domain.ReactionsPayload{
+		MessageId: msgId,
+		ChatId:    chatId,
+		PersonId:  person1,
+		Emoji:     "🔥",
+		Added:     true,
+		Reactions: models.Reactions{{Emoji: "🔥", Count: 1}},
+	}
+	removed := added
+	removed.Added = false
+	removed.Reactions = nil
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("AddReaction", person1, reaction).Return(added, true, nil).Once()
+	mockMessengerService.On("AddReaction", person1, reaction).Return(added, false, nil).Once()
+	mockMessengerService.On("RemoveReaction", person1, reaction).Return(removed, true, nil).Once()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{chatId}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn1, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn1.Close()
+
+	conn2, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(chatId)) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	payload, err := json.Marshal(reaction)
+	require.NoError(t, err)
+
+	// The second add changes nothing, so only the first one is broadcast.
+	for _, id := range []string{"1", "2"} {
+		err = conn1.WriteJSON(domain.Envelope{Type: domain.EventReactionAdd, Id: id, Payload: payload})
+		require.NoError(t, err)
+
+		for {
+			var envelope domain.Envelope
+			_ = conn1.SetReadDeadline(time.Now().Add(time.Second * 5))
+			require.NoError(t, conn1.ReadJSON(&envelope))
+			if envelope.Type == domain.EventAck && envelope.Id == id {
+				break
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/messages/"+msgId.String()+"/reactions?emoji="+
+		url.QueryEscape("🔥"), nil)
+	req.Header = authHeader(person1)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	for _, expected := range []domain.ReactionsPayload{added, removed} {
+		var envelope domain.Envelope
+		_ = conn2.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, conn2.ReadJSON(&envelope))
+		require.Equal(t, domain.EventMessageReactions, envelope.Type)
+
+		var got domain.ReactionsPayload
+		require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+		require.Equal(t, expected, got)
+	}
+}
+
+func TestPins(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	person2 := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+
+	pinned := domain.PinPayload{ChatId: chatId, MessageId: msgId, PersonId: person1, Pinned: true}
+	unpinned := pinned
+	unpinned.Pinned = false
+	pins := []models.Pin{{Message: models.Message{Id: msgId, Chat: models.Chat{Id: chatId}}, PinnedBy: person1,
+		PinnedAt: time.Now().UTC().Truncate(time.Microsecond)}}
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{chatId}, nil)
+	mockChatService.On("Pin", person1, chatId, msgId).Return(pinned, true, nil).Once()
+	mockChatService.On("Pin", person1, chatId, msgId).Return(pinned, false, nil).Once()
+	mockChatService.On("Unpin", person1, chatId, msgId).Return(unpinned, true, nil).Once()
+	mockChatService.On("GetPins", person2, chatId).Return(pins, nil).Once()
+
+	h := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(chatId)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The second pin changes nothing, so only the first one is broadcast.
+	path := "/chat/" + chatId.String() + "/pins/" + msgId.String()
+	for _, method := range []string{http.MethodPost, http.MethodPost, http.MethodDelete} {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header = authHeader(person1)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	for _, expected := range []struct {
+		event   string
+		payload domain.PinPayload
+	}{
+		{domain.EventMessagePinned, pinned},
+		{domain.EventMessageUnpinned, unpinned},
+	} {
+		var envelope domain.Envelope
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, conn.ReadJSON(&envelope))
+		require.Equal(t, expected.event, envelope.Type)
+
+		var got domain.PinPayload
+		require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+		require.Equal(t, expected.payload, got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/chat/"+chatId.String()+"/pins", nil)
+	req.Header = authHeader(person2)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []models.Pin
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	require.Equal(t, msgId, got[0].Id)
+	require.True(t, pins[0].PinnedAt.Equal(got[0].PinnedAt))
+}
+
+func TestForward(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	person2 := uuid.New()
+	sourceId := uuid.New()
+	targetId := uuid.New()
+
+	forward := domain.MessageForward{MessageIds: []uuid.UUID{uuid.New()}, ChatIds: []uuid.UUID{targetId}}
+	origin := uuid.New()
+	sent := time.Now().UTC().Truncate(time.Microsecond)
+	forwarded := models.Message{
+		Id:                uuid.New(),
+		PersonId:          person1,
+		Chat:              models.Chat{Id: targetId},
+		MessageText:       "hello",
+		SendingTime:       sent,
+		ForwardedFrom:     &origin,
+		ForwardedFromChat: &sourceId,
+		ForwardedTime:     &sent,
+	}
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("Forward", person1, forward).Return([]models.Message{forwarded}, nil).Once()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", person2).Return([]uuid.UUID{targetId}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1, person2), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person2))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.chatClients(targetId)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	body, err := json.Marshal(forward)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/messages/forward", bytes.NewReader(body))
+	req.Header = authHeader(person1)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var envelope domain.Envelope
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, conn.ReadJSON(&envelope))
+	require.Equal(t, domain.EventMessageNew, envelope.Type)
+
+	var got domain.NewMessagePayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &got))
+	require.Equal(t, forwarded.Id, got.Id)
+	require.Equal(t, &origin, got.ForwardedFrom)
+	require.Equal(t, &sourceId, got.ForwardedFromChat)
+}
+
+func TestFiles(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	owner := uuid.New()
+	stranger := uuid.New()
+	uploaded := models.File{
+		Id:         uuid.New(),
+		Name:       "отчёт.txt",
+		MimeType:   "text/plain; charset=utf-8",
+		Size:       5,
+		UploadedBy: owner,
+	}
+
+	mockFileService := mocks.NewFileService(t)
+	mockFileService.On("Upload", owner, "отчёт.txt", mock.Anything).Return(uploaded, nil).Once()
+	mockFileService.On("Open", owner, uploaded.Id).Return(uploaded, io.NopCloser(strings.NewReader("hello")), nil).Once()
+	mockFileService.On("Open", stranger, uploaded.Id).Return(models.File{}, nil, file.ErrNotFound).Once()
+	mockFileService.On("OpenThumbnail", owner, uploaded.Id, 160).
+		Return(models.Thumbnail{Size: 160, Width: 160, Height: 90}, io.NopCloser(strings.NewReader("jpeg")), nil).Once()
+	mockFileService.On("OpenThumbnail", owner, uploaded.Id, 480).Return(models.Thumbnail{}, nil, file.ErrNotFound).Once()
+
+	h := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mocks.NewChatService(t), testPresence(t),
+		mockFileService, NewLocalBroker(), testAuthenticator(owner, stranger), testClientConfig)
+	h.InitRoutes()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("comment", "ignored"))
+	part, err := form.CreateFormFile("file", "отчёт.txt")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/files", &body)
+	req.Header = authHeader(owner)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var got models.File
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, uploaded.Id, got.Id)
+
+	req = httptest.NewRequest(http.MethodGet, "/files/"+uploaded.Id.String(), nil)
+	req.Header = authHeader(owner)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "hello", rec.Body.String())
+	require.Equal(t, uploaded.MimeType, rec.Header().Get("Content-Type"))
+	require.Equal(t, "attachment; filename*=utf-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.txt",
+		rec.Header().Get("Content-Disposition"))
+
+	req = httptest.NewRequest(http.MethodGet, "/files/"+uploaded.Id.String(), nil)
+	req.Header = authHeader(stranger)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	for size, status := range map[string]int{"160": http.StatusOK, "480": http.StatusNotFound, "big": http.StatusBadRequest} {
+		req = httptest.NewRequest(http.MethodGet, "/files/"+uploaded.Id.String()+"/thumbnails/"+size, nil)
+		req.Header = authHeader(owner)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, status, rec.Code)
+		if status == http.StatusOK {
+			require.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+			require.Equal(t, "jpeg", rec.Body.String())
+		}
+	}
+}
+
+func TestUploads(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	owner := uuid.New()
+	upload := models.Upload{Id: uuid.New(), PersonId: owner, Name: "видео.mp4", Length: 10}
+	uploaded := models.File{Id: uuid.New(), Name: upload.Name, MimeType: "video/mp4", Size: 10, UploadedBy: owner}
+
+	mockFileService := mocks.NewFileService(t)
+	mockFileService.On("CreateUpload", owner, "видео.mp4", int64(10)).Return(upload, nil).Once()
+	mockFileService.On("CreateUpload", owner, "видео.mp4", int64(0)).Return(models.Upload{}, file.ErrInvalidLength).Once()
+	mockFileService.On("AppendChunk", owner, upload.Id, int64(0), mock.Anything).Run(func(args mock.Arguments) {
+		data, err := io.ReadAll(args.Get(3).(io.Reader))
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(data))
+	}).Return(int64(5), nil).Once()
+	mockFileService.On("AppendChunk", owner, upload.Id, int64(0), mock.Anything).
+		Return(int64(0), file.ErrOffsetMismatch).Once()
+	upload.Offset = 5
+	mockFileService.On("GetUpload", owner, upload.Id).Return(upload, nil).Once()
+	mockFileService.On("FinishUpload", owner, upload.Id).Return(models.File{}, file.ErrUploadIncomplete).Once()
+	mockFileService.On("FinishUpload", owner, upload.Id).Return(uploaded, nil).Once()
+	mockFileService.On("CancelUpload", owner, upload.Id).Return(file.ErrNotFound).Once()
+
+	h := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mocks.NewChatService(t), testPresence(t),
+		mockFileService, NewLocalBroker(), testAuthenticator(owner), testClientConfig)
+	h.InitRoutes()
+
+	do := func(method, target, contentType, offset string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, body)
+		req.Header = authHeader(owner)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if offset != "" {
+			req.Header.Set("Upload-Offset", offset)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	req.Header = authHeader(owner)
+	req.Header.Set("Upload-Length", "10")
+	req.Header.Set("Upload-Metadata", "type dmlkZW8=, filename "+base64.StdEncoding.EncodeToString([]byte("видео.mp4")))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	location := rec.Header().Get("Location")
+	require.Equal(t, "/uploads/"+upload.Id.String(), location)
+	require.Equal(t, "0", rec.Header().Get("Upload-Offset"))
+
+	req = httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	req.Header = authHeader(owner)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	req.Header = authHeader(owner)
+	req.Header.Set("Upload-Length", "0")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("видео.mp4")))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(http.MethodPatch, location, "text/plain", "0", strings.NewReader("hello"))
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec = do(http.MethodPatch, location, offsetContentType, "", strings.NewReader("hello"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(http.MethodPatch, location, offsetContentType, "0", strings.NewReader("hello"))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "5", rec.Header().Get("Upload-Offset"))
+
+	rec = do(http.MethodPatch, location, offsetContentType, "0", strings.NewReader("hello"))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodHead, location, "", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "5", rec.Header().Get("Upload-Offset"))
+	require.Equal(t, "10", rec.Header().Get("Upload-Length"))
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	rec = do(http.MethodPost, location+"/finish", "", "", nil)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodPost, location+"/finish", "", "", nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var got models.File
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, uploaded.Id, got.Id)
+
+	rec = do(http.MethodDelete, location, "", "", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestWsReplayOnReconnect(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	chatId := uuid.New()
+	since := cursor.New(time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond), uuid.New())
+
+	newMessage := func(text string, offset time.Duration) models.Message {
+		return models.Message{
+			Id:          uuid.New(),
+			MessageText: text,
+			PersonId:    uuid.New(),
+			Chat:        models.Chat{Id: chatId},
+			SendingTime: since.Time.Add(offset),
+		}
+	}
+	missed1 := newMessage("missed 1", time.Minute)
+	missed2 := newMessage("missed 2", 2*time.Minute)
+	live := newMessage("live", 3*time.Minute)
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", person1).Return([]uuid.UUID{chatId}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
+	h.InitRoutes()
+
+	// While the backlog is being read, missed2 and live are published; missed2
+	// must not be delivered twice and live must come after the replay.
+	mockMessengerService.On("GetSince", person1, since, uint(replayBatchSize)).
+		Run(func(mock.Arguments) {
+			require.NoError(t, h.publish(chatId, domain.EventMessageNew, newMessagePayload(missed2)))
+			require.NoError(t, h.publish(chatId, domain.EventMessageNew, newMessagePayload(live)))
+			require.Eventually(t, func() bool {
+				clients := h.hub.userClients(person1)
+				if len(clients) != 1 {
+					return false
+				}
+				clients[0].mu.Lock()
+				defer clients[0].mu.Unlock()
+				return len(clients[0].pending) == 2
+			}, time.Second, 10*time.Millisecond)
+		}).
+		Return([]models.Message{missed1, missed2}, nil).Once()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?since=bad", authHeader(person1))
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?since="+since.Encode(), authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readMessage := func() domain.NewMessagePayload {
+		var envelope domain.Envelope
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		require.NoError(t, conn.ReadJSON(&envelope))
+		require.Equal(t, domain.EventMessageNew, envelope.Type)
+
+		var msg domain.NewMessagePayload
+		require.NoError(t, json.Unmarshal(envelope.Payload, &msg))
+		return msg
+	}
+
+	require.Equal(t, missed1.Id, readMessage().Id)
+	got := readMessage()
+	require.Equal(t, missed2.Id, got.Id)
+	require.Equal(t, cursor.New(missed2.SendingTime, missed2.Id).Encode(), got.Cursor)
+
+	var envelope domain.Envelope
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	require.NoError(t, conn.ReadJSON(&envelope))
+	require.Equal(t, domain.EventSyncDone, envelope.Type)
+
+	var sync domain.SyncPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &sync))
+	require.Equal(t, domain.SyncPayload{Cursor: got.Cursor, Count: 2}, sync)
+
+	require.Equal(t, live.Id, readMessage().Id)
+}
+
+func TestWsEnvelope_AckAndErrors(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("Add", mock.MatchedBy(func(add domain.MessageAdd) bool {
+		return len(add.Files) > 0
+	})).Return(models.Message{}, fmt.Errorf("services.messenger.Add: %w", message.ErrInvalidAttachment))
+	mockMessengerService.On("Add", mock.AnythingOfType("domain.MessageAdd")).Return(models.Message{
+		Id:       msgId,
+		PersonId: person1,
+		Chat: models.Chat{
+			Id: chatId,
+		},
+	}, nil)
+
+	foreignMsgId := uuid.New()
+	mockMessengerService.On("Delete", person1, foreignMsgId).
+		Return(domain.DeletedMessage{}, fmt.Errorf("services.messenger.Delete: %w", access.ErrForbidden))
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	payload, err := json.Marshal(domain.MessageAdd{
+		PersonId: person1,
+		ChatId:   chatId,
+		Message:  "Hello tests",
+	})
+	require.NoError(t, err)
+
+	foreignFilePayload, err := json.Marshal(domain.MessageAdd{
+		PersonId: person1,
+		ChatId:   chatId,
+		Message:  "Hello tests",
+		Files:    []uuid.UUID{uuid.New()},
+	})
+	require.NoError(t, err)
+
+	deletePayload, err := json.Marshal(domain.MessageDeletePayload{
+		MessageId: foreignMsgId,
+	})
+	require.NoError(t, err)
+
+	badScopePayload, err := json.Marshal(domain.MessageDeletePayload{
+		MessageId: foreignMsgId,
+		Scope:     "nobody",
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name         string
+		request      domain.Envelope
+		expectedType string
+		expectedCode string
+	}{
+		{
+			name:         "Подтверждение отправки сообщения",
+			request:      domain.Envelope{Type: domain.EventMessageSend, Id: "send-1", Payload: payload},
+			expectedType: domain.EventAck,
+		},
+		{
+			name:         "Отправка сообщения с чужим файлом",
+			request:      domain.Envelope{Type: domain.EventMessageSend, Id: "send-2", Payload: foreignFilePayload},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeBadRequest,
+		},
+		{
+			name:         "Ответ на ping",
+			request:      domain.Envelope{Type: domain.EventPing, Id: "ping-1"},
+			expectedType: domain.EventPong,
+		},
+		{
+			name:         "Неизвестный тип события",
+			request:      domain.Envelope{Type: "unknown", Id: "unknown-1"},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeUnknownType,
+		},
+		{
+			name:         "Неподдерживаемая версия протокола",
+			request:      domain.Envelope{Version: 42, Type: domain.EventPing, Id: "version-1"},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeUnsupportedVersion,
+		},
+		{
+			name:         "Удаление сообщения из чужого чата",
+			request:      domain.Envelope{Type: domain.EventMessageDelete, Id: "delete-1", Payload: deletePayload},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeForbidden,
+		},
+		{
+			name:         "Неизвестная область удаления",
+			request:      domain.Envelope{Type: domain.EventMessageDelete, Id: "delete-2", Payload: badScopePayload},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeBadRequest,
+		},
+		{
+			name:         "Отсутствует payload",
+			request:      domain.Envelope{Type: domain.EventMessageEdit, Id: "edit-1"},
+			expectedType: domain.EventError,
+			expectedCode: domain.ErrCodeBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err = conn.WriteJSON(tt.request)
+			require.NoError(t, err)
+
+			var envelope domain.Envelope
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+			err = conn.ReadJSON(&envelope)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedType, envelope.Type)
+			require.Equal(t, tt.request.Id, envelope.Id)
+
+			if tt.expectedCode != "" {
+				var errPayload domain.ErrorPayload
+				err = json.Unmarshal(envelope.Payload, &errPayload)
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedCode, errPayload.Code)
+			}
+		})
+	}
+}
+
+func TestWsEnvelope_MalformedFrames(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{}, nil)
+
+	h := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cases := []struct {
+		name  string
+		frame string
+	}{
+		{
+			name:  "Незакрытый объект",
+			frame: "{",
+		},
+		{
+			name:  "Пустой кадр",
+			frame: "",
+		},
+		{
+			name:  "Не JSON",
+			frame: "hello",
+		},
+		{
+			name:  "Неверный тип поля",
+			frame: `{"type": 1}`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)))
+
+			var envelope domain.Envelope
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+			require.NoError(t, conn.ReadJSON(&envelope))
+			require.Equal(t, domain.EventError, envelope.Type)
+
+			var errPayload domain.ErrorPayload
+			require.NoError(t, json.Unmarshal(envelope.Payload, &errPayload))
+			require.Equal(t, domain.ErrCodeBadRequest, errPayload.Code)
+
+			// The connection survives the bad frame.
+			require.NoError(t, conn.WriteJSON(domain.Envelope{Type: domain.EventPing, Id: "ping"}))
+			require.NoError(t, conn.ReadJSON(&envelope))
+			require.Equal(t, domain.EventPong, envelope.Type)
+		})
+	}
+}
+
+func TestWsGetInfoUserChats(t *testing.T) {
+	type args struct {
+		userId      uuid.UUID
+		page, count uint
+	}
+
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	cases := []struct {
+		name            string
+		input           args
+		mockChatsReturn []domain.GetChat
+		mockChatsError  error
+		expectedChats   []domain.GetChat
+		expectedStatus  int
+	}{
+		{
+			name: "Возвращение одного чата",
+			input: args{
+				userId: userId,
+				page:   1,
+				count:  1,
+			},
+			mockChatsReturn: []domain.GetChat{
+				{
+					Name: "34 сквад",
+					LastMessage: models.Message{
+						PersonId:    userId,
+						MessageText: "Тест",
+					},
+				},
+			},
+			mockChatsError: nil,
+			expectedChats: []domain.GetChat{
+				{
+					Name: "34 сквад",
+					LastMessage: models.Message{
+						PersonId:    userId,
+						MessageText: "Тест",
+					},
+				},
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockChatService.On("GetInfoUserChats", tt.input.userId, tt.input.page, tt.input.count).Return(tt.mockChatsReturn, tt.mockChatsError)
+			url := fmt.Sprintf("%s/chat/info?page=%d&count=%d", server.URL, tt.input.page, tt.input.count)
+			req, err := http.NewRequest("GET", url, nil)
+			require.NoError(t, err)
+			req.Header = authHeader(tt.input.userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			var chats []domain.GetChat
+			decoder := json.NewDecoder(resp.Body)
+			err = decoder.Decode(&chats)
+			if err != nil {
+				t.Error(err)
+			}
+
+			require.Equal(t, tt.expectedChats, chats)
+		})
+	}
+}
+
+func TestWsUpdate(t *testing.T) {
+	type args struct {
+		chat models.Chat
+	}
+
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	cases := []struct {
+		name           string
+		input          args
+		mockChatsError error
+		expectedStatus int
+	}{
+		{
+			name: "Успешное обновление чата",
+			input: args{
+				chat: models.Chat{
+					Id:   uuid.New(),
+					Name: "Test",
+				},
+			},
+			mockChatsError: nil,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Обновление чата не участником",
+			input: args{
+				chat: models.Chat{
+					Id:   uuid.New(),
+					Name: "Test",
+				},
+			},
+			mockChatsError: fmt.Errorf("services.messenger.Update: %w", access.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockChatService.On("Update", userId, tt.input.chat).Return(tt.mockChatsError).Once()
+
+			body, err := json.Marshal(tt.input.chat)
+			if err != nil {
+				t.Error(err)
+			}
+
+			url := fmt.Sprintf("%s/chat", server.URL)
+
+			req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+			if err != nil {
+				t.Error(err)
+			}
+			req.Header = authHeader(userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestWsGetHistory(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	c := cursor.New(time.Now().UTC().Truncate(time.Microsecond), uuid.New())
+	page := domain.MessagePage{
+		Messages: []models.Message{{Id: uuid.New(), MessageText: "hi"}},
+		Before:   c.Encode(),
+	}
+
+	cases := []struct {
+		name           string
+		query          string
+		expectedQuery  *domain.HistoryQuery
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Последние сообщения",
+			query:          "",
+			expectedQuery:  &domain.HistoryQuery{ChatId: chatId},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Сообщения до курсора",
+			query:          "?before=" + c.Encode() + "&limit=20",
+			expectedQuery:  &domain.HistoryQuery{ChatId: chatId, Cursor: &c, Limit: 20},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Сообщения после курсора",
+			query:          "?after=" + c.Encode(),
+			expectedQuery:  &domain.HistoryQuery{ChatId: chatId, Cursor: &c, After: true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Не участник чата",
+			query:          "",
+			expectedQuery:  &domain.HistoryQuery{ChatId: chatId},
+			mockError:      fmt.Errorf("services.messenger.GetHistory: %w", access.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Неверный курсор",
+			query:          "?before=broken",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Оба курсора",
+			query:          "?before=" + c.Encode() + "&after=" + c.Encode(),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Неверный лимит",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expectedQuery != nil {
+				mockMessengerService.On("GetHistory", userId, *tt.expectedQuery).Return(page, tt.mockError).Once()
+			}
+
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/chat/%v/messages%s", server.URL, chatId, tt.query), nil)
+			require.NoError(t, err)
+			req.Header = authHeader(userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			if tt.expectedStatus == http.StatusOK {
+				var got domain.MessagePage
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+				require.Equal(t, page.Before, got.Before)
+				require.Len(t, got.Messages, 1)
+			}
+		})
+	}
+}
+
+func TestWsEditMessage(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+	mockBroker := mocks.NewBroker(t)
+	mockBroker.On("Subscribe").Return(make(<-chan *domain.Event), nil).Maybe()
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil, mockBroker,
+		testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	editedAt := time.Now().UTC()
+
+	cases := []struct {
+		name           string
+		mockError      error
+		expectedStatus int
+	}{
+		{
+			name:           "Успешное редактирование",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Редактирование чужого сообщения",
+			mockError:      fmt.Errorf("services.messenger.Edit: %w", access.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			edit := domain.MessageEditPayload{
+				MessageId: uuid.New(),
+				Message:   "edited",
+			}
+			msg := models.Message{
+				Id:          edit.MessageId,
+				PersonId:    userId,
+				Chat:        models.Chat{Id: chatId},
+				MessageText: edit.Message,
+				EditedAt:    &editedAt,
+			}
+			mockMessengerService.On("Edit", userId, edit).Return(msg, tt.mockError).Once()
+			if tt.mockError == nil {
+				mockBroker.On("Publish", mock.MatchedBy(func(event *domain.Event) bool {
+					return event.ChatId == chatId && event.Envelope.Type == domain.EventMessageEdited
+				})).Return(nil).Once()
+			}
+
+			body, err := json.Marshal(domain.MessageEditPayload{Message: edit.Message})
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("%s/messages/%v", server.URL, edit.MessageId)
+			req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header = authHeader(userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestWsReadMessages(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	chatId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+	mockBroker := mocks.NewBroker(t)
+	mockBroker.On("Subscribe").Return(make(<-chan *domain.Event), nil).Maybe()
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil, mockBroker,
+		testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	cases := []struct {
+		name            string
+		mockAdvanced    bool
+		mockError       error
+		expectedPublish bool
+		expectedStatus  int
+	}{
+		{
+			name:            "Квитанция о прочтении рассылается участникам",
+			mockAdvanced:    true,
+			expectedPublish: true,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:           "Повторное прочтение без рассылки",
+			mockAdvanced:   false,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Прочтение не участником чата",
+			mockError:      fmt.Errorf("services.messenger.MarkRead: %w", access.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			msgId := uuid.New()
+			read := domain.ReadPayload{
+				ChatId:    chatId,
+				MessageId: msgId,
+				PersonId:  userId,
+			}
+			mockMessengerService.On("MarkRead", userId, msgId).Return(domain.ReadResult{
+				Receipt:  read,
+				Advanced: tt.mockAdvanced,
+			}, tt.mockError).Once()
+			if tt.expectedPublish {
+				mockBroker.On("Publish", mock.MatchedBy(func(event *domain.Event) bool {
+					return event.ChatId == chatId && event.Envelope.Type == domain.EventRead
+				})).Return(nil).Once()
+			}
+
+			url := fmt.Sprintf("%s/messages/%v/read", server.URL, msgId)
+			req, err := http.NewRequest("POST", url, nil)
+			require.NoError(t, err)
+			req.Header = authHeader(userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestWsChangeRole(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	cases := []struct {
+		name           string
+		input          domain.ChangeRole
+		mockChatsError error
+		expectedStatus int
+	}{
+		{
+			name: "Успешная смена роли",
+			input: domain.ChangeRole{
+				ChatId:   uuid.New(),
+				PersonId: uuid.New(),
+				Role:     models.RoleAdmin,
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Смена роли не владельцем",
+			input: domain.ChangeRole{
+				ChatId:   uuid.New(),
+				PersonId: uuid.New(),
+				Role:     models.RoleAdmin,
+			},
+			mockChatsError: fmt.Errorf("services.chat.ChangeRole: %w", access.ErrForbidden),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Неизвестная роль",
+			input: domain.ChangeRole{
+				ChatId:   uuid.New(),
+				PersonId: uuid.New(),
+				Role:     "moderator",
+			},
+			mockChatsError: fmt.Errorf("services.chat.ChangeRole: %w", chat.ErrInvalidRole),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockChatService.On("ChangeRole", userId, tt.input.ChatId, tt.input.PersonId, tt.input.Role).
+				Return(tt.mockChatsError).Once()
+
+			body, err := json.Marshal(tt.input)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest("PUT", fmt.Sprintf("%s/chat/persons/role", server.URL), bytes.NewBuffer(body))
+			require.NoError(t, err)
+			req.Header = authHeader(userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestWsDelete(t *testing.T) {
+	type args struct {
+		chatId uuid.UUID
+		userId uuid.UUID
+	}
+
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	cases := []struct {
+		name           string
+		input          args
+		mockChatsError error
+		expectedStatus int
+	}{
+		{
+			name: "Успешное удаление",
+			input: args{
+				chatId: uuid.New(),
+				userId: userId,
+			},
+			mockChatsError: nil,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockChatService.On("Delete", tt.input.chatId, tt.input.userId).Return(tt.mockChatsError)
+
+			url := fmt.Sprintf("%s/chat?chatId=%v", server.URL, tt.input.chatId)
+			req, err := http.NewRequest("DELETE", url, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			req.Header = authHeader(tt.input.userId)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestWsWrite_PublishesToBroker(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	chatId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("Add", mock.AnythingOfType("domain.MessageAdd")).Return(models.Message{
+		PersonId: person1,
+		Chat: models.Chat{
+			Id: chatId,
+		},
+	}, nil)
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{chatId}, nil)
+
+	mockBroker := mocks.NewBroker(t)
+	mockBroker.On("Subscribe").Return((<-chan *domain.Event)(make(chan *domain.Event)), nil)
+	mockBroker.On("Publish", mock.MatchedBy(func(event *domain.Event) bool {
+		return event.ChatId == chatId && event.Envelope.Type == domain.EventMessageNew
+	})).Return(nil).Run(func(args mock.Arguments) {
+		wg.Done()
+	})
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil, mockBroker,
+		testAuthenticator(person1), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	payload, err := json.Marshal(domain.MessageAdd{
+		PersonId: person1,
+		ChatId:   chatId,
+		Message:  "Hello tests",
+	})
+	require.NoError(t, err)
+
+	err = conn.WriteJSON(domain.Envelope{
+		Type:    domain.EventMessageSend,
+		Id:      "1",
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	wg.Wait()
+	mockBroker.AssertExpectations(t)
+}
+
+// sharedBroker hands every event to each of its subscribers, the way Redis
+// does for instances sharing it.
+type sharedBroker struct {
+	mu          sync.Mutex
+	subscribers []chan *domain.Event
+}
+
+func (b *sharedBroker) Publish(event *domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, events := range b.subscribers {
+		events <- event
+	}
+	return nil
+}
+
+func (b *sharedBroker) Subscribe() (<-chan *domain.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan *domain.Event, 256)
+	b.subscribers = append(b.subscribers, events)
+	return events, nil
+}
+
+func TestWsMembership_AcrossInstances(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	owner := uuid.New()
+	member := uuid.New()
+	chatId := uuid.New()
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", member).Return([]uuid.UUID{}, nil)
+	mockChatService.On("AddNewUser", owner, chatId, member).Return(nil)
+	mockChatService.On("RemoveUser", owner, chatId, member).Return(nil)
+	mockChatService.On("Delete", chatId, owner).Return(nil)
+
+	broker := &sharedBroker{}
+	local := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mockChatService, testPresence(t), nil,
+		broker, testAuthenticator(owner), testClientConfig)
+	local.InitRoutes()
+	remote := NewHandler(slog.New(logHandler), mocks.NewMessageService(t), mockChatService, testPresence(t), nil,
+		broker, testAuthenticator(member), testClientConfig)
+	remote.InitRoutes()
+
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(member))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool {
+		return len(remote.hub.userClients(member)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		subscribed bool
+	}{
+		{
+			name:       "Добавление участника",
+			method:     http.MethodPost,
+			path:       fmt.Sprintf("/chat/persons/add?chatId=%v&personId=%v", chatId, member),
+			subscribed: true,
+		},
+		{
+			name:       "Исключение участника",
+			method:     http.MethodDelete,
+			path:       fmt.Sprintf("/chat/users/remove?chatId=%v&userId=%v", chatId, member),
+			subscribed: false,
+		},
+		{
+			name:       "Повторное добавление участника",
+			method:     http.MethodPost,
+			path:       fmt.Sprintf("/chat/persons/add?chatId=%v&personId=%v", chatId, member),
+			subscribed: true,
+		},
+		{
+			name:       "Удаление чата",
+			method:     http.MethodDelete,
+			path:       fmt.Sprintf("/chat?chatId=%v", chatId),
+			subscribed: false,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header = authHeader(owner)
+			rec := httptest.NewRecorder()
+			local.ServeHTTP(rec, req)
+			require.Less(t, rec.Code, http.StatusBadRequest)
+
+			require.Eventually(t, func() bool {
+				return (len(remote.hub.chatClients(chatId)) == 1) == tt.subscribed
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestWsGetSessions(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", mock.AnythingOfType("uuid.UUID")).Return([]uuid.UUID{}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	devices := []string{"phone", "laptop"}
+	for _, device := range devices {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?device=" + device
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(userId))
+		require.NoError(t, err)
+		defer conn.Close()
+	}
+
+	require.Eventually(t, func() bool {
+		return len(h.hub.userSessions(userId)) == len(devices)
+	}, time.Second*5, time.Millisecond*10)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/sessions", server.URL), nil)
+	require.NoError(t, err)
+	req.Header = authHeader(userId)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var sessions []domain.Session
+	err = json.NewDecoder(resp.Body).Decode(&sessions)
+	require.NoError(t, err)
+	require.Len(t, sessions, len(devices))
+	require.ElementsMatch(t, devices, []string{sessions[0].Device, sessions[1].Device})
+}
+
+func TestWsAuthentication(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockChatService := mocks.NewChatService(t)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(userId), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	cases := []struct {
+		name           string
+		header         http.Header
+		expectedStatus int
+	}{
+		{
+			name:           "Запрос без токена",
+			header:         http.Header{},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Неизвестный токен",
+			header:         authHeader(uuid.New()),
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s/sessions", server.URL), nil)
+			require.NoError(t, err)
+			req.Header = tt.header
 
 			resp, err := http.DefaultClient.Do(req)
 			require.NoError(t, err)
 			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+			_, resp, err = websocket.DefaultDialer.Dial(wsURL, tt.header)
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
 		})
 	}
+
+	t.Run("Токен в параметре access_token", func(t *testing.T) {
+		mockChatService.On("GetUserChats", userId).Return([]uuid.UUID{}, nil)
+
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?access_token=" + userId.String()
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.Eventually(t, func() bool {
+			return len(h.hub.userSessions(userId)) == 1
+		}, time.Second*5, time.Millisecond*10)
+	})
+}
+
+func TestWsWrite_UsesAuthenticatedPerson(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	person1 := uuid.New()
+	chatId := uuid.New()
+
+	mockMessengerService := mocks.NewMessageService(t)
+	mockMessengerService.On("Add", mock.MatchedBy(func(msg domain.MessageAdd) bool {
+		return msg.PersonId == person1
+	})).Return(models.Message{
+		PersonId: person1,
+		Chat: models.Chat{
+			Id: chatId,
+		},
+	}, nil).Run(func(args mock.Arguments) {
+		wg.Done()
+	})
+
+	mockChatService := mocks.NewChatService(t)
+	mockChatService.On("GetUserChats", person1).Return([]uuid.UUID{chatId}, nil)
+
+	h := NewHandler(slog.New(logHandler), mockMessengerService, mockChatService, testPresence(t), nil,
+		NewLocalBroker(), testAuthenticator(person1), testClientConfig)
+	h.InitRoutes()
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, authHeader(person1))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	payload, err := json.Marshal(domain.MessageAdd{
+		PersonId: uuid.New(),
+		ChatId:   chatId,
+		Message:  "Hello tests",
+	})
+	require.NoError(t, err)
+
+	err = conn.WriteJSON(domain.Envelope{
+		Type:    domain.EventMessageSend,
+		Id:      "1",
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	wg.Wait()
+	mockMessengerService.AssertExpectations(t)
 }
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"log/slog"
+	"messenger/internal/auth"
+	"net/http"
+	"strings"
+)
+
+// authenticate resolves the caller from a bearer token and stores the user id
+// in the request context. Browsers cannot set headers on a WebSocket
+// handshake, so upgrade requests may pass the token as access_token instead.
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "handler.authenticate"
+		log := h.log.With(
+			slog.String("op", op),
+		)
+
+		token := bearerToken(r)
+		if token == "" && websocket.IsWebSocketUpgrade(r) {
+			token = r.URL.Query().Get("access_token")
+		}
+
+		userId, err := h.authenticator.Authenticate(token)
+		if err != nil {
+			log.Warn("Error with authenticating request", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithUserId(r.Context(), userId)))
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// currentUser returns the authenticated caller. Routes are only reachable
+// through authenticate, so the id is always present.
+func currentUser(r *http.Request) uuid.UUID {
+	userId, _ := auth.UserId(r.Context())
+	return userId
+}
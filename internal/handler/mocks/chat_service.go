@@ -17,9 +17,9 @@ type ChatService struct {
 	mock.Mock
 }
 
-// Add provides a mock function with given fields: chat
-func (_m *ChatService) Add(chat domain.AddChat) (uuid.UUID, error) {
-	ret := _m.Called(chat)
+// Add provides a mock function with given fields: ownerId, chat
+func (_m *ChatService) Add(ownerId uuid.UUID, chat domain.AddChat) (uuid.UUID, error) {
+	ret := _m.Called(ownerId, chat)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Add")
@@ -27,19 +27,19 @@ func (_m *ChatService) Add(chat domain.AddChat) (uuid.UUID, error) {
 
 	var r0 uuid.UUID
 	var r1 error
-	if rf, ok := ret.Get(0).(func(domain.AddChat) (uuid.UUID, error)); ok {
-		return rf(chat)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.AddChat) (uuid.UUID, error)); ok {
+		return rf(ownerId, chat)
 	}
-	if rf, ok := ret.Get(0).(func(domain.AddChat) uuid.UUID); ok {
-		r0 = rf(chat)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.AddChat) uuid.UUID); ok {
+		r0 = rf(ownerId, chat)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(uuid.UUID)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(domain.AddChat) error); ok {
-		r1 = rf(chat)
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.AddChat) error); ok {
+		r1 = rf(ownerId, chat)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -47,17 +47,35 @@ func (_m *ChatService) Add(chat domain.AddChat) (uuid.UUID, error) {
 	return r0, r1
 }
 
-// AddNewUser provides a mock function with given fields: chatId, userId
-func (_m *ChatService) AddNewUser(chatId uuid.UUID, userId uuid.UUID) error {
-	ret := _m.Called(chatId, userId)
+// AddNewUser provides a mock function with given fields: callerId, chatId, userId
+func (_m *ChatService) AddNewUser(callerId uuid.UUID, chatId uuid.UUID, userId uuid.UUID) error {
+	ret := _m.Called(callerId, chatId, userId)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddNewUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) error); ok {
-		r0 = rf(chatId, userId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(callerId, chatId, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ChangeRole provides a mock function with given fields: callerId, chatId, userId, role
+func (_m *ChatService) ChangeRole(callerId uuid.UUID, chatId uuid.UUID, userId uuid.UUID, role models.Role) error {
+	ret := _m.Called(callerId, chatId, userId, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangeRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID, models.Role) error); ok {
+		r0 = rf(callerId, chatId, userId, role)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -113,6 +131,66 @@ func (_m *ChatService) GetInfoUserChats(userId uuid.UUID, page uint, count uint)
 	return r0, r1
 }
 
+// GetMembers provides a mock function with given fields: callerId, chatId
+func (_m *ChatService) GetMembers(callerId uuid.UUID, chatId uuid.UUID) ([]models.ChatMember, error) {
+	ret := _m.Called(callerId, chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMembers")
+	}
+
+	var r0 []models.ChatMember
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) ([]models.ChatMember, error)); ok {
+		return rf(callerId, chatId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) []models.ChatMember); ok {
+		r0 = rf(callerId, chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ChatMember)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(callerId, chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPins provides a mock function with given fields: callerId, chatId
+func (_m *ChatService) GetPins(callerId uuid.UUID, chatId uuid.UUID) ([]models.Pin, error) {
+	ret := _m.Called(callerId, chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPins")
+	}
+
+	var r0 []models.Pin
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) ([]models.Pin, error)); ok {
+		return rf(callerId, chatId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) []models.Pin); ok {
+		r0 = rf(callerId, chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Pin)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(callerId, chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserChats provides a mock function with given fields: userId
 func (_m *ChatService) GetUserChats(userId uuid.UUID) ([]uuid.UUID, error) {
 	ret := _m.Called(userId)
@@ -143,17 +221,110 @@ func (_m *ChatService) GetUserChats(userId uuid.UUID) ([]uuid.UUID, error) {
 	return r0, r1
 }
 
-// RemoveUser provides a mock function with given fields: chatId, userId
-func (_m *ChatService) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
-	ret := _m.Called(chatId, userId)
+// GetUserInfo provides a mock function with given fields: id
+func (_m *ChatService) GetUserInfo(id uuid.UUID) (domain.UserInfo, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserInfo")
+	}
+
+	var r0 domain.UserInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) (domain.UserInfo, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) domain.UserInfo); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(domain.UserInfo)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsers provides a mock function with given fields: callerId, chatId
+func (_m *ChatService) GetUsers(callerId uuid.UUID, chatId uuid.UUID) ([]uuid.UUID, error) {
+	ret := _m.Called(callerId, chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsers")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) ([]uuid.UUID, error)); ok {
+		return rf(callerId, chatId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) []uuid.UUID); ok {
+		r0 = rf(callerId, chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(callerId, chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pin provides a mock function with given fields: callerId, chatId, messageId
+func (_m *ChatService) Pin(callerId uuid.UUID, chatId uuid.UUID, messageId uuid.UUID) (domain.PinPayload, bool, error) {
+	ret := _m.Called(callerId, chatId, messageId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pin")
+	}
+
+	var r0 domain.PinPayload
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) (domain.PinPayload, bool, error)); ok {
+		return rf(callerId, chatId, messageId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) domain.PinPayload); ok {
+		r0 = rf(callerId, chatId, messageId)
+	} else {
+		r0 = ret.Get(0).(domain.PinPayload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, uuid.UUID) bool); ok {
+		r1 = rf(callerId, chatId, messageId)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r2 = rf(callerId, chatId, messageId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RemoveUser provides a mock function with given fields: callerId, chatId, userId
+func (_m *ChatService) RemoveUser(callerId uuid.UUID, chatId uuid.UUID, userId uuid.UUID) error {
+	ret := _m.Called(callerId, chatId, userId)
 
 	if len(ret) == 0 {
 		panic("no return value specified for RemoveUser")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) error); ok {
-		r0 = rf(chatId, userId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(callerId, chatId, userId)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -161,17 +332,52 @@ func (_m *ChatService) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
 	return r0
 }
 
-// Update provides a mock function with given fields: chat
-func (_m *ChatService) Update(chat models.Chat) error {
-	ret := _m.Called(chat)
+// Unpin provides a mock function with given fields: callerId, chatId, messageId
+func (_m *ChatService) Unpin(callerId uuid.UUID, chatId uuid.UUID, messageId uuid.UUID) (domain.PinPayload, bool, error) {
+	ret := _m.Called(callerId, chatId, messageId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Unpin")
+	}
+
+	var r0 domain.PinPayload
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) (domain.PinPayload, bool, error)); ok {
+		return rf(callerId, chatId, messageId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) domain.PinPayload); ok {
+		r0 = rf(callerId, chatId, messageId)
+	} else {
+		r0 = ret.Get(0).(domain.PinPayload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, uuid.UUID) bool); ok {
+		r1 = rf(callerId, chatId, messageId)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r2 = rf(callerId, chatId, messageId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Update provides a mock function with given fields: callerId, chat
+func (_m *ChatService) Update(callerId uuid.UUID, chat models.Chat) error {
+	ret := _m.Called(callerId, chat)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Update")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Chat) error); ok {
-		r0 = rf(chat)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, models.Chat) error); ok {
+		r0 = rf(callerId, chat)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,263 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	io "io"
+	models "messenger/internal/domain/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// FileService is an autogenerated mock type for the FileService type
+type FileService struct {
+	mock.Mock
+}
+
+// AppendChunk provides a mock function with given fields: userId, id, offset, r
+func (_m *FileService) AppendChunk(userId uuid.UUID, id uuid.UUID, offset int64, r io.Reader) (int64, error) {
+	ret := _m.Called(userId, id, offset, r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AppendChunk")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, int64, io.Reader) (int64, error)); ok {
+		return rf(userId, id, offset, r)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, int64, io.Reader) int64); ok {
+		r0 = rf(userId, id, offset, r)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, int64, io.Reader) error); ok {
+		r1 = rf(userId, id, offset, r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelUpload provides a mock function with given fields: userId, id
+func (_m *FileService) CancelUpload(userId uuid.UUID, id uuid.UUID) error {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateUpload provides a mock function with given fields: userId, name, length
+func (_m *FileService) CreateUpload(userId uuid.UUID, name string, length int64) (models.Upload, error) {
+	ret := _m.Called(userId, name, length)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateUpload")
+	}
+
+	var r0 models.Upload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, int64) (models.Upload, error)); ok {
+		return rf(userId, name, length)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, int64) models.Upload); ok {
+		r0 = rf(userId, name, length)
+	} else {
+		r0 = ret.Get(0).(models.Upload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, string, int64) error); ok {
+		r1 = rf(userId, name, length)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishUpload provides a mock function with given fields: userId, id
+func (_m *FileService) FinishUpload(userId uuid.UUID, id uuid.UUID) (models.File, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishUpload")
+	}
+
+	var r0 models.File
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.File, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.File); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUpload provides a mock function with given fields: userId, id
+func (_m *FileService) GetUpload(userId uuid.UUID, id uuid.UUID) (models.Upload, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUpload")
+	}
+
+	var r0 models.Upload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Upload, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Upload); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Get(0).(models.Upload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Open provides a mock function with given fields: userId, id
+func (_m *FileService) Open(userId uuid.UUID, id uuid.UUID) (models.File, io.ReadCloser, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Open")
+	}
+
+	var r0 models.File
+	var r1 io.ReadCloser
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.File, io.ReadCloser, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.File); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) io.ReadCloser); ok {
+		r1 = rf(userId, id)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(io.ReadCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID) error); ok {
+		r2 = rf(userId, id)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// OpenThumbnail provides a mock function with given fields: userId, id, size
+func (_m *FileService) OpenThumbnail(userId uuid.UUID, id uuid.UUID, size int) (models.Thumbnail, io.ReadCloser, error) {
+	ret := _m.Called(userId, id, size)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OpenThumbnail")
+	}
+
+	var r0 models.Thumbnail
+	var r1 io.ReadCloser
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, int) (models.Thumbnail, io.ReadCloser, error)); ok {
+		return rf(userId, id, size)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, int) models.Thumbnail); ok {
+		r0 = rf(userId, id, size)
+	} else {
+		r0 = ret.Get(0).(models.Thumbnail)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, int) io.ReadCloser); ok {
+		r1 = rf(userId, id, size)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(io.ReadCloser)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, int) error); ok {
+		r2 = rf(userId, id, size)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Upload provides a mock function with given fields: userId, name, r
+func (_m *FileService) Upload(userId uuid.UUID, name string, r io.Reader) (models.File, error) {
+	ret := _m.Called(userId, name, r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Upload")
+	}
+
+	var r0 models.File
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, io.Reader) (models.File, error)); ok {
+		return rf(userId, name, r)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string, io.Reader) models.File); ok {
+		r0 = rf(userId, name, r)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, string, io.Reader) error); ok {
+		r1 = rf(userId, name, r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewFileService creates a new instance of FileService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewFileService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *FileService {
+	mock := &FileService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
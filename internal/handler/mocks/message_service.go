@@ -4,6 +4,7 @@ package mocks
 
 import (
 	domain "messenger/internal/domain"
+	cursor "messenger/pkg/cursor"
 
 	mock "github.com/stretchr/testify/mock"
 
@@ -45,47 +46,148 @@ func (_m *MessageService) Add(message domain.MessageAdd) (models.Message, error)
 	return r0, r1
 }
 
-// Delete provides a mock function with given fields: id
-func (_m *MessageService) Delete(id uuid.UUID) error {
-	ret := _m.Called(id)
+// AddReaction provides a mock function with given fields: userId, reaction
+func (_m *MessageService) AddReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error) {
+	ret := _m.Called(userId, reaction)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddReaction")
+	}
+
+	var r0 domain.ReactionsPayload
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.ReactionPayload) (domain.ReactionsPayload, bool, error)); ok {
+		return rf(userId, reaction)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.ReactionPayload) domain.ReactionsPayload); ok {
+		r0 = rf(userId, reaction)
+	} else {
+		r0 = ret.Get(0).(domain.ReactionsPayload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.ReactionPayload) bool); ok {
+		r1 = rf(userId, reaction)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, domain.ReactionPayload) error); ok {
+		r2 = rf(userId, reaction)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// AddToThread provides a mock function with given fields: message
+func (_m *MessageService) AddToThread(message domain.MessageAdd) (domain.ThreadReply, error) {
+	ret := _m.Called(message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddToThread")
+	}
+
+	var r0 domain.ThreadReply
+	var r1 error
+	if rf, ok := ret.Get(0).(func(domain.MessageAdd) (domain.ThreadReply, error)); ok {
+		return rf(message)
+	}
+	if rf, ok := ret.Get(0).(func(domain.MessageAdd) domain.ThreadReply); ok {
+		r0 = rf(message)
+	} else {
+		r0 = ret.Get(0).(domain.ThreadReply)
+	}
+
+	if rf, ok := ret.Get(1).(func(domain.MessageAdd) error); ok {
+		r1 = rf(message)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: userId, id
+func (_m *MessageService) Delete(userId uuid.UUID, id uuid.UUID) (domain.DeletedMessage, error) {
+	ret := _m.Called(userId, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
-		r0 = rf(id)
+	var r0 domain.DeletedMessage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (domain.DeletedMessage, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) domain.DeletedMessage); ok {
+		r0 = rf(userId, id)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(domain.DeletedMessage)
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Edit provides a mock function with given fields: userId, edit
+func (_m *MessageService) Edit(userId uuid.UUID, edit domain.MessageEditPayload) (models.Message, error) {
+	ret := _m.Called(userId, edit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Edit")
+	}
+
+	var r0 models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.MessageEditPayload) (models.Message, error)); ok {
+		return rf(userId, edit)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.MessageEditPayload) models.Message); ok {
+		r0 = rf(userId, edit)
+	} else {
+		r0 = ret.Get(0).(models.Message)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.MessageEditPayload) error); ok {
+		r1 = rf(userId, edit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByChat provides a mock function with given fields: chatId
-func (_m *MessageService) GetByChat(chatId uuid.UUID) ([]models.Message, error) {
-	ret := _m.Called(chatId)
+// Forward provides a mock function with given fields: userId, forward
+func (_m *MessageService) Forward(userId uuid.UUID, forward domain.MessageForward) ([]models.Message, error) {
+	ret := _m.Called(userId, forward)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByChat")
+		panic("no return value specified for Forward")
 	}
 
 	var r0 []models.Message
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.Message, error)); ok {
-		return rf(chatId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.MessageForward) ([]models.Message, error)); ok {
+		return rf(userId, forward)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.Message); ok {
-		r0 = rf(chatId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.MessageForward) []models.Message); ok {
+		r0 = rf(userId, forward)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Message)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
-		r1 = rf(chatId)
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.MessageForward) error); ok {
+		r1 = rf(userId, forward)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -93,9 +195,9 @@ func (_m *MessageService) GetByChat(chatId uuid.UUID) ([]models.Message, error)
 	return r0, r1
 }
 
-// GetById provides a mock function with given fields: id
-func (_m *MessageService) GetById(id uuid.UUID) (models.Message, error) {
-	ret := _m.Called(id)
+// GetById provides a mock function with given fields: userId, id
+func (_m *MessageService) GetById(userId uuid.UUID, id uuid.UUID) (models.Message, error) {
+	ret := _m.Called(userId, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetById")
@@ -103,17 +205,17 @@ func (_m *MessageService) GetById(id uuid.UUID) (models.Message, error) {
 
 	var r0 models.Message
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) (models.Message, error)); ok {
-		return rf(id)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Message, error)); ok {
+		return rf(userId, id)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID) models.Message); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Message); ok {
+		r0 = rf(userId, id)
 	} else {
 		r0 = ret.Get(0).(models.Message)
 	}
 
-	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -121,17 +223,284 @@ func (_m *MessageService) GetById(id uuid.UUID) (models.Message, error) {
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: message
-func (_m *MessageService) Update(message domain.MessageUpdate) error {
-	ret := _m.Called(message)
+// GetEdits provides a mock function with given fields: userId, id
+func (_m *MessageService) GetEdits(userId uuid.UUID, id uuid.UUID) ([]models.MessageEdit, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetEdits")
+	}
+
+	var r0 []models.MessageEdit
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) ([]models.MessageEdit, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) []models.MessageEdit); ok {
+		r0 = rf(userId, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.MessageEdit)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHistory provides a mock function with given fields: userId, query
+func (_m *MessageService) GetHistory(userId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error) {
+	ret := _m.Called(userId, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHistory")
+	}
+
+	var r0 domain.MessagePage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.HistoryQuery) (domain.MessagePage, error)); ok {
+		return rf(userId, query)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.HistoryQuery) domain.MessagePage); ok {
+		r0 = rf(userId, query)
+	} else {
+		r0 = ret.Get(0).(domain.MessagePage)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.HistoryQuery) error); ok {
+		r1 = rf(userId, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetReceipts provides a mock function with given fields: userId, id
+func (_m *MessageService) GetReceipts(userId uuid.UUID, id uuid.UUID) ([]models.Receipt, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetReceipts")
+	}
+
+	var r0 []models.Receipt
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) ([]models.Receipt, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) []models.Receipt); ok {
+		r0 = rf(userId, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Receipt)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSince provides a mock function with given fields: userId, c, limit
+func (_m *MessageService) GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error) {
+	ret := _m.Called(userId, c, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSince")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, cursor.Cursor, uint) ([]models.Message, error)); ok {
+		return rf(userId, c, limit)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, cursor.Cursor, uint) []models.Message); ok {
+		r0 = rf(userId, c, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, cursor.Cursor, uint) error); ok {
+		r1 = rf(userId, c, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetThread provides a mock function with given fields: userId, rootId, query
+func (_m *MessageService) GetThread(userId uuid.UUID, rootId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error) {
+	ret := _m.Called(userId, rootId, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetThread")
+	}
+
+	var r0 domain.MessagePage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, domain.HistoryQuery) (domain.MessagePage, error)); ok {
+		return rf(userId, rootId, query)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, domain.HistoryQuery) domain.MessagePage); ok {
+		r0 = rf(userId, rootId, query)
+	} else {
+		r0 = ret.Get(0).(domain.MessagePage)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, domain.HistoryQuery) error); ok {
+		r1 = rf(userId, rootId, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Hide provides a mock function with given fields: userId, id
+func (_m *MessageService) Hide(userId uuid.UUID, id uuid.UUID) (models.Message, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Hide")
+	}
+
+	var r0 models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Message, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Message); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Get(0).(models.Message)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkDelivered provides a mock function with given fields: userId, ids
+func (_m *MessageService) MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]domain.MessageStatus, error) {
+	ret := _m.Called(userId, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkDelivered")
+	}
+
+	var r0 []domain.MessageStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID) ([]domain.MessageStatus, error)); ok {
+		return rf(userId, ids)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID) []domain.MessageStatus); ok {
+		r0 = rf(userId, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.MessageStatus)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, []uuid.UUID) error); ok {
+		r1 = rf(userId, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkRead provides a mock function with given fields: userId, id
+func (_m *MessageService) MarkRead(userId uuid.UUID, id uuid.UUID) (domain.ReadResult, error) {
+	ret := _m.Called(userId, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkRead")
+	}
+
+	var r0 domain.ReadResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (domain.ReadResult, error)); ok {
+		return rf(userId, id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) domain.ReadResult); ok {
+		r0 = rf(userId, id)
+	} else {
+		r0 = ret.Get(0).(domain.ReadResult)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveReaction provides a mock function with given fields: userId, reaction
+func (_m *MessageService) RemoveReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error) {
+	ret := _m.Called(userId, reaction)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveReaction")
+	}
+
+	var r0 domain.ReactionsPayload
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.ReactionPayload) (domain.ReactionsPayload, bool, error)); ok {
+		return rf(userId, reaction)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.ReactionPayload) domain.ReactionsPayload); ok {
+		r0 = rf(userId, reaction)
+	} else {
+		r0 = ret.Get(0).(domain.ReactionsPayload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, domain.ReactionPayload) bool); ok {
+		r1 = rf(userId, reaction)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, domain.ReactionPayload) error); ok {
+		r2 = rf(userId, reaction)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Update provides a mock function with given fields: userId, message
+func (_m *MessageService) Update(userId uuid.UUID, message domain.MessageUpdate) error {
+	ret := _m.Called(userId, message)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Update")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(domain.MessageUpdate) error); ok {
-		r0 = rf(message)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, domain.MessageUpdate) error); ok {
+		r0 = rf(userId, message)
 	} else {
 		r0 = ret.Error(0)
 	}
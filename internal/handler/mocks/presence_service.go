@@ -0,0 +1,146 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	domain "messenger/internal/domain"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// PresenceService is an autogenerated mock type for the PresenceService type
+type PresenceService struct {
+	mock.Mock
+}
+
+// Disconnect provides a mock function with given fields: userId, sessionId
+func (_m *PresenceService) Disconnect(userId uuid.UUID, sessionId uuid.UUID) (bool, error) {
+	ret := _m.Called(userId, sessionId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Disconnect")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(userId, sessionId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(userId, sessionId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, sessionId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Get provides a mock function with given fields: userIds
+func (_m *PresenceService) Get(userIds []uuid.UUID) ([]domain.Presence, error) {
+	ret := _m.Called(userIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 []domain.Presence
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) ([]domain.Presence, error)); ok {
+		return rf(userIds)
+	}
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) []domain.Presence); ok {
+		r0 = rf(userIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Presence)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]uuid.UUID) error); ok {
+		r1 = rf(userIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetContacts provides a mock function with given fields: userId
+func (_m *PresenceService) GetContacts(userId uuid.UUID) ([]uuid.UUID, error) {
+	ret := _m.Called(userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetContacts")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]uuid.UUID, error)); ok {
+		return rf(userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []uuid.UUID); ok {
+		r0 = rf(userId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Heartbeat provides a mock function with given fields: userId, sessionId
+func (_m *PresenceService) Heartbeat(userId uuid.UUID, sessionId uuid.UUID) (bool, error) {
+	ret := _m.Called(userId, sessionId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Heartbeat")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(userId, sessionId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(userId, sessionId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, sessionId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewPresenceService creates a new instance of PresenceService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPresenceService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PresenceService {
+	mock := &PresenceService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
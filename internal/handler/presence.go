@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"messenger/internal/domain"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxPresenceBatch caps how many users may be looked up in one request.
+const maxPresenceBatch = 100
+
+//go:generate mockery --name=PresenceService --output=./mocks --case=underscore
+type PresenceService interface {
+	Heartbeat(userId, sessionId uuid.UUID) (bool, error)
+	Disconnect(userId, sessionId uuid.UUID) (bool, error)
+	Get(userIds []uuid.UUID) ([]domain.Presence, error)
+	GetContacts(userId uuid.UUID) ([]uuid.UUID, error)
+}
+
+// heartbeat keeps the session online while it is connected and takes it
+// offline once it closes. Running both in one goroutine guarantees that no
+// heartbeat lands after the session went offline.
+func (h *Handler) heartbeat(c *client) {
+	const op = "handler.heartbeat"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("sessionId", c.sessionId.String()),
+	)
+
+	ticker := time.NewTicker(h.clientCfg.PresenceTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		cameOnline, err := h.presenceService.Heartbeat(c.userId, c.sessionId)
+		if err != nil {
+			log.Warn("Error with sending heartbeat", slog.String("err", err.Error()))
+		} else if cameOnline {
+			h.publishPresence(c.userId)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			wentOffline, err := h.presenceService.Disconnect(c.userId, c.sessionId)
+			if err != nil {
+				log.Warn("Error with ending presence", slog.String("err", err.Error()))
+				return
+			}
+			if wentOffline {
+				h.publishPresence(c.userId)
+			}
+			return
+		}
+	}
+}
+
+// publishPresence pushes the user's presence to everyone who shares a chat with them.
+func (h *Handler) publishPresence(userId uuid.UUID) {
+	const op = "handler.publishPresence"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	presences, err := h.presenceService.Get([]uuid.UUID{userId})
+	if err != nil || len(presences) == 0 {
+		log.Warn("Error with getting presence", slog.Any("err", err))
+		return
+	}
+
+	contacts, err := h.presenceService.GetContacts(userId)
+	if err != nil {
+		log.Warn("Error with getting contacts", slog.String("err", err.Error()))
+		return
+	}
+
+	for _, contact := range contacts {
+		if err = h.publishToUser(contact, uuid.Nil, domain.EventPresence, presences[0]); err != nil {
+			log.Warn("Error with publishing presence", slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (h *Handler) getPresence(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getPresence"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	userId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing userId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	visible, err := h.visibleUsers(currentUser(r), []uuid.UUID{userId})
+	if err != nil {
+		log.Error("Error with getting contacts", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(visible) == 0 {
+		log.Error("User is not a contact", slog.String("userId", userId.String()))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	presences, err := h.presenceService.Get(visible)
+	if err != nil || len(presences) == 0 {
+		log.Error("Error with getting presence", slog.Any("err", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(presences[0]); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// getPresences looks up several users at once: ids is a comma separated list.
+// Users who share no chat with the caller are left out of the reply.
+func (h *Handler) getPresences(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getPresences"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	raw := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(raw) > maxPresenceBatch {
+		log.Error("Too many users requested", slog.Int("count", len(raw)))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userIds := make([]uuid.UUID, 0, len(raw))
+	for _, id := range raw {
+		userId, err := uuid.Parse(id)
+		if err != nil {
+			log.Error("Error with parsing userId", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		userIds = append(userIds, userId)
+	}
+
+	userIds, err := h.visibleUsers(currentUser(r), userIds)
+	if err != nil {
+		log.Error("Error with getting contacts", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	presences := []domain.Presence{}
+	if len(userIds) > 0 {
+		presences, err = h.presenceService.Get(userIds)
+		if err != nil {
+			log.Error("Error with getting presence", slog.String("err", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(presences); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// visibleUsers keeps the users whose presence the caller may see: the caller
+// and everyone who shares a chat with them.
+func (h *Handler) visibleUsers(callerId uuid.UUID, userIds []uuid.UUID) ([]uuid.UUID, error) {
+	contacts, err := h.presenceService.GetContacts(callerId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]uuid.UUID, 0, len(userIds))
+	for _, id := range userIds {
+		if id == callerId || slices.Contains(contacts, id) {
+			visible = append(visible, id)
+		}
+	}
+	return visible, nil
+}
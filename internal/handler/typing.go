@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type typingKey struct {
+	chatId uuid.UUID
+	userId uuid.UUID
+}
+
+type typingEntry struct {
+	sessionId uuid.UUID
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+// typingTracker remembers who is typing where, so that an indicator a client
+// never stops is stopped by the server once it has not been refreshed for
+// the timeout. Nothing about typing is persisted.
+type typingTracker struct {
+	timeout  time.Duration
+	onExpire func(chatId, userId uuid.UUID)
+
+	mu      sync.Mutex
+	entries map[typingKey]*typingEntry
+}
+
+func newTypingTracker(timeout time.Duration, onExpire func(chatId, userId uuid.UUID)) *typingTracker {
+	return &typingTracker{
+		timeout:  timeout,
+		onExpire: onExpire,
+		entries:  make(map[typingKey]*typingEntry),
+	}
+}
+
+// start marks the user as typing in the chat, or refreshes the expiry if
+// they already are. It reports whether the user just started typing.
+func (t *typingTracker) start(c *client, chatId uuid.UUID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := typingKey{chatId: chatId, userId: c.userId}
+	if entry, ok := t.entries[key]; ok {
+		entry.sessionId = c.sessionId
+		entry.expiresAt = time.Now().Add(t.timeout)
+		entry.timer.Reset(t.timeout)
+		return false
+	}
+
+	entry := &typingEntry{
+		sessionId: c.sessionId,
+		expiresAt: time.Now().Add(t.timeout),
+	}
+	entry.timer = time.AfterFunc(t.timeout, func() {
+		t.expire(key, entry)
+	})
+	t.entries[key] = entry
+	return true
+}
+
+// stop clears the indicator and reports whether the user was typing.
+func (t *typingTracker) stop(c *client, chatId uuid.UUID) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := typingKey{chatId: chatId, userId: c.userId}
+	entry, ok := t.entries[key]
+	if !ok {
+		return false
+	}
+	entry.timer.Stop()
+	delete(t.entries, key)
+	return true
+}
+
+// stopSession clears the indicators last refreshed by the session and returns
+// the chats they were shown in.
+func (t *typingTracker) stopSession(c *client) []uuid.UUID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var chatIds []uuid.UUID
+	for key, entry := range t.entries {
+		if key.userId != c.userId || entry.sessionId != c.sessionId {
+			continue
+		}
+		entry.timer.Stop()
+		delete(t.entries, key)
+		chatIds = append(chatIds, key.chatId)
+	}
+	return chatIds
+}
+
+func (t *typingTracker) expire(key typingKey, entry *typingEntry) {
+	t.mu.Lock()
+	// The entry may have been stopped, replaced or refreshed after the timer
+	// fired; a refresh has already re-armed the timer.
+	if t.entries[key] != entry || time.Now().Before(entry.expiresAt) {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.entries, key)
+	t.mu.Unlock()
+
+	t.onExpire(key.chatId, key.userId)
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second up to
+// burst. It is used by a single read loop and is not safe for concurrent use.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow() bool {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
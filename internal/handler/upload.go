@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Resumable uploads follow the headers of the tus protocol: the client
+// creates an upload with its length, sends chunks with the offset they start
+// at and asks for the offset to resume from after a failure. Finishing an
+// upload turns it into a file that can be attached to messages.
+const (
+	tusVersion        = "1.0.0"
+	offsetContentType = "application/offset+octet-stream"
+)
+
+func (h *Handler) createUpload(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.createUpload"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		log.Error("Error with parsing upload length", slog.String("length", r.Header.Get("Upload-Length")))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	name, err := uploadFileName(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		log.Error("Error with parsing upload metadata", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.fileService.CreateUpload(currentUser(r), name, length)
+	if err != nil {
+		log.Error("Error with creating upload", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Location", "/uploads/"+upload.Id.String())
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(upload); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+// getUpload reports how much of the upload was received, for the client to
+// resume from there.
+func (h *Handler) getUpload(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.getUpload"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing uploadId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	upload, err := h.fileService.GetUpload(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with getting upload", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// appendChunk stores the body as the chunk that starts at the Upload-Offset
+// of the request. The body is streamed to the service, which stops reading
+// at the chunk size limit.
+func (h *Handler) appendChunk(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.appendChunk"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing uploadId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != offsetContentType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		log.Error("Error with parsing upload offset", slog.String("offset", r.Header.Get("Upload-Offset")))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	offset, err = h.fileService.AppendChunk(currentUser(r), id, offset, r.Body)
+	if err != nil {
+		log.Error("Error with appending chunk", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) finishUpload(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.finishUpload"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing uploadId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	uploaded, err := h.fileService.FinishUpload(currentUser(r), id)
+	if err != nil {
+		log.Error("Error with finishing upload", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+	log.Info("file uploaded", slog.String("fileId", uploaded.Id.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(uploaded); err != nil {
+		log.Error("Error writing response", slog.String("err", err.Error()))
+	}
+}
+
+func (h *Handler) cancelUpload(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.cancelUpload"
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		log.Error("Error with parsing uploadId", slog.String("err", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err = h.fileService.CancelUpload(currentUser(r), id); err != nil {
+		log.Error("Error with cancelling upload", slog.String("err", err.Error()))
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadFileName takes the file name from Upload-Metadata, a comma separated
+// list of keys with base64 encoded values.
+func uploadFileName(metadata string) (string, error) {
+	for _, pair := range strings.Split(metadata, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "filename" {
+			continue
+		}
+		name, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		return string(name), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,165 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"messenger/internal/domain/models"
+)
+
+// ErrForbidden is returned when the caller is not allowed to touch a chat.
+var ErrForbidden = errors.New("forbidden")
+
+// Permission is an action in a chat that not every member may perform.
+type Permission string
+
+const (
+	PermRenameChat     Permission = "rename_chat"
+	PermAddMembers     Permission = "add_members"
+	PermRemoveMembers  Permission = "remove_members"
+	PermDeleteMessages Permission = "delete_messages"
+	PermDeleteChat     Permission = "delete_chat"
+	PermChangeRoles    Permission = "change_roles"
+)
+
+// permissions is the permission matrix. Deleting your own messages and
+// leaving a chat are allowed to everyone and are not listed here.
+var permissions = map[models.Role]map[Permission]bool{
+	models.RoleOwner: {
+		PermRenameChat:     true,
+		PermAddMembers:     true,
+		PermRemoveMembers:  true,
+		PermDeleteMessages: true,
+		PermDeleteChat:     true,
+		PermChangeRoles:    true,
+	},
+	models.RoleAdmin: {
+		PermRenameChat:     true,
+		PermAddMembers:     true,
+		PermRemoveMembers:  true,
+		PermDeleteMessages: true,
+	},
+	models.RoleMember: {},
+}
+
+var ranks = map[models.Role]int{
+	models.RoleMember: 1,
+	models.RoleAdmin:  2,
+	models.RoleOwner:  3,
+}
+
+// Can reports whether the role grants the permission.
+func Can(role models.Role, perm Permission) bool {
+	return permissions[role][perm]
+}
+
+// Outranks reports whether role a is strictly above role b.
+func Outranks(a, b models.Role) bool {
+	return ranks[a] > ranks[b]
+}
+
+//go:generate mockery --name=Repository --output=./mocks --case=underscore
+type Repository interface {
+	// GetRole returns the role of the user in the chat or an empty role
+	// if the user is not a member.
+	GetRole(chatId, userId uuid.UUID) (models.Role, error)
+}
+
+type Service struct {
+	log        *slog.Logger
+	repository Repository
+}
+
+func NewAccessService(log *slog.Logger, repository Repository) *Service {
+	return &Service{
+		log:        log,
+		repository: repository,
+	}
+}
+
+// CheckMember returns ErrForbidden unless the user belongs to the chat.
+func (s *Service) CheckMember(chatId, userId uuid.UUID) error {
+	const op = "services.access.CheckMember"
+
+	if _, err := s.Role(chatId, userId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Role returns the role of the user in the chat and ErrForbidden if the user
+// is not a member.
+func (s *Service) Role(chatId, userId uuid.UUID) (models.Role, error) {
+	const op = "services.access.Role"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	role, err := s.repository.GetRole(chatId, userId)
+	if err != nil {
+		log.Error("error with getting role", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role == "" {
+		log.Warn("user is not a member of chat", slog.String("chatId", chatId.String()),
+			slog.String("userId", userId.String()))
+		return "", fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+	return role, nil
+}
+
+// Authorize returns ErrForbidden unless the user's role in the chat grants
+// the permission.
+func (s *Service) Authorize(chatId, userId uuid.UUID, perm Permission) error {
+	const op = "services.access.Authorize"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	role, err := s.Role(chatId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !Can(role, perm) {
+		log.Warn("role has no permission", slog.String("role", string(role)),
+			slog.String("permission", string(perm)))
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+	return nil
+}
+
+// AuthorizeOver is Authorize for actions aimed at another member: the caller
+// must also outrank the target, so admins cannot act on other admins or
+// the owner.
+func (s *Service) AuthorizeOver(chatId, callerId, targetId uuid.UUID, perm Permission) error {
+	const op = "services.access.AuthorizeOver"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	callerRole, err := s.Role(chatId, callerId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !Can(callerRole, perm) {
+		log.Warn("role has no permission", slog.String("role", string(callerRole)),
+			slog.String("permission", string(perm)))
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	targetRole, err := s.Role(chatId, targetId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !Outranks(callerRole, targetRole) {
+		log.Warn("caller does not outrank target", slog.String("callerRole", string(callerRole)),
+			slog.String("targetRole", string(targetRole)))
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+	return nil
+}
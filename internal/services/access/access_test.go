@@ -0,0 +1,154 @@
+package access
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/access/mocks"
+	"os"
+	"testing"
+)
+
+func TestService_CheckMember(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewAccessService(slog.New(logHandler), mockRepository)
+
+	repoErr := errors.New("connection refused")
+
+	cases := []struct {
+		name            string
+		mockReturnRole  models.Role
+		mockReturnError error
+		expectedError   error
+	}{
+		{
+			name:           "Пользователь состоит в чате",
+			mockReturnRole: models.RoleMember,
+			expectedError:  nil,
+		},
+		{
+			name:          "Пользователь не состоит в чате",
+			expectedError: ErrForbidden,
+		},
+		{
+			name:            "Ошибка репозитория",
+			mockReturnError: repoErr,
+			expectedError:   repoErr,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			chatId, userId := uuid.New(), uuid.New()
+			mockRepository.On("GetRole", chatId, userId).Return(tt.mockReturnRole, tt.mockReturnError).Once()
+
+			err := service.CheckMember(chatId, userId)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewAccessService(slog.New(logHandler), mockRepository)
+
+	cases := []struct {
+		name          string
+		role          models.Role
+		perm          Permission
+		expectedError error
+	}{
+		{
+			name: "Владелец удаляет чат",
+			role: models.RoleOwner,
+			perm: PermDeleteChat,
+		},
+		{
+			name:          "Администратор удаляет чат",
+			role:          models.RoleAdmin,
+			perm:          PermDeleteChat,
+			expectedError: ErrForbidden,
+		},
+		{
+			name: "Администратор переименовывает чат",
+			role: models.RoleAdmin,
+			perm: PermRenameChat,
+		},
+		{
+			name:          "Участник удаляет чужие сообщения",
+			role:          models.RoleMember,
+			perm:          PermDeleteMessages,
+			expectedError: ErrForbidden,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			chatId, userId := uuid.New(), uuid.New()
+			mockRepository.On("GetRole", chatId, userId).Return(tt.role, nil).Once()
+
+			err := service.Authorize(chatId, userId, tt.perm)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestService_AuthorizeOver(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewAccessService(slog.New(logHandler), mockRepository)
+
+	cases := []struct {
+		name          string
+		callerRole    models.Role
+		targetRole    models.Role
+		expectedError error
+	}{
+		{
+			name:       "Администратор удаляет участника",
+			callerRole: models.RoleAdmin,
+			targetRole: models.RoleMember,
+		},
+		{
+			name:          "Администратор удаляет администратора",
+			callerRole:    models.RoleAdmin,
+			targetRole:    models.RoleAdmin,
+			expectedError: ErrForbidden,
+		},
+		{
+			name:          "Администратор удаляет владельца",
+			callerRole:    models.RoleAdmin,
+			targetRole:    models.RoleOwner,
+			expectedError: ErrForbidden,
+		},
+		{
+			name:       "Владелец удаляет администратора",
+			callerRole: models.RoleOwner,
+			targetRole: models.RoleAdmin,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			chatId, callerId, targetId := uuid.New(), uuid.New(), uuid.New()
+			mockRepository.On("GetRole", chatId, callerId).Return(tt.callerRole, nil).Once()
+			mockRepository.On("GetRole", chatId, targetId).Return(tt.targetRole, nil).Once()
+
+			err := service.AuthorizeOver(chatId, callerId, targetId, PermRemoveMembers)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
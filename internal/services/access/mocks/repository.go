@@ -0,0 +1,58 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "messenger/internal/domain/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	uuid "github.com/google/uuid"
+)
+
+// Repository is an autogenerated mock type for the Repository type
+type Repository struct {
+	mock.Mock
+}
+
+// GetRole provides a mock function with given fields: chatId, userId
+func (_m *Repository) GetRole(chatId uuid.UUID, userId uuid.UUID) (models.Role, error) {
+	ret := _m.Called(chatId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRole")
+	}
+
+	var r0 models.Role
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Role, error)); ok {
+		return rf(chatId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Role); ok {
+		r0 = rf(chatId, userId)
+	} else {
+		r0 = ret.Get(0).(models.Role)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Repository {
+	mock := &Repository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
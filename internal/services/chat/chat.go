@@ -10,11 +10,20 @@ import (
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/internal/services/access"
 	"messenger/pkg/mapper"
 	"net/http"
 	"sync"
 )
 
+// ErrInvalidRole is returned when a role change asks for an unknown role or
+// targets the caller itself.
+var ErrInvalidRole = errors.New("invalid role")
+
+// ErrInvalidPin is returned when the message to pin is not in the main
+// timeline of the chat or has been deleted.
+var ErrInvalidPin = errors.New("invalid pin")
+
 //go:generate mockery --name=CacheRepository --output=./mocks --case=underscore
 type CacheRepository interface {
 	Add(chat models.Chat, personIds []uuid.UUID) error
@@ -22,31 +31,61 @@ type CacheRepository interface {
 
 //go:generate mockery --name=Repository --output=./mocks --case=underscore
 type Repository interface {
-	Add(chat models.Chat, personIds []uuid.UUID) (uuid.UUID, error)
+	Add(chat models.Chat, ownerId uuid.UUID, personIds []uuid.UUID) (uuid.UUID, error)
 	AddNewUser(chatId uuid.UUID, userId uuid.UUID) error
 	RemoveUser(chatId uuid.UUID, userId uuid.UUID) error
 	GetUserChats(userId uuid.UUID) ([]uuid.UUID, error)
 	GetChatIds(userId uuid.UUID, offset, limit uint) ([]uuid.UUID, error)
-	GetInfoChat(chatId uuid.UUID) (domain.GetChat, error)
+	// GetInfoChat returns the chat as seen by the user: its last visible
+	// message and how many messages from others the user has not read yet.
+	GetInfoChat(chatId, userId uuid.UUID) (domain.GetChat, error)
+	GetUsers(chatId uuid.UUID) ([]uuid.UUID, error)
+	GetMembers(chatId uuid.UUID) ([]models.ChatMember, error)
+	SetRole(chatId, userId uuid.UUID, role models.Role) error
+	TransferOwnership(chatId, fromId, toId uuid.UUID) error
 	Update(chat models.Chat) error
 	Delete(chatId, userId uuid.UUID) error
+	IsPinnable(chatId, messageId uuid.UUID) (bool, error)
+	Pin(chatId, messageId, userId uuid.UUID) (bool, error)
+	Unpin(chatId, messageId uuid.UUID) (bool, error)
+	GetPins(chatId uuid.UUID) ([]models.Pin, error)
+}
+
+//go:generate mockery --name=AccessService --output=./mocks --case=underscore
+type AccessService interface {
+	CheckMember(chatId, userId uuid.UUID) error
+	Role(chatId, userId uuid.UUID) (models.Role, error)
+	Authorize(chatId, userId uuid.UUID, perm access.Permission) error
+	AuthorizeOver(chatId, callerId, targetId uuid.UUID, perm access.Permission) error
+}
+
+//go:generate mockery --name=QuoteService --output=./mocks --case=underscore
+type QuoteService interface {
+	// AttachQuotes sets the quote preview of every reply among messages.
+	AttachQuotes(messages []models.Message) error
 }
 
 type Service struct {
 	log             *slog.Logger
 	repository      Repository
 	cacheRepository CacheRepository
+	access          AccessService
+	quotes          QuoteService
 }
 
-func NewChatService(log *slog.Logger, repository Repository, cacheRepository CacheRepository) *Service {
+func NewChatService(log *slog.Logger, repository Repository, cacheRepository CacheRepository,
+	access AccessService, quotes QuoteService) *Service {
 	return &Service{
 		log:             log,
 		repository:      repository,
 		cacheRepository: cacheRepository,
+		access:          access,
+		quotes:          quotes,
 	}
 }
 
-func (c *Service) Add(addChat domain.AddChat) (uuid.UUID, error) {
+// Add creates the chat with ownerId as its owner and the other persons as members.
+func (c *Service) Add(ownerId uuid.UUID, addChat domain.AddChat) (uuid.UUID, error) {
 	const op = "service.chat.Add"
 	log := c.log.With(
 		slog.String("op", op),
@@ -58,7 +97,7 @@ func (c *Service) Add(addChat domain.AddChat) (uuid.UUID, error) {
 	log.Info("successfully mapped addChat to Chat")
 
 	log.Info("adding new chat")
-	id, err := c.repository.Add(chat, addChat.PersonIds)
+	id, err := c.repository.Add(chat, ownerId, addChat.PersonIds)
 	if err != nil {
 		log.Error("Error with adding chat to repository:", slog.String("err", err.Error()))
 		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
@@ -68,12 +107,16 @@ func (c *Service) Add(addChat domain.AddChat) (uuid.UUID, error) {
 	return id, nil
 }
 
-func (c *Service) AddNewUser(chatId uuid.UUID, userId uuid.UUID) error {
+func (c *Service) AddNewUser(callerId, chatId, userId uuid.UUID) error {
 	const op = "service.chat.AddNewUser"
 	log := c.log.With(
 		slog.String("op", op),
 	)
 
+	if err := c.access.Authorize(chatId, callerId, access.PermAddMembers); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("adding new user to chat")
 	err := c.repository.AddNewUser(chatId, userId)
 	if err != nil {
@@ -85,12 +128,25 @@ func (c *Service) AddNewUser(chatId uuid.UUID, userId uuid.UUID) error {
 	return nil
 }
 
-func (c *Service) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
+// RemoveUser removes userId from the chat. Removing yourself means leaving
+// the chat, which every member may do; removing others needs a role above theirs.
+func (c *Service) RemoveUser(callerId, chatId, userId uuid.UUID) error {
 	const op = "service.chat.RemoveUser"
 	log := c.log.With(
 		slog.String("op", op),
 	)
 
+	if callerId == userId {
+		if err := c.leave(chatId, userId); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+
+	if err := c.access.AuthorizeOver(chatId, callerId, userId, access.PermRemoveMembers); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("removing user from chat")
 	err := c.repository.RemoveUser(chatId, userId)
 	if err != nil {
@@ -102,6 +158,217 @@ func (c *Service) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
 	return nil
 }
 
+// leave removes the user from the chat. An owner hands the chat over to the
+// longest-standing admin, or member if there are no admins, before leaving;
+// the last member leaving deletes the chat.
+func (c *Service) leave(chatId, userId uuid.UUID) error {
+	const op = "service.chat.leave"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	role, err := c.access.Role(chatId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role == models.RoleOwner {
+		members, err := c.repository.GetMembers(chatId)
+		if err != nil {
+			log.Error("Error with getting members of chat:", slog.String("err", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		successor, ok := nextOwner(members, userId)
+		if !ok {
+			log.Info("last member left, deleting chat")
+			if err = c.repository.Delete(chatId, userId); err != nil {
+				log.Error("Error with deleting chat:", slog.String("err", err.Error()))
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+
+		log.Info("transferring ownership", slog.String("to", successor.String()))
+		if err = c.repository.TransferOwnership(chatId, userId, successor); err != nil {
+			log.Error("Error with transferring ownership:", slog.String("err", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("leaving chat")
+	if err = c.repository.RemoveUser(chatId, userId); err != nil {
+		log.Error("Error with removing user from repository:", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// nextOwner picks who inherits the chat when the owner leaves: the admin who
+// joined first, otherwise the member who joined first.
+func nextOwner(members []models.ChatMember, ownerId uuid.UUID) (uuid.UUID, bool) {
+	var next *models.ChatMember
+	for i := range members {
+		m := &members[i]
+		if m.PersonId == ownerId {
+			continue
+		}
+		if next == nil || access.Outranks(m.Role, next.Role) ||
+			(m.Role == next.Role && m.JoinedAt.Before(next.JoinedAt)) {
+			next = m
+		}
+	}
+
+	if next == nil {
+		return uuid.Nil, false
+	}
+	return next.PersonId, true
+}
+
+// ChangeRole sets the role of userId in the chat. Only the owner may change
+// roles; giving someone the owner role transfers ownership and makes the
+// previous owner an admin.
+func (c *Service) ChangeRole(callerId, chatId, userId uuid.UUID, role models.Role) error {
+	const op = "services.chat.ChangeRole"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	switch role {
+	case models.RoleOwner, models.RoleAdmin, models.RoleMember:
+	default:
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	if callerId == userId {
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+
+	if err := c.access.AuthorizeOver(chatId, callerId, userId, access.PermChangeRoles); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var err error
+	log.Info("changing role", slog.String("role", string(role)))
+	if role == models.RoleOwner {
+		err = c.repository.TransferOwnership(chatId, callerId, userId)
+	} else {
+		err = c.repository.SetRole(chatId, userId, role)
+	}
+	if err != nil {
+		log.Error("error with changing role:", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("successfully changed role")
+
+	return nil
+}
+
+func (c *Service) GetMembers(callerId, chatId uuid.UUID) ([]models.ChatMember, error) {
+	const op = "services.chat.GetMembers"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	if err := c.access.CheckMember(chatId, callerId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("getting members of chat")
+	members, err := c.repository.GetMembers(chatId)
+	if err != nil {
+		log.Error("error with getting members of chat:", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("members received")
+
+	return members, nil
+}
+
+// Pin pins the message in the chat on behalf of a member. The returned flag
+// tells whether the message was not pinned before, so that only real changes
+// are announced.
+func (c *Service) Pin(callerId, chatId, messageId uuid.UUID) (domain.PinPayload, bool, error) {
+	const op = "services.chat.Pin"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	if err := c.access.CheckMember(chatId, callerId); err != nil {
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pinnable, err := c.repository.IsPinnable(chatId, messageId)
+	if err != nil {
+		log.Error("error with checking message:", slog.String("err", err.Error()))
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !pinnable {
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, ErrInvalidPin)
+	}
+
+	log.Info("pinning message", slog.String("messageId", messageId.String()))
+	pinned, err := c.repository.Pin(chatId, messageId, callerId)
+	if err != nil {
+		log.Error("error with pinning message:", slog.String("err", err.Error()))
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return domain.PinPayload{
+		ChatId:    chatId,
+		MessageId: messageId,
+		PersonId:  callerId,
+		Pinned:    true,
+	}, pinned, nil
+}
+
+// Unpin unpins the message on behalf of a member and tells whether it was
+// pinned.
+func (c *Service) Unpin(callerId, chatId, messageId uuid.UUID) (domain.PinPayload, bool, error) {
+	const op = "services.chat.Unpin"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	if err := c.access.CheckMember(chatId, callerId); err != nil {
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("unpinning message", slog.String("messageId", messageId.String()))
+	unpinned, err := c.repository.Unpin(chatId, messageId)
+	if err != nil {
+		log.Error("error with unpinning message:", slog.String("err", err.Error()))
+		return domain.PinPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return domain.PinPayload{
+		ChatId:    chatId,
+		MessageId: messageId,
+		PersonId:  callerId,
+	}, unpinned, nil
+}
+
+// GetPins returns the pinned messages of the chat in the order they were pinned.
+func (c *Service) GetPins(callerId, chatId uuid.UUID) ([]models.Pin, error) {
+	const op = "services.chat.GetPins"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	if err := c.access.CheckMember(chatId, callerId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("getting pinned messages")
+	pins, err := c.repository.GetPins(chatId)
+	if err != nil {
+		log.Error("error with getting pinned messages:", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pins, nil
+}
+
 func (c *Service) GetInfoUserChats(userId uuid.UUID, page, count uint) ([]domain.GetChat, error) {
 	const op = "services.messenger.GetInfoUserChats"
 	log := c.log.With(
@@ -122,7 +389,7 @@ func (c *Service) GetInfoUserChats(userId uuid.UUID, page, count uint) ([]domain
 
 	for i, chatId := range chatsIds {
 		group.Go(func() error {
-			info, err := c.getInfoChat(ctx, chatId)
+			info, err := c.getInfoChat(ctx, chatId, userId)
 			if err != nil {
 				return err
 			}
@@ -138,16 +405,38 @@ func (c *Service) GetInfoUserChats(userId uuid.UUID, page, count uint) ([]domain
 		log.Error("Error with getting user's chats:", slog.String("err", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	if err = c.attachQuotes(chats); err != nil {
+		log.Error("Error with getting quotes:", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 	log.Info("successfully got user's chats")
 	return chats, nil
 }
 
-func (c *Service) getInfoChat(ctx context.Context, chatId uuid.UUID) (domain.GetChat, error) {
+// attachQuotes sets the quote preview of every last message that is a reply,
+// the same way history shows it.
+func (c *Service) attachQuotes(chats []domain.GetChat) error {
+	messages := make([]models.Message, len(chats))
+	for i, chat := range chats {
+		messages[i] = chat.LastMessage
+	}
+
+	if err := c.quotes.AttachQuotes(messages); err != nil {
+		return err
+	}
+	for i := range chats {
+		chats[i].LastMessage.Quote = messages[i].Quote
+	}
+	return nil
+}
+
+func (c *Service) getInfoChat(ctx context.Context, chatId, userId uuid.UUID) (domain.GetChat, error) {
 	select {
 	case <-ctx.Done():
 		return domain.GetChat{}, ctx.Err()
 	default:
-		info, err := c.repository.GetInfoChat(chatId)
+		info, err := c.repository.GetInfoChat(chatId, userId)
 		if err != nil {
 			return domain.GetChat{}, err
 		}
@@ -155,8 +444,25 @@ func (c *Service) getInfoChat(ctx context.Context, chatId uuid.UUID) (domain.Get
 	}
 }
 
-func (c *Service) GetUsers(chatId uuid.UUID) ([]uuid.UUID, error) {
-	panic("implement me")
+func (c *Service) GetUsers(callerId, chatId uuid.UUID) ([]uuid.UUID, error) {
+	const op = "services.messenger.GetUsers"
+	log := c.log.With(
+		slog.String("op", op),
+	)
+
+	if err := c.access.CheckMember(chatId, callerId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("getting users of chat")
+	users, err := c.repository.GetUsers(chatId)
+	if err != nil {
+		log.Error("error with getting users of chat:", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("users received")
+
+	return users, nil
 }
 
 func (c *Service) GetUserChats(userId uuid.UUID) ([]uuid.UUID, error) {
@@ -204,12 +510,16 @@ func (c *Service) GetUserInfo(id uuid.UUID) (domain.UserInfo, error) {
 	return user, nil
 }
 
-func (c *Service) Update(chat models.Chat) error {
+func (c *Service) Update(callerId uuid.UUID, chat models.Chat) error {
 	const op = "services.messenger.Update"
 	log := c.log.With(
 		slog.String("op", op),
 	)
 
+	if err := c.access.Authorize(chat.Id, callerId, access.PermRenameChat); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("updating chat")
 	err := c.repository.Update(chat)
 	if err != nil {
@@ -227,6 +537,10 @@ func (c *Service) Delete(chatId, userId uuid.UUID) error {
 		slog.String("op", op),
 	)
 
+	if err := c.access.Authorize(chatId, userId, access.PermDeleteChat); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("deleting chat")
 	err := c.repository.Delete(chatId, userId)
 	if err != nil {
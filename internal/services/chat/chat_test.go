@@ -7,9 +7,11 @@ import (
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/internal/services/access"
 	"messenger/internal/services/chat/mocks"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestService_Add(t *testing.T) {
@@ -23,8 +25,9 @@ func TestService_Add(t *testing.T) {
 
 	mockCacheRepository := mocks.NewCacheRepository(t)
 	mockRepository := mocks.NewRepository(t)
+	mockAccess := mocks.NewAccessService(t)
 
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 
 	chatId := uuid.New()
 	personsIds := []uuid.UUID{uuid.New(), uuid.New()}
@@ -56,10 +59,10 @@ func TestService_Add(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			mockRepository.On("Add", mock.AnythingOfType("models.Chat"),
+			mockRepository.On("Add", mock.AnythingOfType("models.Chat"), personsIds[0],
 				mock.AnythingOfType("[]uuid.UUID")).Return(c.mockReturnId, c.mockReturnError).Once()
 
-			id, err := service.Add(c.input.addChat)
+			id, err := service.Add(personsIds[0], c.input.addChat)
 			require.Equal(t, c.expectedId, id)
 			require.Equal(t, c.expectedError, err)
 		})
@@ -68,7 +71,7 @@ func TestService_Add(t *testing.T) {
 
 func TestService_AddNewUser(t *testing.T) {
 	type args struct {
-		chatId, userId uuid.UUID
+		callerId, chatId, userId uuid.UUID
 	}
 
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -77,40 +80,58 @@ func TestService_AddNewUser(t *testing.T) {
 
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
 
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 
 	cases := []struct {
-		name            string
-		input           args
-		mockReturnError error
-		expectedError   error
+		name             string
+		input            args
+		mockAccessError  error
+		mockReturnError  error
+		expectedError    error
+		expectedRepoCall bool
 	}{
 		{
 			name: "Успешное добавление пользователя в чат",
 			input: args{
-				chatId: uuid.New(),
-				userId: uuid.New(),
+				callerId: uuid.New(),
+				chatId:   uuid.New(),
+				userId:   uuid.New(),
 			},
-			mockReturnError: nil,
-			expectedError:   nil,
+			mockReturnError:  nil,
+			expectedError:    nil,
+			expectedRepoCall: true,
+		},
+		{
+			name: "Добавление пользователя не участником чата",
+			input: args{
+				callerId: uuid.New(),
+				chatId:   uuid.New(),
+				userId:   uuid.New(),
+			},
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
 		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			mockRepository.On("AddNewUser", mock.AnythingOfType("uuid.UUID"),
-				mock.AnythingOfType("uuid.UUID")).Return(tt.mockReturnError).Once()
+			mockAccess.On("Authorize", tt.input.chatId, tt.input.callerId, access.PermAddMembers).
+				Return(tt.mockAccessError).Once()
+			if tt.expectedRepoCall {
+				mockRepository.On("AddNewUser", tt.input.chatId, tt.input.userId).Return(tt.mockReturnError).Once()
+			}
 
-			err := service.AddNewUser(tt.input.chatId, tt.input.userId)
-			require.Equal(t, tt.expectedError, err)
+			err := service.AddNewUser(tt.input.callerId, tt.input.chatId, tt.input.userId)
+			require.ErrorIs(t, err, tt.expectedError)
 		})
 	}
 }
 
 func TestService_RemoveUser(t *testing.T) {
 	type args struct {
-		chatId, userId uuid.UUID
+		callerId, chatId, userId uuid.UUID
 	}
 
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -119,7 +140,8 @@ func TestService_RemoveUser(t *testing.T) {
 
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 
 	cases := []struct {
 		name            string
@@ -130,8 +152,9 @@ func TestService_RemoveUser(t *testing.T) {
 		{
 			name: "Успешное удаление пользователя из чата",
 			input: args{
-				chatId: uuid.New(),
-				userId: uuid.New(),
+				callerId: uuid.New(),
+				chatId:   uuid.New(),
+				userId:   uuid.New(),
 			},
 			mockReturnError: nil,
 			expectedError:   nil,
@@ -140,15 +163,217 @@ func TestService_RemoveUser(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			mockRepository.On("RemoveUser", mock.AnythingOfType("uuid.UUID"),
-				mock.AnythingOfType("uuid.UUID")).Return(tt.mockReturnError).Once()
+			mockAccess.On("AuthorizeOver", tt.input.chatId, tt.input.callerId, tt.input.userId,
+				access.PermRemoveMembers).Return(nil).Once()
+			mockRepository.On("RemoveUser", tt.input.chatId, tt.input.userId).Return(tt.mockReturnError).Once()
 
-			err := service.RemoveUser(tt.input.chatId, tt.input.userId)
+			err := service.RemoveUser(tt.input.callerId, tt.input.chatId, tt.input.userId)
 			require.Equal(t, tt.expectedError, err)
 		})
 	}
 }
 
+func TestService_Leave(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	userId := uuid.New()
+	oldAdmin, newAdmin := uuid.New(), uuid.New()
+	oldMember := uuid.New()
+	now := time.Now()
+
+	cases := []struct {
+		name              string
+		role              models.Role
+		members           []models.ChatMember
+		expectedSuccessor uuid.UUID
+		expectedDelete    bool
+	}{
+		{
+			name: "Участник покидает чат",
+			role: models.RoleMember,
+		},
+		{
+			name: "Владелец передаёт чат самому старому администратору",
+			role: models.RoleOwner,
+			members: []models.ChatMember{
+				{PersonId: oldMember, Role: models.RoleMember, JoinedAt: now.Add(-3 * time.Hour)},
+				{PersonId: userId, Role: models.RoleOwner, JoinedAt: now.Add(-3 * time.Hour)},
+				{PersonId: newAdmin, Role: models.RoleAdmin, JoinedAt: now.Add(-time.Hour)},
+				{PersonId: oldAdmin, Role: models.RoleAdmin, JoinedAt: now.Add(-2 * time.Hour)},
+			},
+			expectedSuccessor: oldAdmin,
+		},
+		{
+			name: "Владелец без администраторов передаёт чат участнику",
+			role: models.RoleOwner,
+			members: []models.ChatMember{
+				{PersonId: userId, Role: models.RoleOwner, JoinedAt: now.Add(-3 * time.Hour)},
+				{PersonId: oldMember, Role: models.RoleMember, JoinedAt: now.Add(-2 * time.Hour)},
+			},
+			expectedSuccessor: oldMember,
+		},
+		{
+			name: "Последний участник удаляет чат",
+			role: models.RoleOwner,
+			members: []models.ChatMember{
+				{PersonId: userId, Role: models.RoleOwner, JoinedAt: now},
+			},
+			expectedDelete: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			chatId := uuid.New()
+			mockAccess.On("Role", chatId, userId).Return(tt.role, nil).Once()
+			if tt.role == models.RoleOwner {
+				mockRepository.On("GetMembers", chatId).Return(tt.members, nil).Once()
+			}
+			switch {
+			case tt.expectedDelete:
+				mockRepository.On("Delete", chatId, userId).Return(nil).Once()
+			case tt.expectedSuccessor != uuid.Nil:
+				mockRepository.On("TransferOwnership", chatId, userId, tt.expectedSuccessor).Return(nil).Once()
+				fallthrough
+			default:
+				mockRepository.On("RemoveUser", chatId, userId).Return(nil).Once()
+			}
+
+			err := service.RemoveUser(userId, chatId, userId)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestService_ChangeRole(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	callerId, userId := uuid.New(), uuid.New()
+
+	cases := []struct {
+		name            string
+		userId          uuid.UUID
+		role            models.Role
+		mockAccessError error
+		expectedAccess  bool
+		expectedError   error
+	}{
+		{
+			name:           "Назначение администратора",
+			userId:         userId,
+			role:           models.RoleAdmin,
+			expectedAccess: true,
+		},
+		{
+			name:           "Передача владения",
+			userId:         userId,
+			role:           models.RoleOwner,
+			expectedAccess: true,
+		},
+		{
+			name:            "Смена роли без прав",
+			userId:          userId,
+			role:            models.RoleMember,
+			mockAccessError: access.ErrForbidden,
+			expectedAccess:  true,
+			expectedError:   access.ErrForbidden,
+		},
+		{
+			name:          "Неизвестная роль",
+			userId:        userId,
+			role:          "moderator",
+			expectedError: ErrInvalidRole,
+		},
+		{
+			name:          "Смена собственной роли",
+			userId:        callerId,
+			role:          models.RoleMember,
+			expectedError: ErrInvalidRole,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			chatId := uuid.New()
+			if tt.expectedAccess {
+				mockAccess.On("AuthorizeOver", chatId, callerId, tt.userId, access.PermChangeRoles).
+					Return(tt.mockAccessError).Once()
+			}
+			if tt.expectedError == nil {
+				if tt.role == models.RoleOwner {
+					mockRepository.On("TransferOwnership", chatId, callerId, tt.userId).Return(nil).Once()
+				} else {
+					mockRepository.On("SetRole", chatId, tt.userId, tt.role).Return(nil).Once()
+				}
+			}
+
+			err := service.ChangeRole(callerId, chatId, tt.userId, tt.role)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestService_GetUsers(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	callerId := uuid.New()
+	chatId := uuid.New()
+	users := []uuid.UUID{callerId, uuid.New()}
+
+	cases := []struct {
+		name            string
+		mockAccessError error
+		mockReturnUsers []uuid.UUID
+		expectedUsers   []uuid.UUID
+		expectedError   error
+	}{
+		{
+			name:            "Успешное получение участников чата",
+			mockReturnUsers: users,
+			expectedUsers:   users,
+		},
+		{
+			name:            "Получение участников не участником чата",
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccess.On("CheckMember", chatId, callerId).Return(tt.mockAccessError).Once()
+			if tt.mockAccessError == nil {
+				mockRepository.On("GetUsers", chatId).Return(tt.mockReturnUsers, nil).Once()
+			}
+
+			ids, err := service.GetUsers(callerId, chatId)
+			require.Equal(t, tt.expectedUsers, ids)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
 func TestService_GetUserChats(t *testing.T) {
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
@@ -156,8 +381,9 @@ func TestService_GetUserChats(t *testing.T) {
 
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
 
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 
 	chatId := uuid.New()
 
@@ -199,8 +425,9 @@ func TestService_Update(t *testing.T) {
 	})
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
 
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 
 	cases := []struct {
 		name            string
@@ -223,8 +450,10 @@ func TestService_Update(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
+			callerId := uuid.New()
+			mockAccess.On("Authorize", tt.input.chat.Id, callerId, access.PermRenameChat).Return(nil).Once()
 			mockRepository.On("Update", tt.input.chat).Return(tt.mockReturnError).Once()
-			err := service.Update(tt.input.chat)
+			err := service.Update(callerId, tt.input.chat)
 			require.Equal(t, tt.expectedError, err)
 		})
 	}
@@ -240,8 +469,9 @@ func TestService_Delete(t *testing.T) {
 	})
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
 
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
 	cases := []struct {
 		name            string
 		input           args
@@ -261,6 +491,7 @@ func TestService_Delete(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
+			mockAccess.On("Authorize", tt.input.chatId, tt.input.userId, access.PermDeleteChat).Return(nil).Once()
 			mockRepository.On("Delete", tt.input.chatId, tt.input.userId).Return(tt.mockReturnError).Once()
 			err := service.Delete(tt.input.chatId, tt.input.userId)
 			require.Equal(t, tt.expectedError, err)
@@ -279,7 +510,12 @@ func TestService_GetInfoUserChats(t *testing.T) {
 	})
 	mockRepository := mocks.NewRepository(t)
 	mockCacheRepository := mocks.NewCacheRepository(t)
-	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository)
+	mockAccess := mocks.NewAccessService(t)
+	mockQuotes := mocks.NewQuoteService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, mockQuotes)
+
+	replyTo := uuid.New()
+	quote := models.Quote{Id: replyTo, MessageText: "Quoted"}
 
 	cases := []struct {
 		name                    string
@@ -306,6 +542,7 @@ func TestService_GetInfoUserChats(t *testing.T) {
 					LastMessage: models.Message{
 						MessageText: "Message1",
 					},
+					UnreadCount: 3,
 				},
 			},
 			mockReturnInfoChatError: []error{
@@ -317,19 +554,61 @@ func TestService_GetInfoUserChats(t *testing.T) {
 					LastMessage: models.Message{
 						MessageText: "Message1",
 					},
+					UnreadCount: 3,
+				},
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Последнее сообщение с цитатой",
+			input: args{
+				userId: uuid.New(),
+				page:   1,
+				count:  1,
+			},
+			mockReturnChatsId: []uuid.UUID{uuid.New()},
+			mockReturnInfoChats: []domain.GetChat{
+				{
+					Name: "Chat2",
+					LastMessage: models.Message{
+						MessageText: "Reply",
+						ReplyTo:     &replyTo,
+					},
+				},
+			},
+			mockReturnInfoChatError: []error{
+				nil,
+			},
+			expectedChats: []domain.GetChat{
+				{
+					Name: "Chat2",
+					LastMessage: models.Message{
+						MessageText: "Reply",
+						ReplyTo:     &replyTo,
+						Quote:       &quote,
+					},
 				},
 			},
 			expectedError: nil,
 		},
 	}
 
+	mockQuotes.On("AttachQuotes", mock.AnythingOfType("[]models.Message")).Run(func(args mock.Arguments) {
+		messages := args.Get(0).([]models.Message)
+		for i := range messages {
+			if messages[i].ReplyTo != nil && *messages[i].ReplyTo == replyTo {
+				messages[i].Quote = &quote
+			}
+		}
+	}).Return(nil)
+
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepository.On("GetChatIds", tt.input.userId, tt.input.page,
 				tt.input.count).Return(tt.mockReturnChatsId, tt.mockReturnChatsIdError).Once()
 
 			for i, id := range tt.mockReturnChatsId {
-				mockRepository.On("GetInfoChat", id).
+				mockRepository.On("GetInfoChat", id, tt.input.userId).
 					Return(tt.mockReturnInfoChats[i], tt.mockReturnInfoChatError[i]).Once()
 			}
 
@@ -339,3 +618,169 @@ func TestService_GetInfoUserChats(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Pin(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	callerId := uuid.New()
+	chatId := uuid.New()
+	messageId := uuid.New()
+
+	cases := []struct {
+		name            string
+		mockAccessError error
+		mockPinnable    bool
+		mockPinned      bool
+		expectedChanged bool
+		expectedError   error
+	}{
+		{
+			name:            "Успешное закрепление сообщения",
+			mockPinnable:    true,
+			mockPinned:      true,
+			expectedChanged: true,
+		},
+		{
+			name:         "Повторное закрепление сообщения",
+			mockPinnable: true,
+		},
+		{
+			name:          "Закрепление сообщения из другого чата",
+			expectedError: ErrInvalidPin,
+		},
+		{
+			name:            "Закрепление сообщения не участником чата",
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccess.On("CheckMember", chatId, callerId).Return(tt.mockAccessError).Once()
+			if tt.mockAccessError == nil {
+				mockRepository.On("IsPinnable", chatId, messageId).Return(tt.mockPinnable, nil).Once()
+			}
+			if tt.mockPinnable {
+				mockRepository.On("Pin", chatId, messageId, callerId).Return(tt.mockPinned, nil).Once()
+			}
+
+			payload, changed, err := service.Pin(callerId, chatId, messageId)
+			require.ErrorIs(t, err, tt.expectedError)
+			require.Equal(t, tt.expectedChanged, changed)
+			if tt.expectedError == nil {
+				require.Equal(t, domain.PinPayload{
+					ChatId:    chatId,
+					MessageId: messageId,
+					PersonId:  callerId,
+					Pinned:    true,
+				}, payload)
+			}
+		})
+	}
+}
+
+func TestService_Unpin(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	callerId := uuid.New()
+	chatId := uuid.New()
+	messageId := uuid.New()
+
+	cases := []struct {
+		name            string
+		mockAccessError error
+		mockUnpinned    bool
+		expectedError   error
+	}{
+		{
+			name:         "Успешное открепление сообщения",
+			mockUnpinned: true,
+		},
+		{
+			name: "Открепление незакреплённого сообщения",
+		},
+		{
+			name:            "Открепление сообщения не участником чата",
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccess.On("CheckMember", chatId, callerId).Return(tt.mockAccessError).Once()
+			if tt.mockAccessError == nil {
+				mockRepository.On("Unpin", chatId, messageId).Return(tt.mockUnpinned, nil).Once()
+			}
+
+			payload, changed, err := service.Unpin(callerId, chatId, messageId)
+			require.ErrorIs(t, err, tt.expectedError)
+			require.Equal(t, tt.mockUnpinned, changed)
+			require.False(t, payload.Pinned)
+		})
+	}
+}
+
+func TestService_GetPins(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockCacheRepository := mocks.NewCacheRepository(t)
+	mockAccess := mocks.NewAccessService(t)
+	service := NewChatService(slog.New(logHandler), mockRepository, mockCacheRepository, mockAccess, nil)
+
+	callerId := uuid.New()
+	chatId := uuid.New()
+	now := time.Now()
+	pins := []models.Pin{
+		{Message: models.Message{Id: uuid.New()}, PinnedBy: callerId, PinnedAt: now.Add(-time.Minute)},
+		{Message: models.Message{Id: uuid.New()}, PinnedBy: uuid.New(), PinnedAt: now},
+	}
+
+	cases := []struct {
+		name            string
+		mockAccessError error
+		expectedPins    []models.Pin
+		expectedError   error
+	}{
+		{
+			name:         "Успешное получение закреплённых сообщений",
+			expectedPins: pins,
+		},
+		{
+			name:            "Получение закреплённых сообщений не участником чата",
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccess.On("CheckMember", chatId, callerId).Return(tt.mockAccessError).Once()
+			if tt.mockAccessError == nil {
+				mockRepository.On("GetPins", chatId).Return(pins, nil).Once()
+			}
+
+			got, err := service.GetPins(callerId, chatId)
+			require.Equal(t, tt.expectedPins, got)
+			require.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	access "messenger/internal/services/access"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "messenger/internal/domain/models"
+
+	uuid "github.com/google/uuid"
+)
+
+// AccessService is an autogenerated mock type for the AccessService type
+type AccessService struct {
+	mock.Mock
+}
+
+// Authorize provides a mock function with given fields: chatId, userId, perm
+func (_m *AccessService) Authorize(chatId uuid.UUID, userId uuid.UUID, perm access.Permission) error {
+	ret := _m.Called(chatId, userId, perm)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Authorize")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, access.Permission) error); ok {
+		r0 = rf(chatId, userId, perm)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AuthorizeOver provides a mock function with given fields: chatId, callerId, targetId, perm
+func (_m *AccessService) AuthorizeOver(chatId uuid.UUID, callerId uuid.UUID, targetId uuid.UUID, perm access.Permission) error {
+	ret := _m.Called(chatId, callerId, targetId, perm)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AuthorizeOver")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID, access.Permission) error); ok {
+		r0 = rf(chatId, callerId, targetId, perm)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckMember provides a mock function with given fields: chatId, userId
+func (_m *AccessService) CheckMember(chatId uuid.UUID, userId uuid.UUID) error {
+	ret := _m.Called(chatId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckMember")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(chatId, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Role provides a mock function with given fields: chatId, userId
+func (_m *AccessService) Role(chatId uuid.UUID, userId uuid.UUID) (models.Role, error) {
+	ret := _m.Called(chatId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Role")
+	}
+
+	var r0 models.Role
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Role, error)); ok {
+		return rf(chatId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Role); ok {
+		r0 = rf(chatId, userId)
+	} else {
+		r0 = ret.Get(0).(models.Role)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAccessService creates a new instance of AccessService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAccessService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AccessService {
+	mock := &AccessService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
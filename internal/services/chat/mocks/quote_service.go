@@ -0,0 +1,46 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "messenger/internal/domain/models"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// QuoteService is an autogenerated mock type for the QuoteService type
+type QuoteService struct {
+	mock.Mock
+}
+
+// AttachQuotes provides a mock function with given fields: messages
+func (_m *QuoteService) AttachQuotes(messages []models.Message) error {
+	ret := _m.Called(messages)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AttachQuotes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]models.Message) error); ok {
+		r0 = rf(messages)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewQuoteService creates a new instance of QuoteService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewQuoteService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *QuoteService {
+	mock := &QuoteService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
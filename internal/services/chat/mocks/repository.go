@@ -17,9 +17,9 @@ type Repository struct {
 	mock.Mock
 }
 
-// Add provides a mock function with given fields: _a0, personIds
-func (_m *Repository) Add(_a0 models.Chat, personIds []uuid.UUID) (uuid.UUID, error) {
-	ret := _m.Called(_a0, personIds)
+// Add provides a mock function with given fields: _a0, ownerId, personIds
+func (_m *Repository) Add(_a0 models.Chat, ownerId uuid.UUID, personIds []uuid.UUID) (uuid.UUID, error) {
+	ret := _m.Called(_a0, ownerId, personIds)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Add")
@@ -27,19 +27,19 @@ func (_m *Repository) Add(_a0 models.Chat, personIds []uuid.UUID) (uuid.UUID, er
 
 	var r0 uuid.UUID
 	var r1 error
-	if rf, ok := ret.Get(0).(func(models.Chat, []uuid.UUID) (uuid.UUID, error)); ok {
-		return rf(_a0, personIds)
+	if rf, ok := ret.Get(0).(func(models.Chat, uuid.UUID, []uuid.UUID) (uuid.UUID, error)); ok {
+		return rf(_a0, ownerId, personIds)
 	}
-	if rf, ok := ret.Get(0).(func(models.Chat, []uuid.UUID) uuid.UUID); ok {
-		r0 = rf(_a0, personIds)
+	if rf, ok := ret.Get(0).(func(models.Chat, uuid.UUID, []uuid.UUID) uuid.UUID); ok {
+		r0 = rf(_a0, ownerId, personIds)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(uuid.UUID)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(models.Chat, []uuid.UUID) error); ok {
-		r1 = rf(_a0, personIds)
+	if rf, ok := ret.Get(1).(func(models.Chat, uuid.UUID, []uuid.UUID) error); ok {
+		r1 = rf(_a0, ownerId, personIds)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -113,9 +113,9 @@ func (_m *Repository) GetChatIds(userId uuid.UUID, offset uint, limit uint) ([]u
 	return r0, r1
 }
 
-// GetInfoChat provides a mock function with given fields: chatId
-func (_m *Repository) GetInfoChat(chatId uuid.UUID) (domain.GetChat, error) {
-	ret := _m.Called(chatId)
+// GetInfoChat provides a mock function with given fields: chatId, userId
+func (_m *Repository) GetInfoChat(chatId uuid.UUID, userId uuid.UUID) (domain.GetChat, error) {
+	ret := _m.Called(chatId, userId)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetInfoChat")
@@ -123,13 +123,73 @@ func (_m *Repository) GetInfoChat(chatId uuid.UUID) (domain.GetChat, error) {
 
 	var r0 domain.GetChat
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) (domain.GetChat, error)); ok {
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (domain.GetChat, error)); ok {
+		return rf(chatId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) domain.GetChat); ok {
+		r0 = rf(chatId, userId)
+	} else {
+		r0 = ret.Get(0).(domain.GetChat)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMembers provides a mock function with given fields: chatId
+func (_m *Repository) GetMembers(chatId uuid.UUID) ([]models.ChatMember, error) {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMembers")
+	}
+
+	var r0 []models.ChatMember
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.ChatMember, error)); ok {
 		return rf(chatId)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID) domain.GetChat); ok {
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.ChatMember); ok {
 		r0 = rf(chatId)
 	} else {
-		r0 = ret.Get(0).(domain.GetChat)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ChatMember)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPins provides a mock function with given fields: chatId
+func (_m *Repository) GetPins(chatId uuid.UUID) ([]models.Pin, error) {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPins")
+	}
+
+	var r0 []models.Pin
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.Pin, error)); ok {
+		return rf(chatId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.Pin); ok {
+		r0 = rf(chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Pin)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
@@ -171,6 +231,92 @@ func (_m *Repository) GetUserChats(userId uuid.UUID) ([]uuid.UUID, error) {
 	return r0, r1
 }
 
+// GetUsers provides a mock function with given fields: chatId
+func (_m *Repository) GetUsers(chatId uuid.UUID) ([]uuid.UUID, error) {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsers")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]uuid.UUID, error)); ok {
+		return rf(chatId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []uuid.UUID); ok {
+		r0 = rf(chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsPinnable provides a mock function with given fields: chatId, messageId
+func (_m *Repository) IsPinnable(chatId uuid.UUID, messageId uuid.UUID) (bool, error) {
+	ret := _m.Called(chatId, messageId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsPinnable")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(chatId, messageId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(chatId, messageId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, messageId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pin provides a mock function with given fields: chatId, messageId, userId
+func (_m *Repository) Pin(chatId uuid.UUID, messageId uuid.UUID, userId uuid.UUID) (bool, error) {
+	ret := _m.Called(chatId, messageId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pin")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(chatId, messageId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(chatId, messageId, userId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, messageId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveUser provides a mock function with given fields: chatId, userId
 func (_m *Repository) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
 	ret := _m.Called(chatId, userId)
@@ -189,6 +335,70 @@ func (_m *Repository) RemoveUser(chatId uuid.UUID, userId uuid.UUID) error {
 	return r0
 }
 
+// SetRole provides a mock function with given fields: chatId, userId, role
+func (_m *Repository) SetRole(chatId uuid.UUID, userId uuid.UUID, role models.Role) error {
+	ret := _m.Called(chatId, userId, role)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, models.Role) error); ok {
+		r0 = rf(chatId, userId, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TransferOwnership provides a mock function with given fields: chatId, fromId, toId
+func (_m *Repository) TransferOwnership(chatId uuid.UUID, fromId uuid.UUID, toId uuid.UUID) error {
+	ret := _m.Called(chatId, fromId, toId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TransferOwnership")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(chatId, fromId, toId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Unpin provides a mock function with given fields: chatId, messageId
+func (_m *Repository) Unpin(chatId uuid.UUID, messageId uuid.UUID) (bool, error) {
+	ret := _m.Called(chatId, messageId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Unpin")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(chatId, messageId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(chatId, messageId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(chatId, messageId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: _a0
 func (_m *Repository) Update(_a0 models.Chat) error {
 	ret := _m.Called(_a0)
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// CollectFiles deletes the files that were not attached to any message within
+// the upload TTL and returns how many there were. Their blobs are released,
+// to be removed by CollectBlobs once no other file refers to them.
+func (s *Service) CollectFiles() (int, error) {
+	const op = "services.file.CollectFiles"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	before := time.Now().UTC().Add(-s.uploads.TTL)
+	collected := 0
+	for {
+		deleted, err := s.repository.DeleteUnattachedFiles(before, collectBatch)
+		if err != nil {
+			log.Error("error with deleting unattached files", slog.String("err", err.Error()))
+			return collected, fmt.Errorf("%s: %w", op, err)
+		}
+
+		collected += deleted
+		if deleted < collectBatch {
+			return collected, nil
+		}
+	}
+}
+
+// CollectBlobs removes the blobs that no file refers to any longer, with
+// their thumbnails, and returns how many there were. A blob is unreferenced
+// once the last message carrying one of its files is deleted.
+func (s *Service) CollectBlobs() (int, error) {
+	const op = "services.file.CollectBlobs"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	collected := 0
+	for {
+		keys, err := s.repository.GetUnreferencedBlobs(collectBatch)
+		if err != nil {
+			log.Error("error with getting unreferenced blobs", slog.String("err", err.Error()))
+			return collected, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, key := range keys {
+			if err = s.dropBlob(key); err != nil {
+				log.Error("error with dropping blob", slog.String("err", err.Error()))
+				return collected, fmt.Errorf("%s: %w", op, err)
+			}
+			collected++
+		}
+		if len(keys) < collectBatch {
+			return collected, nil
+		}
+	}
+}
+
+// dropBlob deletes the blob and its thumbnails from the blob store before it
+// is forgotten, so that a failure leaves the blob to be collected again.
+func (s *Service) dropBlob(key string) error {
+	for _, size := range thumbnailSizes {
+		if err := s.blobs.Delete(thumbnailKey(key, size)); err != nil {
+			return err
+		}
+	}
+	if err := s.blobs.Delete(key); err != nil {
+		return err
+	}
+	return s.repository.DeleteBlob(key)
+}
+
+// RunCollector collects expired uploads, unattached files and unreferenced
+// blobs every CollectInterval until the context is done. Blobs are collected
+// last, so that they include the blobs of the files just deleted.
+func (s *Service) RunCollector(ctx context.Context) {
+	const op = "services.file.RunCollector"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	ticker := time.NewTicker(s.uploads.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		collected, err := s.CollectUploads()
+		if err != nil {
+			log.Warn("Error with collecting uploads", slog.String("err", err.Error()))
+		} else if collected > 0 {
+			log.Info("expired uploads collected", slog.Int("count", collected))
+		}
+
+		collected, err = s.CollectFiles()
+		if err != nil {
+			log.Warn("Error with collecting files", slog.String("err", err.Error()))
+		} else if collected > 0 {
+			log.Info("unattached files collected", slog.Int("count", collected))
+		}
+
+		collected, err = s.CollectBlobs()
+		if err != nil {
+			log.Warn("Error with collecting blobs", slog.String("err", err.Error()))
+		} else if collected > 0 {
+			log.Info("unreferenced blobs collected", slog.Int("count", collected))
+		}
+	}
+}
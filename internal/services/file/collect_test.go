@@ -0,0 +1,55 @@
+package file
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"messenger/internal/services/file/mocks"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestService_CollectBlobs(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockBlobs := mocks.NewBlobStore(t)
+	service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, testUploadConfig)
+
+	key := storageKey(uuid.New())
+	mockRepository.On("GetUnreferencedBlobs", uint(collectBatch)).Return([]string{key}, nil).Once()
+	for _, size := range thumbnailSizes {
+		mockBlobs.On("Delete", thumbnailKey(key, size)).Return(nil).Once()
+	}
+	mockBlobs.On("Delete", key).Return(nil).Once()
+	mockRepository.On("DeleteBlob", key).Return(nil).Once()
+
+	collected, err := service.CollectBlobs()
+	require.NoError(t, err)
+	require.Equal(t, 1, collected)
+}
+
+func TestService_CollectFiles(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewFileService(slog.New(logHandler), mockRepository, mocks.NewBlobStore(t), testUploadConfig)
+
+	// Files uploaded within the TTL are kept for the message they are
+	// uploaded for.
+	expired := mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= testUploadConfig.TTL && time.Since(before) < testUploadConfig.TTL+time.Minute
+	})
+	mockRepository.On("DeleteUnattachedFiles", expired, uint(collectBatch)).Return(collectBatch, nil).Once()
+	mockRepository.On("DeleteUnattachedFiles", expired, uint(collectBatch)).Return(3, nil).Once()
+
+	collected, err := service.CollectFiles()
+	require.NoError(t, err)
+	require.Equal(t, collectBatch+3, collected)
+}
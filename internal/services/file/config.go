@@ -0,0 +1,16 @@
+package file
+
+import "time"
+
+// UploadConfig controls resumable uploads and the collection of unused files.
+type UploadConfig struct {
+	// MaxSize is the largest file an upload may carry, in bytes.
+	MaxSize int64 `yaml:"max_size" env-default:"1073741824"`
+	// TTL is how long an unfinished upload session is kept after its last
+	// chunk, and how long an uploaded file is kept while no message has
+	// attached it.
+	TTL time.Duration `yaml:"ttl" env-default:"24h"`
+	// CollectInterval is how often expired uploads, unattached files and
+	// unreferenced blobs are removed.
+	CollectInterval time.Duration `yaml:"collect_interval" env-default:"1h"`
+}
@@ -0,0 +1,408 @@
+package file
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for files that do not exist or that the user
+	// cannot see.
+	ErrNotFound = errors.New("file not found")
+	// ErrTooLarge is returned for uploads over MaxFileSize, or over the
+	// limits of resumable uploads.
+	ErrTooLarge = errors.New("file too large")
+	// ErrUnsupportedType is returned for uploads whose content is not of an
+	// allowed type.
+	ErrUnsupportedType = errors.New("unsupported file type")
+)
+
+const (
+	// MaxFileSize is the largest file that may be uploaded.
+	MaxFileSize = 20 << 20
+
+	// maxNameLength is how many characters of the uploaded name are kept.
+	maxNameLength = 255
+	// sniffLength is how much of the content is used to detect its type.
+	sniffLength = 512
+	// moveBatch is how many files kept in the database are moved per query.
+	moveBatch = 100
+)
+
+// allowedTypes are the media types accepted for upload, by the type detected
+// from the content rather than the one the client claims.
+var allowedTypes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"application/pdf",
+	"application/zip",
+	"application/x-gzip",
+	"text/plain",
+}
+
+//go:generate mockery --name=Repository --output=./mocks --case=underscore
+type Repository interface {
+	// Add stores the file and returns it as stored. A file whose content
+	// was stored before refers to the blob of that content instead of its
+	// own, and shares its preview.
+	Add(file models.File) (models.File, error)
+	// GetAccessible returns the file and true if the user uploaded it or is
+	// a member of a chat with a message that carries it.
+	GetAccessible(id, userId uuid.UUID) (models.File, bool, error)
+	// GetLegacyFiles, GetLegacyContent and SetStorageKey serve files whose
+	// content was stored in the database, until it is moved to the blob
+	// store. SetStorageKey returns the key of the blob the file refers to.
+	// Once MoveLegacyFiles finds nothing left to move, the files.file column
+	// is to be dropped along with them.
+	GetLegacyFiles(limit uint) ([]uuid.UUID, error)
+	GetLegacyContent(id uuid.UUID) ([]byte, error)
+	SetStorageKey(id uuid.UUID, key string) (string, error)
+	SetPreview(file models.File) error
+
+	AddUpload(upload models.Upload) error
+	// GetUpload returns the upload and true if it belongs to the user.
+	GetUpload(id, userId uuid.UUID) (models.Upload, bool, error)
+	// AddChunk records the chunk and moves the offset of its upload past it.
+	// It reports false if the offset of the upload is no longer the start of
+	// the chunk.
+	AddChunk(chunk models.UploadChunk, expiresAt time.Time) (bool, error)
+	GetChunks(id uuid.UUID) ([]models.UploadChunk, error)
+	// CompleteUpload adds the file and drops the upload it was assembled from.
+	CompleteUpload(id uuid.UUID, file models.File) (models.File, error)
+	DeleteUpload(id uuid.UUID) error
+	GetExpiredUploads(now time.Time, limit uint) ([]models.Upload, error)
+
+	// DeleteUnattachedFiles deletes up to limit files created before the
+	// time that no message carries, and returns how many there were.
+	DeleteUnattachedFiles(before time.Time, limit uint) (int, error)
+	// GetUnreferencedBlobs returns the keys of blobs that no file refers to
+	// any longer, which are forgotten with DeleteBlob once removed.
+	GetUnreferencedBlobs(limit uint) ([]string, error)
+	DeleteBlob(key string) error
+}
+
+//go:generate mockery --name=BlobStore --output=./mocks --case=underscore
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Service keeps file metadata in the repository and file contents in the
+// blob store.
+type Service struct {
+	log        *slog.Logger
+	repository Repository
+	blobs      BlobStore
+	uploads    UploadConfig
+}
+
+func NewFileService(log *slog.Logger, repository Repository, blobs BlobStore, uploads UploadConfig) *Service {
+	return &Service{
+		log:        log,
+		repository: repository,
+		blobs:      blobs,
+		uploads:    uploads,
+	}
+}
+
+// Upload streams the content read from r into the blob store as a file of
+// the user. The type of the file is detected from the start of its content.
+func (s *Service) Upload(userId uuid.UUID, name string, r io.Reader) (models.File, error) {
+	const op = "services.file.Upload"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	file, err := s.store(log, userId, name, r, MaxFileSize)
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	file, err = s.register(log, file, s.repository.Add)
+	if err != nil {
+		log.Error("error with storing file", slog.String("err", err.Error()))
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("file stored", slog.String("fileId", file.Id.String()), slog.Int64("size", file.Size))
+
+	return file, nil
+}
+
+// Open returns the file and its content if the user may see it. The caller
+// must close the content.
+func (s *Service) Open(userId, id uuid.UUID) (models.File, io.ReadCloser, error) {
+	const op = "services.file.Open"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	file, ok, err := s.repository.GetAccessible(id, userId)
+	if err != nil {
+		log.Error("error with getting file", slog.String("err", err.Error()))
+		return models.File{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.File{}, nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	if file.StorageKey == "" {
+		log.Info("moving file content to blob store", slog.String("fileId", id.String()))
+		if file.StorageKey, err = s.moveLegacy(id); err != nil {
+			log.Error("error with moving file content", slog.String("err", err.Error()))
+			return models.File{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	content, err := s.blobs.Open(file.StorageKey)
+	if err != nil {
+		log.Error("error with opening file", slog.String("err", err.Error()))
+		return models.File{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return file, content, nil
+}
+
+// store streams the content read from r into the blob store and returns the
+// file it makes up, which is not added to the repository yet. The content is
+// discarded if it is over maxSize bytes. Images are stored without location
+// metadata.
+func (s *Service) store(log *slog.Logger, userId uuid.UUID, name string, r io.Reader, maxSize int64) (models.File, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return models.File{}, err
+	}
+	head = head[:n]
+
+	mimeType, ok := detectType(head)
+	if !ok {
+		log.Warn("rejected upload", slog.String("type", mimeType))
+		return models.File{}, ErrUnsupportedType
+	}
+
+	file := models.File{
+		Id:         uuid.New(),
+		Name:       cleanName(name),
+		MimeType:   mimeType,
+		UploadedBy: userId,
+		CreatedAt:  time.Now().UTC(),
+	}
+	file.StorageKey = storageKey(file.Id)
+
+	raw := &countingReader{r: io.LimitReader(io.MultiReader(bytes.NewReader(head), r), maxSize+1)}
+	scrubbed := scrub(mimeType, raw)
+	defer scrubbed.Close()
+
+	hash := sha256.New()
+	content := &countingReader{r: io.TeeReader(scrubbed, hash)}
+
+	log.Info("storing file content", slog.String("type", mimeType))
+	err = s.blobs.Put(file.StorageKey, content)
+	switch {
+	case raw.n > maxSize:
+		// The content was cut off at the limit, which may also leave an
+		// image that cannot be scrubbed.
+		s.discard(log, file.StorageKey)
+		return models.File{}, ErrTooLarge
+	case errors.Is(err, errMalformed):
+		log.Warn("rejected malformed image", slog.String("type", mimeType))
+		return models.File{}, ErrUnsupportedType
+	case err != nil:
+		log.Error("error with storing file content", slog.String("err", err.Error()))
+		return models.File{}, err
+	}
+	file.Size = content.n
+	file.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return file, nil
+}
+
+// register adds the stored file through add and returns it as added. The
+// content just stored is discarded if the file shares the blob of an earlier
+// file with the same content. Image files that get no preview from such a
+// file get one made.
+func (s *Service) register(log *slog.Logger, file models.File, add func(models.File) (models.File, error)) (models.File, error) {
+	stored, err := add(file)
+	if err != nil {
+		s.discard(log, file.StorageKey)
+		return models.File{}, err
+	}
+	if stored.StorageKey != file.StorageKey {
+		log.Info("file content shared", slog.String("fileId", file.Id.String()))
+		s.discard(log, file.StorageKey)
+	}
+
+	if stored.Width != 0 || !previewTypes[stored.MimeType] {
+		return stored, nil
+	}
+	preview := stored
+	s.addPreview(log, &preview)
+	if preview.Width == 0 {
+		return stored, nil
+	}
+	if err = s.repository.SetPreview(preview); err != nil {
+		// The file is kept without a preview; its thumbnails go with its blob.
+		log.Error("error with storing preview", slog.String("err", err.Error()))
+		return stored, nil
+	}
+	return preview, nil
+}
+
+// MoveLegacyFiles moves the contents of all files still kept in the database
+// to the blob store and returns how many there were. Files opened meanwhile
+// are moved on the first download instead, whichever comes first.
+func (s *Service) MoveLegacyFiles() (int, error) {
+	const op = "services.file.MoveLegacyFiles"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	moved := 0
+	for {
+		ids, err := s.repository.GetLegacyFiles(moveBatch)
+		if err != nil {
+			log.Error("error with getting legacy files", slog.String("err", err.Error()))
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, id := range ids {
+			if _, err = s.moveLegacy(id); err != nil {
+				log.Error("error with moving file content", slog.String("fileId", id.String()),
+					slog.String("err", err.Error()))
+				return moved, fmt.Errorf("%s: %w", op, err)
+			}
+			moved++
+		}
+		if len(ids) < moveBatch {
+			log.Info("legacy files moved", slog.Int("count", moved))
+			return moved, nil
+		}
+	}
+}
+
+// moveLegacy copies the content of a file kept in the database to the blob
+// store and returns the key of the blob the file refers to, which is a blob
+// stored before if one has the same content.
+func (s *Service) moveLegacy(id uuid.UUID) (string, error) {
+	content, err := s.repository.GetLegacyContent(id)
+	if err != nil {
+		return "", err
+	}
+
+	key := storageKey(id)
+	if err = s.blobs.Put(key, bytes.NewReader(content)); err != nil {
+		return "", err
+	}
+	shared, err := s.repository.SetStorageKey(id, key)
+	if err != nil {
+		return "", err
+	}
+	if shared != key {
+		s.discard(s.log, key)
+	}
+	return shared, nil
+}
+
+// OpenThumbnail returns the thumbnail of the given size of an image file and
+// its content if the user may see the file. The caller must close the
+// content.
+func (s *Service) OpenThumbnail(userId, id uuid.UUID, size int) (models.Thumbnail, io.ReadCloser, error) {
+	const op = "services.file.OpenThumbnail"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	file, ok, err := s.repository.GetAccessible(id, userId)
+	if err != nil {
+		log.Error("error with getting file", slog.String("err", err.Error()))
+		return models.Thumbnail{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.Thumbnail{}, nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	for _, thumbnail := range file.Thumbnails {
+		if thumbnail.Size != size {
+			continue
+		}
+		content, err := s.blobs.Open(thumbnailKey(file.StorageKey, size))
+		if err != nil {
+			log.Error("error with opening thumbnail", slog.String("err", err.Error()))
+			return models.Thumbnail{}, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return thumbnail, content, nil
+	}
+	return models.Thumbnail{}, nil, fmt.Errorf("%s: %w", op, ErrNotFound)
+}
+
+// discard removes a blob that no file refers to.
+func (s *Service) discard(log *slog.Logger, key string) {
+	if err := s.blobs.Delete(key); err != nil {
+		log.Error("error with deleting file content", slog.String("err", err.Error()))
+	}
+}
+
+// storageKey spreads the blobs of files over directories by the start of
+// their ids.
+func storageKey(id uuid.UUID) string {
+	name := id.String()
+	return "files/" + name[:2] + "/" + name
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// detectType sniffs the media type of the content and reports whether it
+// may be uploaded.
+func detectType(content []byte) (string, bool) {
+	if len(content) == 0 {
+		return "", false
+	}
+
+	mimeType := http.DetectContentType(content[:min(len(content), sniffLength)])
+	for _, allowed := range allowedTypes {
+		if strings.HasPrefix(mimeType, allowed) {
+			return mimeType, true
+		}
+	}
+	return mimeType, false
+}
+
+// cleanName keeps the base name of an uploaded file without control
+// characters, shortened to maxNameLength.
+func cleanName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+
+	if name == "." || name == "/" {
+		return ""
+	}
+	if runes := []rune(name); len(runes) > maxNameLength {
+		return string(runes[:maxNameLength])
+	}
+	return name
+}
@@ -0,0 +1,291 @@
+package file
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/file/mocks"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pngImage is a 4x2 PNG image, red but for one blue pixel.
+var pngImage = func() []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []byte{0xff, 0, 0, 0xff})
+	}
+	copy(img.Pix[4:], []byte{0, 0, 0xff, 0xff})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}()
+
+func isBlobKey(prefix string) any {
+	return mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+func TestService_Upload(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	sharedKey := storageKey(uuid.New())
+	preview := &models.Preview{
+		Width:         4,
+		Height:        2,
+		DominantColor: "#ff0000",
+		Thumbnails:    []models.Thumbnail{{Size: 160, Width: 4, Height: 2}},
+	}
+
+	cases := []struct {
+		name          string
+		fileName      string
+		content       []byte
+		expectedName  string
+		expectedType  string
+		preview       *models.Preview
+		shared        bool
+		malformed     bool
+		expectedError error
+	}{
+		{
+			name:         "Загрузка картинки",
+			fileName:     "cat.png",
+			content:      pngImage,
+			expectedName: "cat.png",
+			expectedType: "image/png",
+			preview:      preview,
+		},
+		{
+			name:         "Загрузка картинки, загруженной раньше",
+			fileName:     "meme.png",
+			content:      pngImage,
+			expectedName: "meme.png",
+			expectedType: "image/png",
+			preview:      preview,
+			shared:       true,
+		},
+		{
+			name:          "Загрузка повреждённой картинки",
+			fileName:      "broken.png",
+			content:       pngImage[:40],
+			malformed:     true,
+			expectedError: ErrUnsupportedType,
+		},
+		{
+			name:         "Загрузка текста с путём в имени",
+			fileName:     `..\..\notes.txt`,
+			content:      []byte("hello"),
+			expectedName: "notes.txt",
+			expectedType: "text/plain; charset=utf-8",
+		},
+		{
+			name:          "Загрузка HTML",
+			fileName:      "page.png",
+			content:       []byte("<html><script>alert(1)</script></html>"),
+			expectedError: ErrUnsupportedType,
+		},
+		{
+			name:          "Загрузка пустого файла",
+			fileName:      "empty.txt",
+			expectedError: ErrUnsupportedType,
+		},
+		{
+			name:          "Загрузка слишком большого файла",
+			fileName:      "big.png",
+			content:       append(pngImage, make([]byte, MaxFileSize)...),
+			expectedError: ErrTooLarge,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := mocks.NewRepository(t)
+			mockBlobs := mocks.NewBlobStore(t)
+			service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, UploadConfig{})
+
+			var stored []byte
+			if tt.expectedError != ErrUnsupportedType || tt.malformed {
+				mockBlobs.On("Put", isBlobKey("files/"), mock.Anything).Return(func(_ string, r io.Reader) error {
+					var err error
+					stored, err = io.ReadAll(r)
+					return err
+				}).Once()
+			}
+			if tt.expectedError == ErrTooLarge {
+				mockBlobs.On("Delete", mock.AnythingOfType("string")).Return(nil).Once()
+			}
+			if tt.preview != nil && !tt.shared {
+				mockBlobs.On("Open", isBlobKey("files/")).Return(func(string) (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(stored)), nil
+				}).Once()
+				mockBlobs.On("Put", isBlobKey("thumbnails/"), mock.Anything).Return(nil).Once()
+				mockRepository.On("SetPreview", mock.MatchedBy(func(file models.File) bool {
+					return file.Width == tt.preview.Width && len(file.Thumbnails) == len(tt.preview.Thumbnails)
+				})).Return(nil).Once()
+			}
+			if tt.expectedError == nil {
+				hash := sha256.Sum256(tt.content)
+				mockRepository.On("Add", mock.MatchedBy(func(file models.File) bool {
+					return file.Name == tt.expectedName && file.MimeType == tt.expectedType &&
+						file.Size == int64(len(tt.content)) && file.UploadedBy == userId &&
+						file.Checksum == hex.EncodeToString(hash[:]) && file.StorageKey == storageKey(file.Id)
+				})).Return(func(file models.File) (models.File, error) {
+					if tt.shared {
+						// The content was stored before, with a preview.
+						file.StorageKey = sharedKey
+						file.Width, file.Height = tt.preview.Width, tt.preview.Height
+						file.DominantColor = tt.preview.DominantColor
+						file.Thumbnails = tt.preview.Thumbnails
+					}
+					return file, nil
+				}).Once()
+			}
+			if tt.shared {
+				mockBlobs.On("Delete", mock.MatchedBy(func(key string) bool {
+					return key != sharedKey && strings.HasPrefix(key, "files/")
+				})).Return(nil).Once()
+			}
+
+			file, err := service.Upload(userId, tt.fileName, bytes.NewReader(tt.content))
+			require.ErrorIs(t, err, tt.expectedError)
+			if tt.expectedError == nil {
+				require.Equal(t, tt.expectedName, file.Name)
+				require.Equal(t, tt.expectedType, file.MimeType)
+				require.Equal(t, tt.content, stored)
+			}
+			if tt.shared {
+				require.Equal(t, sharedKey, file.StorageKey)
+			}
+			if tt.preview != nil {
+				require.Equal(t, tt.preview.Width, file.Width)
+				require.Equal(t, tt.preview.Height, file.Height)
+				require.Equal(t, tt.preview.DominantColor, file.DominantColor)
+				require.Equal(t, models.Thumbnails(tt.preview.Thumbnails), file.Thumbnails)
+			}
+		})
+	}
+}
+
+func TestService_Open(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	stored := models.File{Id: uuid.New(), Name: "notes.txt", Size: 5}
+	stored.StorageKey = storageKey(stored.Id)
+	legacy := models.File{Id: uuid.New(), Name: "old.txt", Size: 5}
+	sharedKey := storageKey(uuid.New())
+
+	cases := []struct {
+		name          string
+		file          models.File
+		accessible    bool
+		sharedKey     string
+		expectedError error
+	}{
+		{
+			name:       "Скачивание доступного файла",
+			file:       stored,
+			accessible: true,
+		},
+		{
+			name:       "Скачивание файла из базы с переносом в хранилище",
+			file:       legacy,
+			accessible: true,
+		},
+		{
+			name:       "Скачивание файла из базы, содержимое которого уже в хранилище",
+			file:       legacy,
+			accessible: true,
+			sharedKey:  sharedKey,
+		},
+		{
+			name:          "Скачивание чужого файла",
+			file:          stored,
+			expectedError: ErrNotFound,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := mocks.NewRepository(t)
+			mockBlobs := mocks.NewBlobStore(t)
+			service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, UploadConfig{})
+
+			if !tt.accessible {
+				mockRepository.On("GetAccessible", tt.file.Id, userId).Return(models.File{}, false, nil).Once()
+			} else {
+				mockRepository.On("GetAccessible", tt.file.Id, userId).Return(tt.file, true, nil).Once()
+				key := storageKey(tt.file.Id)
+				if tt.file.StorageKey == "" {
+					mockRepository.On("GetLegacyContent", tt.file.Id).Return([]byte("hello"), nil).Once()
+					mockBlobs.On("Put", key, mock.Anything).Return(nil).Once()
+					if tt.sharedKey != "" {
+						mockRepository.On("SetStorageKey", tt.file.Id, key).Return(tt.sharedKey, nil).Once()
+						mockBlobs.On("Delete", key).Return(nil).Once()
+						key = tt.sharedKey
+					} else {
+						mockRepository.On("SetStorageKey", tt.file.Id, key).Return(key, nil).Once()
+					}
+				}
+				mockBlobs.On("Open", key).Return(io.NopCloser(strings.NewReader("hello")), nil).Once()
+			}
+
+			got, content, err := service.Open(userId, tt.file.Id)
+			require.ErrorIs(t, err, tt.expectedError)
+			if tt.accessible {
+				if tt.sharedKey != "" {
+					require.Equal(t, tt.sharedKey, got.StorageKey)
+				} else {
+					require.Equal(t, storageKey(tt.file.Id), got.StorageKey)
+				}
+				data, err := io.ReadAll(content)
+				require.NoError(t, err)
+				require.Equal(t, "hello", string(data))
+			}
+		})
+	}
+}
+
+func TestService_MoveLegacyFiles(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockBlobs := mocks.NewBlobStore(t)
+	service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, UploadConfig{})
+
+	ids := make([]uuid.UUID, moveBatch+1)
+	for i := range ids {
+		ids[i] = uuid.New()
+		key := storageKey(ids[i])
+		mockRepository.On("GetLegacyContent", ids[i]).Return([]byte("hello"), nil).Once()
+		mockBlobs.On("Put", key, mock.Anything).Return(nil).Once()
+		mockRepository.On("SetStorageKey", ids[i], key).Return(key, nil).Once()
+	}
+	mockRepository.On("GetLegacyFiles", uint(moveBatch)).Return(ids[:moveBatch], nil).Once()
+	mockRepository.On("GetLegacyFiles", uint(moveBatch)).Return(ids[moveBatch:], nil).Once()
+
+	moved, err := service.MoveLegacyFiles()
+	require.NoError(t, err)
+	require.Equal(t, len(ids), moved)
+}
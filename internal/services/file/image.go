@@ -0,0 +1,311 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"strconv"
+	"strings"
+)
+
+// thumbnailSizes are the squares that thumbnails are scaled down to fit in,
+// smallest first. Images are never scaled up, but every image gets the
+// smallest thumbnail.
+var thumbnailSizes = []int{160, 480, 1280}
+
+// previewTypes are the image types that previews are made of.
+var previewTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+const (
+	// maxImagePixels bounds the images that are decoded for a preview, as
+	// a decoded image takes up to four bytes per pixel.
+	maxImagePixels = 50_000_000
+	// thumbnailQuality is the JPEG quality of thumbnails.
+	thumbnailQuality = 80
+)
+
+var errTooManyPixels = errors.New("image too large to preview")
+
+// addPreview records the dimensions, dominant color and thumbnails of an
+// image file. A file whose image cannot be decoded is kept without them.
+func (s *Service) addPreview(log *slog.Logger, file *models.File) {
+	if !previewTypes[file.MimeType] {
+		return
+	}
+
+	img, orientation, err := s.decodeImage(file.StorageKey, file.MimeType)
+	if err != nil {
+		log.Warn("no preview for image", slog.String("fileId", file.Id.String()), slog.String("err", err.Error()))
+		return
+	}
+
+	file.Width, file.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if orientation >= 5 {
+		// These orientations turn the image by a quarter.
+		file.Width, file.Height = file.Height, file.Width
+	}
+
+	thumbnails, smallest, err := s.putThumbnails(file.StorageKey, img, orientation)
+	if err != nil {
+		log.Error("error with storing thumbnails", slog.String("err", err.Error()))
+		file.Width, file.Height = 0, 0
+		return
+	}
+	file.Thumbnails = thumbnails
+	file.DominantColor = dominantColor(smallest)
+}
+
+// decodeImage decodes the stored image and returns it with its EXIF
+// orientation. The header is decoded first to check the size of the image
+// before the pixels are.
+func (s *Service) decodeImage(key, mimeType string) (image.Image, int, error) {
+	content, err := s.blobs.Open(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer content.Close()
+
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(content, &header))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, 0, fmt.Errorf("%w: %dx%d", errTooManyPixels, config.Width, config.Height)
+	}
+
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(header.Bytes())
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, content))
+	if err != nil {
+		return nil, 0, err
+	}
+	return img, orientation, nil
+}
+
+// putThumbnails stores the thumbnails of the image in the blob at key and
+// returns them with the smallest one. Each thumbnail is scaled down from the
+// next larger one.
+func (s *Service) putThumbnails(key string, img image.Image, orientation int) (models.Thumbnails, *image.RGBA, error) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	count := 1
+	for count < len(thumbnailSizes) && max(width, height) > thumbnailSizes[count] {
+		count++
+	}
+
+	thumbnails := make(models.Thumbnails, count)
+	var scaled *image.RGBA
+	for i := count - 1; i >= 0; i-- {
+		w, h := fit(width, height, thumbnailSizes[i])
+		if scaled != nil {
+			img = scaled
+		}
+		scaled = resize(img, w, h)
+
+		oriented := orient(scaled, orientation)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, oriented, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+			s.discardThumbnails(key, thumbnails[i+1:])
+			return nil, nil, err
+		}
+		if err := s.blobs.Put(thumbnailKey(key, thumbnailSizes[i]), &buf); err != nil {
+			s.discardThumbnails(key, thumbnails[i+1:])
+			return nil, nil, err
+		}
+		thumbnails[i] = models.Thumbnail{
+			Size:   thumbnailSizes[i],
+			Width:  oriented.Bounds().Dx(),
+			Height: oriented.Bounds().Dy(),
+		}
+	}
+	return thumbnails, scaled, nil
+}
+
+// discardThumbnails removes stored thumbnails of the blob at key on a best
+// effort basis.
+func (s *Service) discardThumbnails(key string, thumbnails models.Thumbnails) {
+	for _, thumbnail := range thumbnails {
+		s.discard(s.log, thumbnailKey(key, thumbnail.Size))
+	}
+}
+
+// thumbnailKey keeps the thumbnails of the blob at key next to each other, by
+// size, under a key that mirrors the key of the blob. Files that share a blob
+// share its thumbnails.
+func thumbnailKey(key string, size int) string {
+	return "thumbnails/" + strings.TrimPrefix(key, "files/") + "/" + strconv.Itoa(size)
+}
+
+// fit returns the dimensions of an image of width by height scaled down to
+// fit in a square of size pixels, keeping its aspect ratio.
+func fit(width, height, size int) (int, int) {
+	longest := max(width, height)
+	if longest <= size {
+		return width, height
+	}
+	return max(1, (width*size+longest/2)/longest), max(1, (height*size+longest/2)/longest)
+}
+
+// resize scales the image down to width by height, averaging the pixels that
+// each pixel of the result covers. Transparent pixels are put on white, as
+// thumbnails are JPEG images.
+func resize(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	at := pixelReader(src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	xs := make([]int, width+1)
+	for x := range xs {
+		xs[x] = bounds.Min.X + x*bounds.Dx()/width
+	}
+	sums := make([]uint64, 4*width)
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		clear(sums)
+
+		for sy := y0; sy < y1; sy++ {
+			for x := 0; x < width; x++ {
+				sum := sums[4*x : 4*x+4]
+				for sx := xs[x]; sx < max(xs[x]+1, xs[x+1]); sx++ {
+					r, g, b, a := at(sx, sy)
+					sum[0] += uint64(r)
+					sum[1] += uint64(g)
+					sum[2] += uint64(b)
+					sum[3] += uint64(a)
+				}
+			}
+		}
+
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			n := uint64(y1-y0) * uint64(max(1, xs[x+1]-xs[x]))
+			sum := sums[4*x : 4*x+4]
+			// The channels are premultiplied by alpha, so putting a pixel on
+			// white adds what its alpha leaves of white.
+			white := 0xffff - sum[3]/n
+			for c := 0; c < 3; c++ {
+				row[4*x+c] = uint8(min(0xffff, sum[c]/n+white) >> 8)
+			}
+			row[4*x+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// pixelReader returns a function reading the premultiplied colors of the
+// image, which avoids the cost of At for the types that decoders return.
+func pixelReader(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.YCbCrAt(x, y).RGBA()
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.RGBAAt(x, y).RGBA()
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.NRGBAAt(x, y).RGBA()
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.GrayAt(x, y).RGBA()
+		}
+	case *image.Paletted:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.Palette[img.ColorIndexAt(x, y)].RGBA()
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return img.At(x, y).RGBA()
+		}
+	}
+}
+
+// orient turns the image upright by its EXIF orientation.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):])
+		}
+	}
+	return dst
+}
+
+// dominantColor returns the most common color of the image as #rrggbb.
+// Colors are counted with four bits per channel, so that close shades count
+// as one, and the pixels of the most common one are averaged.
+func dominantColor(img *image.RGBA) string {
+	var counts [4096]int
+	var sums [4096][3]int
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := img.Pix[img.PixOffset(x, y):]
+			k := int(p[0]>>4)<<8 | int(p[1]>>4)<<4 | int(p[2]>>4)
+			counts[k]++
+			sums[k][0] += int(p[0])
+			sums[k][1] += int(p[1])
+			sums[k][2] += int(p[2])
+		}
+	}
+
+	best := 0
+	for k := range counts {
+		if counts[k] > counts[best] {
+			best = k
+		}
+	}
+	if counts[best] == 0 {
+		return ""
+	}
+	n := counts[best]
+	return fmt.Sprintf("#%02x%02x%02x", sums[best][0]/n, sums[best][1]/n, sums[best][2]/n)
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/file/mocks"
+	"os"
+	"testing"
+)
+
+func TestService_AddPreview(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	// A landscape photo taken with the camera turned, mostly green.
+	img := image.NewRGBA(image.Rect(0, 0, 2000, 1000))
+	for y := 0; y < 1000; y++ {
+		for x := 0; x < 2000; x++ {
+			c := color.RGBA{G: 0xc0, A: 0xff}
+			if x < 500 {
+				c = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	var content []byte
+	content = append(content, buf.Bytes()[:2]...)
+	content = append(content, jpegSegment(0xe1, testExif(6))...)
+	content = append(content, buf.Bytes()[2:]...)
+
+	mockBlobs := mocks.NewBlobStore(t)
+	service := NewFileService(slog.New(logHandler), mocks.NewRepository(t), mockBlobs, testUploadConfig)
+
+	file := models.File{Id: uuid.New(), MimeType: "image/jpeg"}
+	file.StorageKey = storageKey(file.Id)
+
+	mockBlobs.On("Open", file.StorageKey).Return(io.NopCloser(bytes.NewReader(content)), nil).Once()
+	thumbnails := map[string][]byte{}
+	mockBlobs.On("Put", isBlobKey("thumbnails/"), mock.Anything).Return(func(key string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		thumbnails[key] = data
+		return err
+	}).Times(3)
+
+	service.addPreview(slog.New(logHandler), &file)
+
+	require.Equal(t, 1000, file.Width)
+	require.Equal(t, 2000, file.Height)
+	require.Equal(t, models.Thumbnails{
+		{Size: 160, Width: 80, Height: 160},
+		{Size: 480, Width: 240, Height: 480},
+		{Size: 1280, Width: 640, Height: 1280},
+	}, file.Thumbnails)
+	require.Regexp(t, "^#00[b-c][0-9a-f]00$", file.DominantColor)
+
+	for _, thumbnail := range file.Thumbnails {
+		decoded, err := jpeg.Decode(bytes.NewReader(thumbnails[thumbnailKey(file.StorageKey, thumbnail.Size)]))
+		require.NoError(t, err)
+		require.Equal(t, thumbnail.Width, decoded.Bounds().Dx())
+		require.Equal(t, thumbnail.Height, decoded.Bounds().Dy())
+
+		// Turned clockwise, the white left side of the photo is on top.
+		r, g, b, _ := decoded.At(thumbnail.Width/2, 0).RGBA()
+		require.Greater(t, min(r, g, b), uint32(0xe000))
+		r, _, _, _ = decoded.At(thumbnail.Width/2, thumbnail.Height-1).RGBA()
+		require.Less(t, r, uint32(0x2000))
+	}
+}
+
+func TestService_AddPreviewTooLarge(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	// The header of a PNG image of 100000 by 100000 pixels is enough to
+	// refuse decoding it.
+	header := pngChunk("IHDR", []byte{0, 1, 0x86, 0xa0, 0, 1, 0x86, 0xa0, 8, 2, 0, 0, 0})
+	content := append(append([]byte{}, pngSignature...), header...)
+
+	mockBlobs := mocks.NewBlobStore(t)
+	service := NewFileService(slog.New(logHandler), mocks.NewRepository(t), mockBlobs, testUploadConfig)
+
+	file := models.File{Id: uuid.New(), MimeType: "image/png"}
+	file.StorageKey = storageKey(file.Id)
+	mockBlobs.On("Open", file.StorageKey).Return(io.NopCloser(bytes.NewReader(content)), nil).Once()
+
+	service.addPreview(slog.New(logHandler), &file)
+	require.Zero(t, file.Width)
+	require.Empty(t, file.Thumbnails)
+}
@@ -0,0 +1,464 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	models "messenger/internal/domain/models"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Repository is an autogenerated mock type for the Repository type
+type Repository struct {
+	mock.Mock
+}
+
+// Add provides a mock function with given fields: _a0
+func (_m *Repository) Add(_a0 models.File) (models.File, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Add")
+	}
+
+	var r0 models.File
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.File) (models.File, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(models.File) models.File); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.File) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddChunk provides a mock function with given fields: chunk, expiresAt
+func (_m *Repository) AddChunk(chunk models.UploadChunk, expiresAt time.Time) (bool, error) {
+	ret := _m.Called(chunk, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddChunk")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.UploadChunk, time.Time) (bool, error)); ok {
+		return rf(chunk, expiresAt)
+	}
+	if rf, ok := ret.Get(0).(func(models.UploadChunk, time.Time) bool); ok {
+		r0 = rf(chunk, expiresAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.UploadChunk, time.Time) error); ok {
+		r1 = rf(chunk, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddUpload provides a mock function with given fields: upload
+func (_m *Repository) AddUpload(upload models.Upload) error {
+	ret := _m.Called(upload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.Upload) error); ok {
+		r0 = rf(upload)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CompleteUpload provides a mock function with given fields: id, _a1
+func (_m *Repository) CompleteUpload(id uuid.UUID, _a1 models.File) (models.File, error) {
+	ret := _m.Called(id, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteUpload")
+	}
+
+	var r0 models.File
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, models.File) (models.File, error)); ok {
+		return rf(id, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, models.File) models.File); ok {
+		r0 = rf(id, _a1)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, models.File) error); ok {
+		r1 = rf(id, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteBlob provides a mock function with given fields: key
+func (_m *Repository) DeleteBlob(key string) error {
+	ret := _m.Called(key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteBlob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteUnattachedFiles provides a mock function with given fields: before, limit
+func (_m *Repository) DeleteUnattachedFiles(before time.Time, limit uint) (int, error) {
+	ret := _m.Called(before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUnattachedFiles")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, uint) (int, error)); ok {
+		return rf(before, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, uint) int); ok {
+		r0 = rf(before, limit)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, uint) error); ok {
+		r1 = rf(before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUpload provides a mock function with given fields: id
+func (_m *Repository) DeleteUpload(id uuid.UUID) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUpload")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAccessible provides a mock function with given fields: id, userId
+func (_m *Repository) GetAccessible(id uuid.UUID, userId uuid.UUID) (models.File, bool, error) {
+	ret := _m.Called(id, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAccessible")
+	}
+
+	var r0 models.File
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.File, bool, error)); ok {
+		return rf(id, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.File); ok {
+		r0 = rf(id, userId)
+	} else {
+		r0 = ret.Get(0).(models.File)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r1 = rf(id, userId)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID) error); ok {
+		r2 = rf(id, userId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetChunks provides a mock function with given fields: id
+func (_m *Repository) GetChunks(id uuid.UUID) ([]models.UploadChunk, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChunks")
+	}
+
+	var r0 []models.UploadChunk
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.UploadChunk, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.UploadChunk); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.UploadChunk)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExpiredUploads provides a mock function with given fields: now, limit
+func (_m *Repository) GetExpiredUploads(now time.Time, limit uint) ([]models.Upload, error) {
+	ret := _m.Called(now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiredUploads")
+	}
+
+	var r0 []models.Upload
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, uint) ([]models.Upload, error)); ok {
+		return rf(now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, uint) []models.Upload); ok {
+		r0 = rf(now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Upload)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, uint) error); ok {
+		r1 = rf(now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLegacyContent provides a mock function with given fields: id
+func (_m *Repository) GetLegacyContent(id uuid.UUID) ([]byte, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLegacyContent")
+	}
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]byte, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []byte); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLegacyFiles provides a mock function with given fields: limit
+func (_m *Repository) GetLegacyFiles(limit uint) ([]uuid.UUID, error) {
+	ret := _m.Called(limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLegacyFiles")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uint) ([]uuid.UUID, error)); ok {
+		return rf(limit)
+	}
+	if rf, ok := ret.Get(0).(func(uint) []uuid.UUID); ok {
+		r0 = rf(limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint) error); ok {
+		r1 = rf(limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUnreferencedBlobs provides a mock function with given fields: limit
+func (_m *Repository) GetUnreferencedBlobs(limit uint) ([]string, error) {
+	ret := _m.Called(limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUnreferencedBlobs")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uint) ([]string, error)); ok {
+		return rf(limit)
+	}
+	if rf, ok := ret.Get(0).(func(uint) []string); ok {
+		r0 = rf(limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint) error); ok {
+		r1 = rf(limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUpload provides a mock function with given fields: id, userId
+func (_m *Repository) GetUpload(id uuid.UUID, userId uuid.UUID) (models.Upload, bool, error) {
+	ret := _m.Called(id, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUpload")
+	}
+
+	var r0 models.Upload
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (models.Upload, bool, error)); ok {
+		return rf(id, userId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) models.Upload); ok {
+		r0 = rf(id, userId)
+	} else {
+		r0 = ret.Get(0).(models.Upload)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r1 = rf(id, userId)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID) error); ok {
+		r2 = rf(id, userId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetPreview provides a mock function with given fields: _a0
+func (_m *Repository) SetPreview(_a0 models.File) error {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPreview")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.File) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetStorageKey provides a mock function with given fields: id, key
+func (_m *Repository) SetStorageKey(id uuid.UUID, key string) (string, error) {
+	ret := _m.Called(id, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetStorageKey")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string) (string, error)); ok {
+		return rf(id, key)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string) string); ok {
+		r0 = rf(id, key)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, string) error); ok {
+		r1 = rf(id, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Repository {
+	mock := &Repository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
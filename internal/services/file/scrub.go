@@ -0,0 +1,356 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// errMalformed is returned for images whose structure is broken, so that
+// their metadata cannot be told apart from their content.
+var errMalformed = errors.New("malformed image")
+
+const (
+	tagOrientation = 0x0112
+	tagGPS         = 0x8825
+)
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	xmpNamespace = []byte("http://ns.adobe.com/")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	// xmpKeyword starts the text chunks of PNG images that carry XMP.
+	xmpKeyword = []byte("XML:com.adobe.xmp\x00")
+)
+
+// exifTypeSizes are the sizes of the EXIF value types, by type.
+var exifTypeSizes = map[uint16]uint64{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// scrub returns the content with the location metadata of JPEG and PNG
+// images removed as it is read: the GPS data of EXIF and XMP packets, which
+// may carry a location too. Other content is returned as is. The caller must
+// close the returned reader.
+func scrub(mimeType string, r io.Reader) io.ReadCloser {
+	var filter func(w io.Writer, r io.Reader) error
+	switch mimeType {
+	case "image/jpeg":
+		filter = scrubJPEG
+	case "image/png":
+		filter = scrubPNG
+	default:
+		return io.NopCloser(r)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(filter(pw, r))
+	}()
+	return pr
+}
+
+// scrubJPEG copies the JPEG image, dropping XMP segments and the GPS data of
+// the EXIF segment. An EXIF segment that cannot be parsed is dropped whole.
+func scrubJPEG(w io.Writer, r io.Reader) error {
+	return filterJPEG(w, r, func(marker byte, payload []byte) bool {
+		if marker != 0xe1 {
+			return true
+		}
+		if bytes.HasPrefix(payload, exifHeader) {
+			return stripGPS(payload[len(exifHeader):]) == nil
+		}
+		return !bytes.HasPrefix(payload, xmpNamespace)
+	})
+}
+
+// jpegOrientation returns the EXIF orientation of the JPEG image that starts
+// with header, or 1 if it has none.
+func jpegOrientation(header []byte) int {
+	orientation := 1
+	_ = filterJPEG(io.Discard, bytes.NewReader(header), func(marker byte, payload []byte) bool {
+		if marker == 0xe1 && bytes.HasPrefix(payload, exifHeader) {
+			if o, err := exifOrientation(payload[len(exifHeader):]); err == nil && o >= 1 && o <= 8 {
+				orientation = o
+			}
+		}
+		return true
+	})
+	return orientation
+}
+
+// filterJPEG copies the JPEG image, passing the payload of every marker
+// segment before the image data to keep, which may change the payload in
+// place and reports whether the segment is kept. The image data is copied
+// as is.
+func filterJPEG(w io.Writer, r io.Reader, keep func(marker byte, payload []byte) bool) error {
+	br := bufio.NewReader(r)
+
+	soi := make([]byte, 2)
+	if err := readFull(br, soi); err != nil {
+		return err
+	}
+	if soi[0] != 0xff || soi[1] != 0xd8 {
+		return errMalformed
+	}
+	if _, err := w.Write(soi); err != nil {
+		return err
+	}
+
+	for {
+		marker, err := nextMarker(br)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case marker == 0xda || marker == 0xd9:
+			// Start of scan or end of image: the rest is image data.
+			if _, err = w.Write([]byte{0xff, marker}); err != nil {
+				return err
+			}
+			_, err = io.Copy(w, br)
+			return err
+		case marker >= 0xd0 && marker <= 0xd7 || marker == 0x01:
+			if _, err = w.Write([]byte{0xff, marker}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		length := make([]byte, 2)
+		if err = readFull(br, length); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(length))
+		if n < 2 {
+			return errMalformed
+		}
+		payload := make([]byte, n-2)
+		if err = readFull(br, payload); err != nil {
+			return err
+		}
+
+		if !keep(marker, payload) {
+			continue
+		}
+		if _, err = w.Write(append([]byte{0xff, marker}, length...)); err != nil {
+			return err
+		}
+		if _, err = w.Write(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// nextMarker reads the marker that starts the next segment, skipping fill
+// bytes.
+func nextMarker(br *bufio.Reader) (byte, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, eofMalformed(err)
+	}
+	if b != 0xff {
+		return 0, errMalformed
+	}
+	for b == 0xff {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, eofMalformed(err)
+		}
+	}
+	if b == 0 {
+		return 0, errMalformed
+	}
+	return b, nil
+}
+
+// scrubPNG copies the PNG image, dropping its EXIF chunks and the text
+// chunks that carry XMP.
+func scrubPNG(w io.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	signature := make([]byte, len(pngSignature))
+	if err := readFull(br, signature); err != nil {
+		return err
+	}
+	if !bytes.Equal(signature, pngSignature) {
+		return errMalformed
+	}
+	if _, err := w.Write(signature); err != nil {
+		return err
+	}
+
+	for {
+		header := make([]byte, 8)
+		if err := readFull(br, header); err != nil {
+			return err
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		if length > 1<<31-1 {
+			return errMalformed
+		}
+		chunkType := string(header[4:])
+		// The data of the chunk is followed by its CRC.
+		rest := int64(length) + 4
+
+		drop := chunkType == "eXIf"
+		var prefix []byte
+		if chunkType == "iTXt" || chunkType == "tEXt" || chunkType == "zTXt" {
+			prefix = make([]byte, min(rest, int64(len(xmpKeyword))))
+			if err := readFull(br, prefix); err != nil {
+				return err
+			}
+			rest -= int64(len(prefix))
+			drop = bytes.Equal(prefix, xmpKeyword)
+		}
+
+		if drop {
+			if _, err := io.CopyN(io.Discard, br, rest); err != nil {
+				return eofMalformed(err)
+			}
+			continue
+		}
+
+		if _, err := w.Write(append(header, prefix...)); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, br, rest); err != nil {
+			return eofMalformed(err)
+		}
+		if chunkType == "IEND" {
+			_, err := io.Copy(w, br)
+			return err
+		}
+	}
+}
+
+// stripGPS removes the GPS data from the EXIF data in place: the values of
+// the GPS directory are zeroed and its entry is dropped from the first
+// directory.
+func stripGPS(tiff []byte) error {
+	order, ifd, n, err := firstIFD(tiff)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		entry := tiff[ifd+2+12*i:]
+		if order.Uint16(entry) != tagGPS {
+			continue
+		}
+		gps := uint64(order.Uint32(entry[8:]))
+		if gps == uint64(ifd) {
+			return errMalformed
+		}
+		if err = zeroIFD(tiff, order, gps); err != nil {
+			return err
+		}
+
+		// The later entries and the offset of the next directory move up.
+		end := ifd + 2 + 12*n + 4
+		copy(tiff[ifd+2+12*i:], tiff[ifd+2+12*(i+1):end])
+		clear(tiff[end-12 : end])
+		order.PutUint16(tiff[ifd:], uint16(n-1))
+		return nil
+	}
+	return nil
+}
+
+// exifOrientation returns the orientation recorded in the EXIF data, or 1 if
+// there is none.
+func exifOrientation(tiff []byte) (int, error) {
+	order, ifd, n, err := firstIFD(tiff)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		entry := tiff[ifd+2+12*i:]
+		if order.Uint16(entry) == tagOrientation && order.Uint16(entry[2:]) == 3 {
+			return int(order.Uint16(entry[8:])), nil
+		}
+	}
+	return 1, nil
+}
+
+// firstIFD returns the byte order of the EXIF data and the offset and number
+// of entries of its first directory.
+func firstIFD(tiff []byte) (binary.ByteOrder, int, int, error) {
+	if len(tiff) < 8 {
+		return nil, 0, 0, errMalformed
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, 0, errMalformed
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, 0, errMalformed
+	}
+
+	ifd := uint64(order.Uint32(tiff[4:]))
+	n, err := ifdEntries(tiff, order, ifd)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return order, int(ifd), n, nil
+}
+
+// ifdEntries returns the number of entries of the directory at offset,
+// checking that the directory lies within the data.
+func ifdEntries(tiff []byte, order binary.ByteOrder, offset uint64) (int, error) {
+	if offset+2 > uint64(len(tiff)) {
+		return 0, errMalformed
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	if offset+2+12*uint64(n)+4 > uint64(len(tiff)) {
+		return 0, errMalformed
+	}
+	return n, nil
+}
+
+// zeroIFD zeroes the directory at offset and the values it points at.
+func zeroIFD(tiff []byte, order binary.ByteOrder, offset uint64) error {
+	n, err := ifdEntries(tiff, order, offset)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		entry := tiff[offset+2+12*uint64(i):]
+		typeSize, ok := exifTypeSizes[order.Uint16(entry[2:])]
+		if !ok {
+			return errMalformed
+		}
+		size := typeSize * uint64(order.Uint32(entry[4:]))
+		if size <= 4 {
+			continue
+		}
+		value := uint64(order.Uint32(entry[8:]))
+		if value+size > uint64(len(tiff)) {
+			return errMalformed
+		}
+		clear(tiff[value : value+size])
+	}
+
+	clear(tiff[offset : offset+2+12*uint64(n)+4])
+	return nil
+}
+
+func readFull(r io.Reader, buf []byte) error {
+	_, err := io.ReadFull(r, buf)
+	return eofMalformed(err)
+}
+
+// eofMalformed reports content that ends too early as malformed.
+func eofMalformed(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errMalformed
+	}
+	return err
+}
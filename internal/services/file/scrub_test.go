@@ -0,0 +1,141 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// gpsLatitude is the latitude recorded in testExif, three rationals.
+var gpsLatitude = []byte{
+	55, 0, 0, 0, 1, 0, 0, 0,
+	45, 0, 0, 0, 1, 0, 0, 0,
+	0xd2, 0x04, 0, 0, 100, 0, 0, 0,
+}
+
+// testExif returns little endian EXIF data with the orientation and a GPS
+// directory holding gpsLatitude.
+func testExif(orientation uint16) []byte {
+	le := binary.LittleEndian
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+
+	// The first directory, with the orientation and the GPS directory at 38.
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = append(tiff, exifEntry(tagOrientation, 3, 1, uint32(orientation))...)
+	tiff = append(tiff, exifEntry(tagGPS, 4, 1, 38)...)
+	tiff = le.AppendUint32(tiff, 0)
+
+	// The GPS directory, with the latitude at 56.
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = append(tiff, exifEntry(2, 5, 3, 56)...)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, gpsLatitude...)
+
+	return append(append([]byte{}, exifHeader...), tiff...)
+}
+
+func exifEntry(tag, valueType uint16, count, value uint32) []byte {
+	le := binary.LittleEndian
+	entry := le.AppendUint16(nil, tag)
+	entry = le.AppendUint16(entry, valueType)
+	entry = le.AppendUint32(entry, count)
+	return le.AppendUint32(entry, value)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// testJPEG encodes a width by height JPEG image with the segments inserted
+// after its start.
+func testJPEG(t *testing.T, width, height int, segments ...[]byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+
+	content := buf.Bytes()
+	result := append([]byte{}, content[:2]...)
+	for _, segment := range segments {
+		result = append(result, segment...)
+	}
+	return append(result, content[2:]...)
+}
+
+func scrubAll(t *testing.T, mimeType string, content []byte) ([]byte, error) {
+	scrubbed := scrub(mimeType, bytes.NewReader(content))
+	defer func() {
+		require.NoError(t, scrubbed.Close())
+	}()
+	return io.ReadAll(scrubbed)
+}
+
+func TestScrubJPEG(t *testing.T) {
+	xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), "<x:xmpmeta>Moscow</x:xmpmeta>"...)
+	content := testJPEG(t, 8, 8, jpegSegment(0xe1, testExif(6)), jpegSegment(0xe1, xmp))
+	require.True(t, bytes.Contains(content, gpsLatitude))
+
+	scrubbed, err := scrubAll(t, "image/jpeg", content)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(scrubbed, gpsLatitude))
+	require.False(t, bytes.Contains(scrubbed, []byte("Moscow")))
+	require.Equal(t, 6, jpegOrientation(scrubbed))
+
+	tiff := scrubbed[2+4+len(exifHeader):]
+	order, ifd, n, err := firstIFD(tiff)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, uint16(tagOrientation), order.Uint16(tiff[ifd+2:]))
+
+	_, err = jpeg.Decode(bytes.NewReader(scrubbed))
+	require.NoError(t, err)
+
+	// EXIF whose GPS directory lies out of bounds is dropped whole.
+	broken := testExif(6)
+	binary.LittleEndian.PutUint32(broken[len(exifHeader)+8+2+12+8:], 1000)
+	scrubbed, err = scrubAll(t, "image/jpeg", testJPEG(t, 8, 8, jpegSegment(0xe1, broken)))
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(scrubbed, exifHeader))
+
+	_, err = scrubAll(t, "image/jpeg", content[:len(exifHeader)+20])
+	require.ErrorIs(t, err, errMalformed)
+}
+
+func TestScrubPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))))
+	encoded := buf.Bytes()
+
+	// Metadata chunks go before the image data, after the 25 byte header.
+	var content []byte
+	content = append(content, encoded[:len(pngSignature)+25]...)
+	content = append(content, pngChunk("eXIf", testExif(1)[len(exifHeader):])...)
+	content = append(content, pngChunk("iTXt", append(append([]byte{}, xmpKeyword...), "Moscow"...))...)
+	content = append(content, pngChunk("tEXt", []byte("Comment\x00hello"))...)
+	content = append(content, encoded[len(pngSignature)+25:]...)
+
+	scrubbed, err := scrubAll(t, "image/png", content)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(scrubbed, gpsLatitude))
+	require.False(t, bytes.Contains(scrubbed, []byte("Moscow")))
+	require.True(t, bytes.Contains(scrubbed, []byte("Comment\x00hello")))
+
+	_, err = png.Decode(bytes.NewReader(scrubbed))
+	require.NoError(t, err)
+
+	_, err = scrubAll(t, "image/png", content[:40])
+	require.ErrorIs(t, err, errMalformed)
+}
@@ -0,0 +1,324 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrOffsetMismatch is returned for chunks that do not start where the
+	// upload stopped.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrUploadIncomplete is returned for finishing an upload before all of
+	// its content was received.
+	ErrUploadIncomplete = errors.New("upload incomplete")
+	// ErrInvalidLength is returned for uploads of no content.
+	ErrInvalidLength = errors.New("invalid upload length")
+)
+
+const (
+	// MaxChunkSize is the largest chunk that may be sent at once.
+	MaxChunkSize = 16 << 20
+
+	// collectBatch is how many expired uploads are collected per query.
+	collectBatch = 100
+)
+
+// CreateUpload starts a resumable upload of a file of length bytes.
+func (s *Service) CreateUpload(userId uuid.UUID, name string, length int64) (models.Upload, error) {
+	const op = "services.file.CreateUpload"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	if length <= 0 {
+		return models.Upload{}, fmt.Errorf("%s: %w", op, ErrInvalidLength)
+	}
+	if length > s.uploads.MaxSize {
+		return models.Upload{}, fmt.Errorf("%s: %w", op, ErrTooLarge)
+	}
+
+	now := time.Now().UTC()
+	upload := models.Upload{
+		Id:        uuid.New(),
+		PersonId:  userId,
+		Name:      cleanName(name),
+		Length:    length,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.uploads.TTL),
+	}
+
+	if err := s.repository.AddUpload(upload); err != nil {
+		log.Error("error with adding upload", slog.String("err", err.Error()))
+		return models.Upload{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("upload created", slog.String("uploadId", upload.Id.String()), slog.Int64("length", length))
+
+	return upload, nil
+}
+
+// GetUpload returns the upload of the user, unless it expired.
+func (s *Service) GetUpload(userId, id uuid.UUID) (models.Upload, error) {
+	const op = "services.file.GetUpload"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	upload, ok, err := s.repository.GetUpload(id, userId)
+	if err != nil {
+		log.Error("error with getting upload", slog.String("err", err.Error()))
+		return models.Upload{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok || !upload.ExpiresAt.After(time.Now()) {
+		return models.Upload{}, fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return upload, nil
+}
+
+// AppendChunk stores the content read from r as the part of the upload that
+// starts at offset and returns the offset after it. A chunk takes at most
+// MaxChunkSize bytes and may not run past the length of the upload.
+func (s *Service) AppendChunk(userId, id uuid.UUID, offset int64, r io.Reader) (int64, error) {
+	const op = "services.file.AppendChunk"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	upload, err := s.GetUpload(userId, id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if offset != upload.Offset {
+		return 0, fmt.Errorf("%s: %w", op, ErrOffsetMismatch)
+	}
+
+	limit := min(MaxChunkSize, upload.Length-offset)
+	if offset == 0 {
+		// Rejecting content of the wrong type here spares the client the
+		// rest of the upload; it is checked again when the upload finishes.
+		head := make([]byte, sniffLength)
+		n, err := io.ReadFull(io.LimitReader(r, limit), head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if mimeType, ok := detectType(head[:n]); n > 0 && !ok {
+			log.Warn("rejected upload", slog.String("type", mimeType))
+			return 0, fmt.Errorf("%s: %w", op, ErrUnsupportedType)
+		}
+		r = io.MultiReader(bytes.NewReader(head[:n]), r)
+	}
+
+	chunk := models.UploadChunk{
+		UploadId:   id,
+		Start:      offset,
+		StorageKey: chunkKey(id, offset),
+	}
+	content := &countingReader{r: io.LimitReader(r, limit+1)}
+	if err = s.blobs.Put(chunk.StorageKey, content); err != nil {
+		log.Error("error with storing chunk", slog.String("err", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if content.n > limit {
+		s.discard(log, chunk.StorageKey)
+		return 0, fmt.Errorf("%s: %w", op, ErrTooLarge)
+	}
+	if content.n == 0 {
+		s.discard(log, chunk.StorageKey)
+		return offset, nil
+	}
+	chunk.Size = content.n
+
+	added, err := s.repository.AddChunk(chunk, time.Now().UTC().Add(s.uploads.TTL))
+	if err != nil {
+		log.Error("error with adding chunk", slog.String("err", err.Error()))
+		s.discard(log, chunk.StorageKey)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !added {
+		// Another request sent a chunk at the same offset first.
+		s.discard(log, chunk.StorageKey)
+		log.Warn("concurrent chunk", slog.String("uploadId", id.String()), slog.Int64("offset", offset))
+		return 0, fmt.Errorf("%s: %w", op, ErrOffsetMismatch)
+	}
+
+	return offset + chunk.Size, nil
+}
+
+// FinishUpload assembles the chunks of a complete upload into a file.
+func (s *Service) FinishUpload(userId, id uuid.UUID) (models.File, error) {
+	const op = "services.file.FinishUpload"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	upload, err := s.GetUpload(userId, id)
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if upload.Offset != upload.Length {
+		return models.File{}, fmt.Errorf("%s: %w", op, ErrUploadIncomplete)
+	}
+
+	chunks, err := s.repository.GetChunks(id)
+	if err != nil {
+		log.Error("error with getting chunks", slog.String("err", err.Error()))
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	content := &chunkReader{blobs: s.blobs, chunks: chunks}
+	file, err := s.store(log, userId, upload.Name, content, upload.Length)
+	_ = content.Close()
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	file, err = s.register(log, file, func(file models.File) (models.File, error) {
+		return s.repository.CompleteUpload(id, file)
+	})
+	if err != nil {
+		log.Error("error with completing upload", slog.String("err", err.Error()))
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("upload finished", slog.String("uploadId", id.String()), slog.String("fileId", file.Id.String()))
+
+	s.discardChunks(log, chunks)
+	return file, nil
+}
+
+// CancelUpload drops the upload and the chunks received for it.
+func (s *Service) CancelUpload(userId, id uuid.UUID) error {
+	const op = "services.file.CancelUpload"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	if _, err := s.GetUpload(userId, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.dropUpload(id); err != nil {
+		log.Error("error with dropping upload", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CollectUploads drops the uploads that expired and returns how many there
+// were.
+func (s *Service) CollectUploads() (int, error) {
+	const op = "services.file.CollectUploads"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	collected := 0
+	for {
+		uploads, err := s.repository.GetExpiredUploads(time.Now().UTC(), collectBatch)
+		if err != nil {
+			log.Error("error with getting expired uploads", slog.String("err", err.Error()))
+			return collected, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, upload := range uploads {
+			if err = s.dropUpload(upload.Id); err != nil {
+				log.Error("error with dropping upload", slog.String("err", err.Error()))
+				return collected, fmt.Errorf("%s: %w", op, err)
+			}
+			collected++
+		}
+		if len(uploads) < collectBatch {
+			return collected, nil
+		}
+	}
+}
+
+// dropUpload deletes the chunks of the upload before the upload itself, so
+// that a failure leaves the upload to be collected again.
+func (s *Service) dropUpload(id uuid.UUID) error {
+	chunks, err := s.repository.GetChunks(id)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err = s.blobs.Delete(chunk.StorageKey); err != nil {
+			return err
+		}
+	}
+	return s.repository.DeleteUpload(id)
+}
+
+// discardChunks removes the blobs of chunks that were assembled into a file.
+func (s *Service) discardChunks(log *slog.Logger, chunks []models.UploadChunk) {
+	for _, chunk := range chunks {
+		s.discard(log, chunk.StorageKey)
+	}
+}
+
+// chunkKey keeps the chunks of an upload together, by their offsets. Chunks
+// sent at the same offset at once get keys of their own, so the one that is
+// not recorded can be removed without touching the other.
+func chunkKey(id uuid.UUID, offset int64) string {
+	return "uploads/" + id.String() + "/" + strconv.FormatInt(offset, 10) + "-" + uuid.NewString()
+}
+
+// errChunkSize is returned for chunks whose blob does not hold as many bytes
+// as were recorded for them.
+var errChunkSize = errors.New("chunk size mismatch")
+
+// chunkReader reads the chunks of an upload one after another, opening each
+// only once the previous one is read.
+type chunkReader struct {
+	blobs   BlobStore
+	chunks  []models.UploadChunk
+	current io.ReadCloser
+	left    int64
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.chunks) == 0 {
+				return 0, io.EOF
+			}
+			content, err := c.blobs.Open(c.chunks[0].StorageKey)
+			if err != nil {
+				return 0, err
+			}
+			c.current = content
+			c.left = c.chunks[0].Size
+			c.chunks = c.chunks[1:]
+		}
+
+		n, err := c.current.Read(p[:min(int64(len(p)), c.left+1)])
+		c.left -= int64(n)
+		if c.left < 0 {
+			return 0, errChunkSize
+		}
+		if errors.Is(err, io.EOF) {
+			if c.left != 0 {
+				return 0, errChunkSize
+			}
+			_ = c.current.Close()
+			c.current = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.current == nil {
+		return nil
+	}
+	err := c.current.Close()
+	c.current = nil
+	return err
+}
@@ -0,0 +1,323 @@
+package file
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"messenger/internal/domain/models"
+	"messenger/internal/services/file/mocks"
+	"os"
+	"testing"
+	"time"
+)
+
+var testUploadConfig = UploadConfig{
+	MaxSize:         1 << 30,
+	TTL:             time.Hour,
+	CollectInterval: time.Minute,
+}
+
+func TestService_CreateUpload(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+
+	cases := []struct {
+		name          string
+		length        int64
+		expectedError error
+	}{
+		{
+			name:   "Создание загрузки",
+			length: 200 << 20,
+		},
+		{
+			name:          "Создание загрузки больше предела",
+			length:        testUploadConfig.MaxSize + 1,
+			expectedError: ErrTooLarge,
+		},
+		{
+			name:          "Создание пустой загрузки",
+			expectedError: ErrInvalidLength,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := mocks.NewRepository(t)
+			service := NewFileService(slog.New(logHandler), mockRepository, mocks.NewBlobStore(t), testUploadConfig)
+
+			if tt.expectedError == nil {
+				mockRepository.On("AddUpload", mock.MatchedBy(func(upload models.Upload) bool {
+					return upload.PersonId == userId && upload.Name == "video.mp4" && upload.Length == tt.length &&
+						upload.Offset == 0 && upload.ExpiresAt.Equal(upload.CreatedAt.Add(testUploadConfig.TTL))
+				})).Return(nil).Once()
+			}
+
+			upload, err := service.CreateUpload(userId, "/tmp/video.mp4", tt.length)
+			require.ErrorIs(t, err, tt.expectedError)
+			if tt.expectedError == nil {
+				require.Equal(t, tt.length, upload.Length)
+			}
+		})
+	}
+}
+
+func TestService_AppendChunk(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	upload := models.Upload{
+		Id:        uuid.New(),
+		PersonId:  userId,
+		Length:    int64(len(pngImage)),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	head, tail := pngImage[:len(pngImage)-4], pngImage[len(pngImage)-4:]
+	started := upload
+	started.Offset = int64(len(head))
+	expired := upload
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+
+	cases := []struct {
+		name           string
+		upload         models.Upload
+		found          bool
+		offset         int64
+		content        []byte
+		stored         bool
+		added          bool
+		expectedOffset int64
+		expectedError  error
+	}{
+		{
+			name:           "Первый кусок",
+			upload:         upload,
+			found:          true,
+			content:        head,
+			stored:         true,
+			added:          true,
+			expectedOffset: started.Offset,
+		},
+		{
+			name:           "Последний кусок",
+			upload:         started,
+			found:          true,
+			offset:         started.Offset,
+			content:        tail,
+			stored:         true,
+			added:          true,
+			expectedOffset: upload.Length,
+		},
+		{
+			name:          "Кусок не с того места",
+			upload:        started,
+			found:         true,
+			content:       head,
+			expectedError: ErrOffsetMismatch,
+		},
+		{
+			name:          "Кусок длиннее загрузки",
+			upload:        started,
+			found:         true,
+			offset:        started.Offset,
+			content:       append(tail, "and more"...),
+			stored:        true,
+			expectedError: ErrTooLarge,
+		},
+		{
+			name:          "Первый кусок HTML",
+			upload:        upload,
+			found:         true,
+			content:       []byte("<html><script>alert(1)</script></html>"),
+			expectedError: ErrUnsupportedType,
+		},
+		{
+			name:          "Кусок, опередивший другой запрос",
+			upload:        started,
+			found:         true,
+			offset:        started.Offset,
+			content:       tail,
+			stored:        true,
+			expectedError: ErrOffsetMismatch,
+		},
+		{
+			name:          "Кусок просроченной загрузки",
+			upload:        expired,
+			found:         true,
+			content:       head,
+			expectedError: ErrNotFound,
+		},
+		{
+			name:          "Кусок чужой загрузки",
+			upload:        upload,
+			content:       head,
+			expectedError: ErrNotFound,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := mocks.NewRepository(t)
+			mockBlobs := mocks.NewBlobStore(t)
+			service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, testUploadConfig)
+
+			if tt.found {
+				mockRepository.On("GetUpload", tt.upload.Id, userId).Return(tt.upload, true, nil).Once()
+			} else {
+				mockRepository.On("GetUpload", tt.upload.Id, userId).Return(models.Upload{}, false, nil).Once()
+			}
+
+			var key string
+			if tt.stored {
+				mockBlobs.On("Put", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+					key = args.String(0)
+					_, err := io.ReadAll(args.Get(1).(io.Reader))
+					require.NoError(t, err)
+				}).Return(nil).Once()
+			}
+			if tt.stored && tt.expectedError != nil {
+				mockBlobs.On("Delete", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+					require.Equal(t, key, args.String(0))
+				}).Return(nil).Once()
+			}
+			if tt.stored && tt.expectedError != ErrTooLarge {
+				mockRepository.On("AddChunk", mock.MatchedBy(func(chunk models.UploadChunk) bool {
+					return chunk.UploadId == tt.upload.Id && chunk.Start == tt.offset &&
+						chunk.Size == int64(len(tt.content)) && chunk.StorageKey == key
+				}), mock.AnythingOfType("time.Time")).Return(tt.added, nil).Once()
+			}
+
+			offset, err := service.AppendChunk(userId, tt.upload.Id, tt.offset, bytes.NewReader(tt.content))
+			require.ErrorIs(t, err, tt.expectedError)
+			require.Equal(t, tt.expectedOffset, offset)
+		})
+	}
+}
+
+func TestService_FinishUpload(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	upload := models.Upload{
+		Id:        uuid.New(),
+		PersonId:  userId,
+		Name:      "cat.png",
+		Length:    int64(len(pngImage)),
+		Offset:    int64(len(pngImage)),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	incomplete := upload
+	incomplete.Offset = 10
+
+	head, tail := pngImage[:10], pngImage[10:]
+	chunks := []models.UploadChunk{
+		{UploadId: upload.Id, Start: 0, Size: int64(len(head)), StorageKey: "uploads/first"},
+		{UploadId: upload.Id, Start: int64(len(head)), Size: int64(len(tail)), StorageKey: "uploads/second"},
+	}
+
+	cases := []struct {
+		name          string
+		upload        models.Upload
+		secondChunk   []byte
+		expectedError error
+	}{
+		{
+			name:        "Завершение загрузки",
+			upload:      upload,
+			secondChunk: tail,
+		},
+		{
+			name:          "Завершение загрузки с потерянной частью куска",
+			upload:        upload,
+			secondChunk:   tail[:len(tail)-1],
+			expectedError: errChunkSize,
+		},
+		{
+			name:          "Завершение незаконченной загрузки",
+			upload:        incomplete,
+			expectedError: ErrUploadIncomplete,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := mocks.NewRepository(t)
+			mockBlobs := mocks.NewBlobStore(t)
+			service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, testUploadConfig)
+
+			mockRepository.On("GetUpload", tt.upload.Id, userId).Return(tt.upload, true, nil).Once()
+
+			var stored []byte
+			if tt.secondChunk != nil {
+				mockRepository.On("GetChunks", tt.upload.Id).Return(chunks, nil).Once()
+				mockBlobs.On("Open", "uploads/first").Return(io.NopCloser(bytes.NewReader(head)), nil).Once()
+				mockBlobs.On("Open", "uploads/second").Return(io.NopCloser(bytes.NewReader(tt.secondChunk)), nil).Once()
+			}
+			if tt.expectedError == nil {
+				mockBlobs.On("Put", isBlobKey("files/"), mock.Anything).Return(func(_ string, r io.Reader) error {
+					var err error
+					stored, err = io.ReadAll(r)
+					return err
+				}).Once()
+				mockBlobs.On("Open", isBlobKey("files/")).Return(func(string) (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(stored)), nil
+				}).Once()
+				mockBlobs.On("Put", isBlobKey("thumbnails/"), mock.Anything).Return(nil).Once()
+				mockRepository.On("CompleteUpload", tt.upload.Id, mock.MatchedBy(func(file models.File) bool {
+					return file.Name == "cat.png" && file.MimeType == "image/png" && file.Size == tt.upload.Length &&
+						file.UploadedBy == userId
+				})).Return(func(_ uuid.UUID, file models.File) (models.File, error) {
+					return file, nil
+				}).Once()
+				mockRepository.On("SetPreview", mock.MatchedBy(func(file models.File) bool {
+					return file.Width == 4 && len(file.Thumbnails) == 1
+				})).Return(nil).Once()
+				mockBlobs.On("Delete", "uploads/first").Return(nil).Once()
+				mockBlobs.On("Delete", "uploads/second").Return(nil).Once()
+			}
+
+			file, err := service.FinishUpload(userId, tt.upload.Id)
+			require.ErrorIs(t, err, tt.expectedError)
+			if tt.expectedError == nil {
+				require.Equal(t, pngImage, stored)
+				require.Equal(t, storageKey(file.Id), file.StorageKey)
+			}
+		})
+	}
+}
+
+func TestService_CollectUploads(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	mockBlobs := mocks.NewBlobStore(t)
+	service := NewFileService(slog.New(logHandler), mockRepository, mockBlobs, testUploadConfig)
+
+	abandoned := models.Upload{Id: uuid.New()}
+	empty := models.Upload{Id: uuid.New()}
+
+	mockRepository.On("GetExpiredUploads", mock.AnythingOfType("time.Time"), uint(collectBatch)).
+		Return([]models.Upload{abandoned, empty}, nil).Once()
+	mockRepository.On("GetChunks", abandoned.Id).
+		Return([]models.UploadChunk{{UploadId: abandoned.Id, StorageKey: "uploads/abandoned"}}, nil).Once()
+	mockBlobs.On("Delete", "uploads/abandoned").Return(nil).Once()
+	mockRepository.On("DeleteUpload", abandoned.Id).Return(nil).Once()
+	mockRepository.On("GetChunks", empty.Id).Return(nil, nil).Once()
+	mockRepository.On("DeleteUpload", empty.Id).Return(nil).Once()
+
+	collected, err := service.CollectUploads()
+	require.NoError(t, err)
+	require.Equal(t, 2, collected)
+}
@@ -1,12 +1,55 @@
 package message
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/internal/services/access"
+	"messenger/pkg/cursor"
 	"messenger/pkg/mapper"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrDeleted is returned for changes to a message deleted for everyone.
+	ErrDeleted = errors.New("message deleted")
+	// ErrInvalidReply is returned when a reply points at a message that does
+	// not exist or belongs to another chat.
+	ErrInvalidReply = errors.New("invalid reply")
+	// ErrInvalidThread is returned when a thread root is missing, belongs to
+	// another chat or is itself a thread message.
+	ErrInvalidThread = errors.New("invalid thread")
+	// ErrInvalidReaction is returned for a reaction that is not an emoji.
+	ErrInvalidReaction = errors.New("invalid reaction")
+	// ErrInvalidForward is returned when a forward names no messages or
+	// chats, too many of them, or a message that does not exist.
+	ErrInvalidForward = errors.New("invalid forward")
+	// ErrInvalidAttachment is returned when a message carries too many files
+	// or files the sender has not uploaded.
+	ErrInvalidAttachment = errors.New("invalid attachment")
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 100
+
+	// QuotePreviewLength is how many characters of a quoted message are shown in a reply.
+	QuotePreviewLength = 100
+
+	// MaxForwardMessages and MaxForwardChats bound a single forward.
+	MaxForwardMessages = 100
+	MaxForwardChats    = 10
+
+	// MaxAttachments is how many files a message may carry.
+	MaxAttachments = 10
+
+	// maxEmojiLength leaves room for flags, skin tones and ZWJ sequences.
+	maxEmojiLength = 16
 )
 
 //go:generate mockery --name=CacheRepository --output=./mocks --case=underscore
@@ -18,24 +61,71 @@ type CacheRepository interface {
 
 //go:generate mockery --name=Repository --output=./mocks --case=underscore
 type Repository interface {
+	// Add stores the message together with the links to its files.
 	Add(message models.Message) (models.Message, error)
-	GetByChat(chatId uuid.UUID) ([]models.Message, error)
+	// GetPage returns up to limit messages of the chat next to the cursor,
+	// ordered from the cursor outwards: newest first when paging backwards.
+	// Messages the user hid are skipped.
+	GetPage(userId, chatId uuid.UUID, c *cursor.Cursor, after bool, limit uint) ([]models.Message, error)
+	// GetSince returns up to limit messages after the cursor across all
+	// chats of the user, oldest first. Messages the user hid are skipped.
+	GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error)
+	GetThreadPage(userId, rootId uuid.UUID, c *cursor.Cursor, after bool, limit uint) ([]models.Message, error)
+	AddToThread(message models.Message) (domain.ThreadReply, error)
 	GetById(id uuid.UUID) (models.Message, error)
+	GetByIds(ids []uuid.UUID) ([]models.Message, error)
+	// Forward copies the messages, given in the order they were sent, into
+	// every chat on behalf of the user and returns the copies.
+	Forward(userId uuid.UUID, ids, chatIds []uuid.UUID) ([]models.Message, error)
 	Update(message models.Message) error
-	Delete(id uuid.UUID) error
+	Edit(id uuid.UUID, text string) (models.Message, error)
+	GetEdits(id uuid.UUID) ([]models.MessageEdit, error)
+	// GetQuotes returns the current state of the messages; ids of messages
+	// that no longer exist are skipped.
+	GetQuotes(ids []uuid.UUID) ([]models.Quote, error)
+	Delete(id uuid.UUID) (domain.DeletedMessage, error)
+	Hide(id, userId uuid.UUID) error
+	// AddReaction and RemoveReaction report whether the reaction changed and
+	// return the reactions of the message afterwards.
+	AddReaction(id, userId uuid.UUID, emoji string) (bool, models.Reactions, error)
+	RemoveReaction(id, userId uuid.UUID, emoji string) (bool, models.Reactions, error)
+	// MarkRead moves the user's read pointer in the chat forward to the
+	// message and reports whether it moved, along with the ids of messages
+	// that became read; it never moves backwards.
+	MarkRead(chatId, userId uuid.UUID, c cursor.Cursor) (bool, []uuid.UUID, error)
+	MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error)
+	GetStatuses(ids []uuid.UUID) ([]domain.MessageStatus, error)
+	GetReceipts(id uuid.UUID) ([]models.Receipt, error)
+}
+
+//go:generate mockery --name=AccessService --output=./mocks --case=underscore
+type AccessService interface {
+	CheckMember(chatId, userId uuid.UUID) error
+	Authorize(chatId, userId uuid.UUID, perm access.Permission) error
+}
+
+//go:generate mockery --name=FileRepository --output=./mocks --case=underscore
+type FileRepository interface {
+	// GetByIds returns the files; ids of files that do not exist are skipped.
+	GetByIds(ids []uuid.UUID) ([]models.File, error)
 }
 
 type Service struct {
 	log        *slog.Logger
 	cache      CacheRepository
 	repository Repository
+	files      FileRepository
+	access     AccessService
 }
 
-func NewMessageService(log *slog.Logger, cache CacheRepository, repository Repository) *Service {
+func NewMessageService(log *slog.Logger, cache CacheRepository, repository Repository,
+	files FileRepository, access AccessService) *Service {
 	return &Service{
 		log:        log,
 		cache:      cache,
 		repository: repository,
+		files:      files,
+		access:     access,
 	}
 }
 
@@ -45,6 +135,30 @@ func (m *Service) Add(message domain.MessageAdd) (models.Message, error) {
 		slog.String("op", op),
 	)
 
+	// Thread messages go through AddToThread, which keeps the root up to date.
+	if message.ThreadRootId != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidThread)
+	}
+
+	if err := m.access.CheckMember(message.ChatId, message.PersonId); err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var quote *models.Quote
+	if message.ReplyTo != nil {
+		q, err := m.replyQuote(message.ChatId, *message.ReplyTo)
+		if err != nil {
+			log.Warn("invalid reply", slog.String("err", err.Error()))
+			return models.Message{}, fmt.Errorf("%s: %w", op, err)
+		}
+		quote = &q
+	}
+
+	if err := m.checkAttachments(message.PersonId, message.Files); err != nil {
+		log.Warn("invalid attachments", slog.String("err", err.Error()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("mapping model to dto")
 	dto := mapper.MessageAddToMessage(message)
 
@@ -64,47 +178,253 @@ func (m *Service) Add(message domain.MessageAdd) (models.Message, error) {
 	}
 	log.Info("message added in cache")
 
+	msg.Quote = quote
 	return msg, nil
 }
 
-func (m *Service) GetByChat(chatId uuid.UUID) ([]models.Message, error) {
-	const op = "services.messenger.GetByChat"
+// Forward copies messages into other chats of the user. The user must be able
+// to read every source chat and write to every target chat; the copies keep
+// the order the messages were sent in.
+func (m *Service) Forward(userId uuid.UUID, forward domain.MessageForward) ([]models.Message, error) {
+	const op = "services.messenger.Forward"
 	log := m.log.With(
 		slog.String("op", op),
 	)
 
+	ids := unique(forward.MessageIds)
+	chatIds := unique(forward.ChatIds)
+	if len(ids) == 0 || len(ids) > MaxForwardMessages || len(chatIds) == 0 || len(chatIds) > MaxForwardChats {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidForward)
+	}
+
+	for _, chatId := range chatIds {
+		if err := m.access.CheckMember(chatId, userId); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	messages, err := m.repository.GetByIds(ids)
+	if err != nil {
+		log.Error("error with getting messages", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(messages) != len(ids) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidForward)
+	}
+
+	var sources []uuid.UUID
+	ordered := make([]uuid.UUID, len(messages))
+	for i, message := range messages {
+		if message.Status == models.MessageStatusDeleted {
+			return nil, fmt.Errorf("%s: %w", op, ErrDeleted)
+		}
+		if !slices.Contains(sources, message.Chat.Id) {
+			if err = m.access.CheckMember(message.Chat.Id, userId); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			sources = append(sources, message.Chat.Id)
+		}
+		ordered[i] = message.Id
+	}
+
+	log.Info("forwarding messages", slog.Int("messages", len(ordered)), slog.Int("chats", len(chatIds)))
+	forwarded, err := m.repository.Forward(userId, ordered, chatIds)
+	if err != nil {
+		log.Error("error with forwarding messages", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("messages forwarded")
+
+	return forwarded, nil
+}
+
+// unique returns the ids without repeats, keeping the first occurrence.
+func unique(ids []uuid.UUID) []uuid.UUID {
+	result := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func (m *Service) GetHistory(userId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error) {
+	const op = "services.messenger.GetHistory"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	if err := m.access.CheckMember(query.ChatId, userId); err != nil {
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	limit := historyLimit(query.Limit)
+
 	log.Info("getting messages for chat")
-	messages, err := m.repository.GetByChat(chatId)
+	// One extra row tells whether there is another page in the same direction.
+	messages, err := m.repository.GetPage(userId, query.ChatId, query.Cursor, query.After, limit+1)
 	if err != nil {
 		log.Error("error with getting messages for chat", slog.String("err", err.Error()))
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("messages received")
+
+	page, err := m.buildPage(messages, limit, query)
+	if err != nil {
+		log.Error("error with getting quotes", slog.String("err", err.Error()))
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+// GetThread returns a page of the thread started by rootId. It pages like
+// GetHistory; the chat of query is ignored.
+func (m *Service) GetThread(userId, rootId uuid.UUID, query domain.HistoryQuery) (domain.MessagePage, error) {
+	const op = "services.messenger.GetThread"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	root, err := m.getAccessible(userId, rootId)
+	if err != nil {
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if root.ThreadRootId != nil {
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, ErrInvalidThread)
+	}
+
+	limit := historyLimit(query.Limit)
+
+	log.Info("getting messages for thread")
+	messages, err := m.repository.GetThreadPage(userId, rootId, query.Cursor, query.After, limit+1)
+	if err != nil {
+		log.Error("error with getting messages for thread", slog.String("err", err.Error()))
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
 	}
 	log.Info("messages received")
+
+	page, err := m.buildPage(messages, limit, query)
+	if err != nil {
+		log.Error("error with getting quotes", slog.String("err", err.Error()))
+		return domain.MessagePage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+// AddToThread posts a message in the thread of message.ThreadRootId. Any
+// message of the main timeline can start a thread; threads do not nest.
+func (m *Service) AddToThread(message domain.MessageAdd) (domain.ThreadReply, error) {
+	const op = "services.messenger.AddToThread"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	if message.ThreadRootId == nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, ErrInvalidThread)
+	}
+
+	if err := m.access.CheckMember(message.ChatId, message.PersonId); err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	root, err := m.repository.GetById(*message.ThreadRootId)
+	if err != nil {
+		log.Error("error with getting thread root", slog.String("err", err.Error()))
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if root.Chat.Id != message.ChatId || root.ThreadRootId != nil {
+		log.Warn("invalid thread root", slog.String("rootId", root.Id.String()))
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, ErrInvalidThread)
+	}
+	if root.Status == models.MessageStatusDeleted {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, ErrDeleted)
+	}
+
+	var quote *models.Quote
+	if message.ReplyTo != nil {
+		q, err := m.replyQuote(message.ChatId, *message.ReplyTo)
+		if err != nil {
+			log.Warn("invalid reply", slog.String("err", err.Error()))
+			return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+		}
+		quote = &q
+	}
+
+	if err = m.checkAttachments(message.PersonId, message.Files); err != nil {
+		log.Warn("invalid attachments", slog.String("err", err.Error()))
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("adding message to thread")
+	reply, err := m.repository.AddToThread(mapper.MessageAddToMessage(message))
+	if err != nil {
+		log.Error("error with adding message to thread", slog.String("err", err.Error()))
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("message added to thread")
+
+	reply.Message.Quote = quote
+	return reply, nil
+}
+
+// GetSince returns the messages the user missed after the cursor, oldest
+// first, so that a reconnecting client can catch up before going live.
+func (m *Service) GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error) {
+	const op = "services.messenger.GetSince"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	if limit == 0 || limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	messages, err := m.repository.GetSince(userId, c, limit)
+	if err != nil {
+		log.Error("error with getting missed messages", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.AttachQuotes(messages); err != nil {
+		log.Error("error with getting quotes", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 	return messages, nil
 }
 
-func (m *Service) GetById(id uuid.UUID) (models.Message, error) {
+func (m *Service) GetById(userId, id uuid.UUID) (models.Message, error) {
 	const op = "services.messenger.GetById"
 	log := m.log.With(
 		slog.String("op", op),
 	)
 
 	log.Info("getting message")
-	message, err := m.repository.GetById(id)
+	message, err := m.getAccessible(userId, id)
 	if err != nil {
 		log.Error("error with getting message", slog.String("err", err.Error()))
 		return models.Message{}, fmt.Errorf("%s: %w", op, err)
 	}
 	log.Info("message received")
-	return message, nil
+
+	messages := []models.Message{message}
+	if err = m.AttachQuotes(messages); err != nil {
+		log.Error("error with getting quotes", slog.String("err", err.Error()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return messages[0], nil
 }
 
-func (m *Service) Update(message domain.MessageUpdate) error {
+func (m *Service) Update(userId uuid.UUID, message domain.MessageUpdate) error {
 	const op = "services.messenger.Update"
 	log := m.log.With(
 		slog.String("op", op),
 	)
 
+	if _, err := m.getAccessible(userId, message.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("mapping model to dto")
 	dto := mapper.MessageUpdateToMessage(message)
 
@@ -118,18 +438,454 @@ func (m *Service) Update(message domain.MessageUpdate) error {
 	return nil
 }
 
-func (m *Service) Delete(id uuid.UUID) error {
+// Edit changes the text of a message. Only the sender may edit it; the
+// previous text is kept in the edit history.
+func (m *Service) Edit(userId uuid.UUID, edit domain.MessageEditPayload) (models.Message, error) {
+	const op = "services.messenger.Edit"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	message, err := m.getAccessible(userId, edit.MessageId)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if message.PersonId != userId {
+		log.Warn("user is not the sender of message", slog.String("userId", userId.String()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, access.ErrForbidden)
+	}
+
+	if message.Status == models.MessageStatusDeleted {
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrDeleted)
+	}
+
+	log.Info("editing message")
+	edited, err := m.repository.Edit(edit.MessageId, edit.Message)
+	if err != nil {
+		log.Error("error with editing message", slog.String("err", err.Error()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("message edited")
+
+	messages := []models.Message{edited}
+	if err = m.AttachQuotes(messages); err != nil {
+		log.Error("error with getting quotes", slog.String("err", err.Error()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return messages[0], nil
+}
+
+// GetEdits returns the previous versions of a message, oldest first.
+func (m *Service) GetEdits(userId, id uuid.UUID) ([]models.MessageEdit, error) {
+	const op = "services.messenger.GetEdits"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	if _, err := m.getAccessible(userId, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("getting message edits")
+	edits, err := m.repository.GetEdits(id)
+	if err != nil {
+		log.Error("error with getting message edits", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("message edits received")
+	return edits, nil
+}
+
+// Delete deletes the message for everyone and returns its tombstone. The
+// sender may always do it, other members need the delete_messages permission.
+// For a thread reply the updated thread is returned as well.
+func (m *Service) Delete(userId, id uuid.UUID) (domain.DeletedMessage, error) {
 	const op = "services.messenger.Delete"
 	log := m.log.With(
 		slog.String("op", op),
 	)
 
+	message, err := m.getAccessible(userId, id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if message.PersonId != userId {
+		if err = m.access.Authorize(message.Chat.Id, userId, access.PermDeleteMessages); err != nil {
+			return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if message.Status == models.MessageStatusDeleted {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, ErrDeleted)
+	}
+
 	log.Info("deleting message")
-	err := m.repository.Delete(id)
+	deleted, err := m.repository.Delete(id)
 	if err != nil {
 		log.Error("error with deleting message", slog.String("err", err.Error()))
-		return fmt.Errorf("%s: %w", op, err)
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
 	}
 	log.Info("message deleted")
+	return deleted, nil
+}
+
+// Hide deletes the message for the user only; other members still see it.
+func (m *Service) Hide(userId, id uuid.UUID) (models.Message, error) {
+	const op = "services.messenger.Hide"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	message, err := m.getAccessible(userId, id)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("hiding message")
+	if err = m.repository.Hide(id, userId); err != nil {
+		log.Error("error with hiding message", slog.String("err", err.Error()))
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("message hidden")
+	return message, nil
+}
+
+// MarkRead marks every message of the chat up to and including the given one
+// as read by the user. Advanced is false when a later message was already read.
+func (m *Service) MarkRead(userId, id uuid.UUID) (domain.ReadResult, error) {
+	const op = "services.messenger.MarkRead"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	message, err := m.getAccessible(userId, id)
+	if err != nil {
+		return domain.ReadResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("marking messages read")
+	advanced, readIds, err := m.repository.MarkRead(message.Chat.Id, userId,
+		cursor.New(message.SendingTime, message.Id))
+	if err != nil {
+		log.Error("error with marking messages read", slog.String("err", err.Error()))
+		return domain.ReadResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("messages marked read", slog.Bool("advanced", advanced), slog.Int("count", len(readIds)))
+
+	statuses, err := m.statuses(readIds)
+	if err != nil {
+		log.Error("error with getting message statuses", slog.String("err", err.Error()))
+		return domain.ReadResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return domain.ReadResult{
+		Receipt: domain.ReadPayload{
+			ChatId:    message.Chat.Id,
+			MessageId: message.Id,
+			PersonId:  userId,
+		},
+		Advanced: advanced,
+		Statuses: statuses,
+	}, nil
+}
+
+// MarkDelivered records that the messages reached one of the user's devices
+// and returns the new status of those delivered for the first time.
+func (m *Service) MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]domain.MessageStatus, error) {
+	const op = "services.messenger.MarkDelivered"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("marking messages delivered")
+	delivered, err := m.repository.MarkDelivered(userId, ids)
+	if err != nil {
+		log.Error("error with marking messages delivered", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("messages marked delivered", slog.Int("count", len(delivered)))
+
+	statuses, err := m.statuses(delivered)
+	if err != nil {
+		log.Error("error with getting message statuses", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return statuses, nil
+}
+
+// GetReceipts returns the per-recipient delivery state of a message. Only
+// the sender may see it.
+func (m *Service) GetReceipts(userId, id uuid.UUID) ([]models.Receipt, error) {
+	const op = "services.messenger.GetReceipts"
+	log := m.log.With(
+		slog.String("op", op),
+	)
+
+	message, err := m.getAccessible(userId, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if message.PersonId != userId {
+		log.Warn("user is not the sender of message", slog.String("userId", userId.String()))
+		return nil, fmt.Errorf("%s: %w", op, access.ErrForbidden)
+	}
+
+	log.Info("getting receipts")
+	receipts, err := m.repository.GetReceipts(id)
+	if err != nil {
+		log.Error("error with getting receipts", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("receipts received")
+	return receipts, nil
+}
+
+func (m *Service) statuses(ids []uuid.UUID) ([]domain.MessageStatus, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	statuses, err := m.repository.GetStatuses(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		statuses[i].Status = deliveryStatus(statuses[i])
+	}
+	return statuses, nil
+}
+
+// deliveryStatus sums up a message's receipts: it is delivered or read once
+// every recipient has received or read it.
+func deliveryStatus(status domain.MessageStatus) string {
+	switch {
+	case status.Read >= status.Recipients:
+		return domain.DeliveryRead
+	case status.Delivered >= status.Recipients:
+		return domain.DeliveryDelivered
+	default:
+		return domain.DeliverySent
+	}
+}
+
+// AddReaction adds the user's reaction to a message. Each user may react
+// with each emoji once; the returned flag reports whether anything changed.
+func (m *Service) AddReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error) {
+	const op = "services.messenger.AddReaction"
+
+	payload, changed, err := m.react(userId, reaction, true)
+	if err != nil {
+		return domain.ReactionsPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return payload, changed, nil
+}
+
+// RemoveReaction takes the user's reaction back.
+func (m *Service) RemoveReaction(userId uuid.UUID, reaction domain.ReactionPayload) (domain.ReactionsPayload, bool, error) {
+	const op = "services.messenger.RemoveReaction"
+
+	payload, changed, err := m.react(userId, reaction, false)
+	if err != nil {
+		return domain.ReactionsPayload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return payload, changed, nil
+}
+
+func (m *Service) react(userId uuid.UUID, reaction domain.ReactionPayload, add bool) (domain.ReactionsPayload, bool, error) {
+	log := m.log.With(
+		slog.String("op", "services.messenger.react"),
+	)
+
+	if !validEmoji(reaction.Emoji) {
+		return domain.ReactionsPayload{}, false, ErrInvalidReaction
+	}
+
+	message, err := m.getAccessible(userId, reaction.MessageId)
+	if err != nil {
+		return domain.ReactionsPayload{}, false, err
+	}
+	if message.Status == models.MessageStatusDeleted {
+		return domain.ReactionsPayload{}, false, ErrDeleted
+	}
+
+	change := m.repository.RemoveReaction
+	if add {
+		change = m.repository.AddReaction
+	}
+
+	changed, reactions, err := change(reaction.MessageId, userId, reaction.Emoji)
+	if err != nil {
+		log.Error("error with changing reaction", slog.String("err", err.Error()))
+		return domain.ReactionsPayload{}, false, err
+	}
+
+	return domain.ReactionsPayload{
+		MessageId: reaction.MessageId,
+		ChatId:    message.Chat.Id,
+		PersonId:  userId,
+		Emoji:     reaction.Emoji,
+		Added:     add,
+		Reactions: reactions,
+	}, changed, nil
+}
+
+// validEmoji accepts short strings of pictographs. ASCII is only allowed for
+// the digits, '#' and '*' of keycap sequences.
+func validEmoji(emoji string) bool {
+	if !utf8.ValidString(emoji) || utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return false
+	}
+
+	pictograph := false
+	for _, r := range emoji {
+		switch {
+		case r >= utf8.RuneSelf:
+			if unicode.IsSpace(r) || unicode.IsControl(r) {
+				return false
+			}
+			pictograph = true
+		case r >= '0' && r <= '9', r == '#', r == '*':
+		default:
+			return false
+		}
+	}
+	return pictograph
+}
+
+func historyLimit(limit uint) uint {
+	switch {
+	case limit == 0:
+		return DefaultHistoryLimit
+	case limit > MaxHistoryLimit:
+		return MaxHistoryLimit
+	}
+	return limit
+}
+
+// buildPage turns up to limit+1 messages fetched from the cursor outwards
+// into a page in chronological order with cursors to both neighbours.
+func (m *Service) buildPage(messages []models.Message, limit uint, query domain.HistoryQuery) (domain.MessagePage, error) {
+	hasMore := uint(len(messages)) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if !query.After {
+		slices.Reverse(messages)
+	}
+
+	if err := m.AttachQuotes(messages); err != nil {
+		return domain.MessagePage{}, err
+	}
+
+	page := domain.MessagePage{
+		Messages: messages,
+	}
+	if len(messages) == 0 {
+		return page, nil
+	}
+
+	first, last := messages[0], messages[len(messages)-1]
+	if hasMore || (query.After && query.Cursor != nil) {
+		page.Before = cursor.New(first.SendingTime, first.Id).Encode()
+	}
+	page.After = cursor.New(last.SendingTime, last.Id).Encode()
+	return page, nil
+}
+
+// getAccessible loads the message and checks that the user belongs to its chat.
+func (m *Service) getAccessible(userId, id uuid.UUID) (models.Message, error) {
+	message, err := m.repository.GetById(id)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	if err = m.access.CheckMember(message.Chat.Id, userId); err != nil {
+		return models.Message{}, err
+	}
+	return message, nil
+}
+
+// checkAttachments makes sure the files exist, were uploaded by the sender and
+// are not attached twice.
+func (m *Service) checkAttachments(senderId uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxAttachments || len(unique(ids)) != len(ids) {
+		return ErrInvalidAttachment
+	}
+
+	files, err := m.files.GetByIds(ids)
+	if err != nil {
+		return err
+	}
+	if len(files) != len(ids) {
+		return ErrInvalidAttachment
+	}
+	for _, file := range files {
+		if file.UploadedBy != senderId {
+			return ErrInvalidAttachment
+		}
+	}
+	return nil
+}
+
+// replyQuote checks that the quoted message exists in the chat and has not
+// been deleted, and returns its preview.
+func (m *Service) replyQuote(chatId, replyTo uuid.UUID) (models.Quote, error) {
+	quotes, err := m.repository.GetQuotes([]uuid.UUID{replyTo})
+	if err != nil {
+		return models.Quote{}, err
+	}
+
+	if len(quotes) == 0 || quotes[0].ChatId != chatId {
+		return models.Quote{}, ErrInvalidReply
+	}
+	if quotes[0].Deleted {
+		return models.Quote{}, ErrDeleted
+	}
+	return preview(quotes[0]), nil
+}
+
+// AttachQuotes sets the quote preview of every reply among messages.
+func (m *Service) AttachQuotes(messages []models.Message) error {
+	var ids []uuid.UUID
+	for _, message := range messages {
+		if message.ReplyTo != nil && !slices.Contains(ids, *message.ReplyTo) {
+			ids = append(ids, *message.ReplyTo)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quotes, err := m.repository.GetQuotes(ids)
+	if err != nil {
+		return err
+	}
+
+	byId := make(map[uuid.UUID]models.Quote, len(quotes))
+	for _, quote := range quotes {
+		byId[quote.Id] = preview(quote)
+	}
+	for i, message := range messages {
+		if message.ReplyTo == nil {
+			continue
+		}
+		if quote, ok := byId[*message.ReplyTo]; ok {
+			messages[i].Quote = &quote
+		}
+	}
 	return nil
 }
+
+func preview(quote models.Quote) models.Quote {
+	if text := []rune(quote.MessageText); len(text) > QuotePreviewLength {
+		quote.MessageText = string(text[:QuotePreviewLength])
+	}
+	return quote
+}
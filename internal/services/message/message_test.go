@@ -1,13 +1,17 @@
 package message
 
 import (
+	"errors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"log/slog"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/internal/services/access"
 	mocks2 "messenger/internal/services/message/mocks"
+	"messenger/pkg/cursor"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,13 +25,15 @@ func TestMessenger_Add(t *testing.T) {
 		Level: slog.LevelDebug,
 	})
 
-	mockMessengerRepo := mocks2.NewMessengerRepo(t)
-	mockMessengerCacheRepo := mocks2.NewMessengerCacheRepo(t)
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockMessengerCacheRepo := mocks2.NewCacheRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
 
 	service := &Service{
 		log:        slog.New(logHandler),
 		cache:      mockMessengerCacheRepo,
 		repository: mockMessengerRepo,
+		access:     mockAccess,
 	}
 
 	personId := uuid.New()
@@ -89,7 +95,9 @@ func TestMessenger_Add(t *testing.T) {
 			t.Parallel()
 			mockMessengerRepo.ExpectedCalls = nil
 			mockMessengerCacheRepo.ExpectedCalls = nil
+			mockAccess.ExpectedCalls = nil
 
+			mockAccess.On("CheckMember", c.Message.ChatId, c.Message.PersonId).Return(nil).Once()
 			mockMessengerRepo.On("Add", c.mockArgument.message).Return(c.mockReturnMessage, c.mockReturnError).Once()
 			mockMessengerCacheRepo.On("Add", c.mockArgument.message).Return(c.mockReturnError).Once()
 
@@ -100,58 +108,413 @@ func TestMessenger_Add(t *testing.T) {
 	}
 }
 
-func TestMessenger_GetByChat(t *testing.T) {
-	type args struct {
-		chatId uuid.UUID
+func TestMessenger_AddReply(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	personId := uuid.New()
+	chatId := uuid.New()
+	replyTo := uuid.New()
+	longText := strings.Repeat("я", QuotePreviewLength+10)
+
+	cases := []struct {
+		name          string
+		quotes        []models.Quote
+		expectedQuote *models.Quote
+		expectedError error
+	}{
+		{
+			name: "Ответ на сообщение из того же чата",
+			quotes: []models.Quote{
+				{Id: replyTo, PersonId: personId, ChatId: chatId, MessageText: longText, Edited: true},
+			},
+			expectedQuote: &models.Quote{
+				Id:          replyTo,
+				PersonId:    personId,
+				ChatId:      chatId,
+				MessageText: longText[:len("я")*QuotePreviewLength],
+				Edited:      true,
+			},
+		},
+		{
+			name:          "Сообщение из другого чата",
+			quotes:        []models.Quote{{Id: replyTo, ChatId: uuid.New()}},
+			expectedError: ErrInvalidReply,
+		},
+		{
+			name:          "Сообщение не найдено",
+			expectedError: ErrInvalidReply,
+		},
+		{
+			name:          "Сообщение удалено",
+			quotes:        []models.Quote{{Id: replyTo, ChatId: chatId, Deleted: true}},
+			expectedError: ErrDeleted,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			mockMessengerCacheRepo := mocks2.NewCacheRepository(t)
+			mockAccess := mocks2.NewAccessService(t)
+
+			service := &Service{
+				log:        slog.New(logHandler),
+				cache:      mockMessengerCacheRepo,
+				repository: mockMessengerRepo,
+				access:     mockAccess,
+			}
+
+			add := domain.MessageAdd{
+				PersonId: personId,
+				ChatId:   chatId,
+				Message:  "reply",
+				ReplyTo:  &replyTo,
+			}
+			dto := models.Message{
+				PersonId:    personId,
+				Chat:        models.Chat{Id: chatId},
+				MessageText: "reply",
+				ReplyTo:     &replyTo,
+			}
+
+			mockAccess.On("CheckMember", chatId, personId).Return(nil).Once()
+			mockMessengerRepo.On("GetQuotes", []uuid.UUID{replyTo}).Return(c.quotes, nil).Once()
+			if c.expectedError == nil {
+				mockMessengerRepo.On("Add", dto).Return(dto, nil).Once()
+				mockMessengerCacheRepo.On("Add", dto).Return(nil).Once()
+			}
+
+			msg, err := service.Add(add)
+			require.ErrorIs(t, err, c.expectedError)
+			require.Equal(t, c.expectedQuote, msg.Quote)
+		})
+	}
+}
+
+func TestMessenger_AddAttachments(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	personId := uuid.New()
+	chatId := uuid.New()
+	own := models.File{Id: uuid.New(), UploadedBy: personId}
+	foreign := models.File{Id: uuid.New(), UploadedBy: uuid.New()}
+	tooMany := make([]uuid.UUID, MaxAttachments+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.New()
+	}
+
+	cases := []struct {
+		name          string
+		files         []uuid.UUID
+		mockFiles     []models.File
+		expectedError error
+	}{
+		{
+			name:      "Сообщение со своим файлом",
+			files:     []uuid.UUID{own.Id},
+			mockFiles: []models.File{own},
+		},
+		{
+			name:          "Чужой файл",
+			files:         []uuid.UUID{own.Id, foreign.Id},
+			mockFiles:     []models.File{own, foreign},
+			expectedError: ErrInvalidAttachment,
+		},
+		{
+			name:          "Файл не найден",
+			files:         []uuid.UUID{own.Id, uuid.New()},
+			mockFiles:     []models.File{own},
+			expectedError: ErrInvalidAttachment,
+		},
+		{
+			name:          "Файл прикреплён дважды",
+			files:         []uuid.UUID{own.Id, own.Id},
+			expectedError: ErrInvalidAttachment,
+		},
+		{
+			name:          "Слишком много файлов",
+			files:         tooMany,
+			expectedError: ErrInvalidAttachment,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			mockMessengerCacheRepo := mocks2.NewCacheRepository(t)
+			mockFileRepo := mocks2.NewFileRepository(t)
+			mockAccess := mocks2.NewAccessService(t)
+
+			service := NewMessageService(slog.New(logHandler), mockMessengerCacheRepo, mockMessengerRepo,
+				mockFileRepo, mockAccess)
+
+			dto := models.Message{
+				PersonId:    personId,
+				Chat:        models.Chat{Id: chatId},
+				MessageText: "look",
+				Files:       c.files,
+			}
+
+			mockAccess.On("CheckMember", chatId, personId).Return(nil).Once()
+			if c.mockFiles != nil {
+				mockFileRepo.On("GetByIds", c.files).Return(c.mockFiles, nil).Once()
+			}
+			if c.expectedError == nil {
+				mockMessengerRepo.On("Add", dto).Return(dto, nil).Once()
+				mockMessengerCacheRepo.On("Add", dto).Return(nil).Once()
+			}
+
+			msg, err := service.Add(domain.MessageAdd{
+				PersonId: personId,
+				ChatId:   chatId,
+				Message:  "look",
+				Files:    c.files,
+			})
+			require.ErrorIs(t, err, c.expectedError)
+			if c.expectedError == nil {
+				require.Equal(t, models.FileIds(c.files), msg.Files)
+			}
+		})
 	}
+}
 
+func TestMessenger_AddToThread(t *testing.T) {
 	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
 
-	mockMessengerRepo := mocks2.NewMessengerRepo(t)
-	mockMessengerCacheRepo := mocks2.NewMessengerCacheRepo(t)
+	personId := uuid.New()
+	chatId := uuid.New()
+	rootId := uuid.New()
+	nestedRootId := uuid.New()
+
+	cases := []struct {
+		name          string
+		root          models.Message
+		expectedError error
+	}{
+		{
+			name: "Сообщение в ветке",
+			root: models.Message{Id: rootId, Chat: models.Chat{Id: chatId}},
+		},
+		{
+			name:          "Корень из другого чата",
+			root:          models.Message{Id: rootId, Chat: models.Chat{Id: uuid.New()}},
+			expectedError: ErrInvalidThread,
+		},
+		{
+			name:          "Корень сам находится в ветке",
+			root:          models.Message{Id: rootId, Chat: models.Chat{Id: chatId}, ThreadRootId: &nestedRootId},
+			expectedError: ErrInvalidThread,
+		},
+		{
+			name: "Корень удален",
+			root: models.Message{
+				Id:     rootId,
+				Chat:   models.Chat{Id: chatId},
+				Status: models.MessageStatusDeleted,
+			},
+			expectedError: ErrDeleted,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			mockAccess := mocks2.NewAccessService(t)
+
+			service := &Service{
+				log:        slog.New(logHandler),
+				repository: mockMessengerRepo,
+				access:     mockAccess,
+			}
+
+			add := domain.MessageAdd{
+				PersonId:     personId,
+				ChatId:       chatId,
+				Message:      "in thread",
+				ThreadRootId: &rootId,
+			}
+			dto := models.Message{
+				PersonId:     personId,
+				Chat:         models.Chat{Id: chatId},
+				MessageText:  "in thread",
+				ThreadRootId: &rootId,
+			}
+			lastReplyAt := time.Now().UTC()
+			reply := domain.ThreadReply{
+				Message: dto,
+				Thread: domain.ThreadSummary{
+					RootId:      rootId,
+					ChatId:      chatId,
+					ReplyCount:  1,
+					LastReplyAt: &lastReplyAt,
+				},
+				Participants: []uuid.UUID{personId},
+			}
+
+			mockAccess.On("CheckMember", chatId, personId).Return(nil).Once()
+			mockMessengerRepo.On("GetById", rootId).Return(c.root, nil).Once()
+			if c.expectedError == nil {
+				mockMessengerRepo.On("AddToThread", dto).Return(reply, nil).Once()
+			}
+
+			got, err := service.AddToThread(add)
+			require.ErrorIs(t, err, c.expectedError)
+			if c.expectedError == nil {
+				require.Equal(t, reply, got)
+			}
+		})
+	}
+}
+
+func TestMessenger_AddRejectsThread(t *testing.T) {
+	service := &Service{
+		log: slog.New(slog.NewTextHandler(os.Stdout, nil)),
+	}
+
+	rootId := uuid.New()
+	_, err := service.Add(domain.MessageAdd{ThreadRootId: &rootId})
+	require.ErrorIs(t, err, ErrInvalidThread)
+}
+
+func TestMessenger_GetThread(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	rootId := uuid.New()
+	base := time.Now().UTC()
+	newest := models.Message{Id: uuid.New(), ThreadRootId: &rootId, SendingTime: base.Add(time.Minute)}
+	oldest := models.Message{Id: uuid.New(), ThreadRootId: &rootId, SendingTime: base}
+
+	mockMessengerRepo.On("GetById", rootId).Return(models.Message{Id: rootId, Chat: models.Chat{Id: chatId}}, nil).Once()
+	mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+	mockMessengerRepo.On("GetThreadPage", userId, rootId, (*cursor.Cursor)(nil), false, uint(DefaultHistoryLimit+1)).
+		Return([]models.Message{newest, oldest}, nil).Once()
+
+	page, err := service.GetThread(userId, rootId, domain.HistoryQuery{})
+	require.NoError(t, err)
+	require.Equal(t, []models.Message{oldest, newest}, page.Messages)
+	require.Empty(t, page.Before)
+	require.Equal(t, cursor.New(newest.SendingTime, newest.Id).Encode(), page.After)
+}
+
+func TestMessenger_GetHistory(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockMessengerCacheRepo := mocks2.NewCacheRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
 
 	service := &Service{
 		log:        slog.New(logHandler),
 		cache:      mockMessengerCacheRepo,
 		repository: mockMessengerRepo,
+		access:     mockAccess,
 	}
 
 	chatId := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	messages := make([]models.Message, 3)
+	for i := range messages {
+		messages[i] = models.Message{
+			Id:          uuid.New(),
+			Chat:        models.Chat{Id: chatId},
+			SendingTime: now.Add(time.Duration(i) * time.Second),
+		}
+	}
+	cursorOf := func(msg models.Message) string {
+		return cursor.New(msg.SendingTime, msg.Id).Encode()
+	}
+	middle := cursor.New(messages[0].SendingTime, messages[0].Id)
 
 	cases := []struct {
 		name               string
-		chatId             uuid.UUID
-		mockArgument       args
+		query              domain.HistoryQuery
+		mockAccessError    error
+		mockLimit          uint
 		mockReturnMessages []models.Message
-		mockReturnError    error
-		expectedMessages   []models.Message
+		expectedPage       domain.MessagePage
 		expectedError      error
 	}{
 		{
-			name:   "Успешное получение чатов",
-			chatId: chatId,
-			mockArgument: args{
-				chatId: chatId,
+			name:               "Последние сообщения, есть более старые",
+			query:              domain.HistoryQuery{ChatId: chatId, Limit: 2},
+			mockLimit:          3,
+			mockReturnMessages: []models.Message{messages[2], messages[1], messages[0]},
+			expectedPage: domain.MessagePage{
+				Messages: []models.Message{messages[1], messages[2]},
+				Before:   cursorOf(messages[1]),
+				After:    cursorOf(messages[2]),
+			},
+		},
+		{
+			name:               "Сообщения после курсора",
+			query:              domain.HistoryQuery{ChatId: chatId, Cursor: &middle, After: true, Limit: 5},
+			mockLimit:          6,
+			mockReturnMessages: []models.Message{messages[1], messages[2]},
+			expectedPage: domain.MessagePage{
+				Messages: []models.Message{messages[1], messages[2]},
+				Before:   cursorOf(messages[1]),
+				After:    cursorOf(messages[2]),
 			},
+		},
+		{
+			name:               "Пустой чат и лимит по умолчанию",
+			query:              domain.HistoryQuery{ChatId: chatId},
+			mockLimit:          DefaultHistoryLimit + 1,
 			mockReturnMessages: []models.Message{},
-			mockReturnError:    nil,
-			expectedMessages:   []models.Message{},
-			expectedError:      nil,
+			expectedPage: domain.MessagePage{
+				Messages: []models.Message{},
+			},
+		},
+		{
+			name:               "Лимит больше максимального",
+			query:              domain.HistoryQuery{ChatId: chatId, Limit: 1000},
+			mockLimit:          MaxHistoryLimit + 1,
+			mockReturnMessages: []models.Message{},
+			expectedPage: domain.MessagePage{
+				Messages: []models.Message{},
+			},
+		},
+		{
+			name:            "Получение истории не участником чата",
+			query:           domain.HistoryQuery{ChatId: chatId},
+			mockAccessError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			t.Parallel()
-			mockMessengerRepo.ExpectedCalls = nil
-			mockMessengerCacheRepo.ExpectedCalls = nil
+			userId := uuid.New()
+			mockAccess.On("CheckMember", chatId, userId).Return(c.mockAccessError).Once()
+			if c.mockAccessError == nil {
+				mockMessengerRepo.On("GetPage", userId, chatId, c.query.Cursor, c.query.After, c.mockLimit).
+					Return(c.mockReturnMessages, nil).Once()
+			}
 
-			mockMessengerRepo.On("GetByChat", c.mockArgument.chatId).Return(c.mockReturnMessages, c.mockReturnError).Once()
-			messages, err := service.GetByChat(c.chatId)
-			require.Equal(t, c.expectedMessages, messages)
-			require.Equal(t, c.expectedError, err)
+			page, err := service.GetHistory(userId, c.query)
+			require.Equal(t, c.expectedPage, page)
+			require.ErrorIs(t, err, c.expectedError)
 		})
 	}
 }
@@ -165,7 +528,8 @@ func TestMessenger_GetById(t *testing.T) {
 		Level: slog.LevelDebug,
 	})
 
-	mockMessengerRepo := mocks2.NewMessengerRepo(t)
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
 
 	msgId := uuid.New()
 
@@ -173,6 +537,7 @@ func TestMessenger_GetById(t *testing.T) {
 		log:        slog.New(logHandler),
 		cache:      nil,
 		repository: mockMessengerRepo,
+		access:     mockAccess,
 	}
 
 	cases := []struct {
@@ -181,6 +546,7 @@ func TestMessenger_GetById(t *testing.T) {
 		args              args
 		mockReturnMessage models.Message
 		mockReturnError   error
+		mockAccessError   error
 		expectedMessage   models.Message
 		expectedError     error
 	}{
@@ -199,16 +565,33 @@ func TestMessenger_GetById(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name:  "Получение сообщения не участником чата",
+			msgId: msgId,
+			args: args{
+				msgId: msgId,
+			},
+			mockReturnMessage: models.Message{
+				Id: msgId,
+			},
+			mockAccessError: access.ErrForbidden,
+			expectedMessage: models.Message{},
+			expectedError:   access.ErrForbidden,
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			mockMessengerRepo.ExpectedCalls = nil
+			mockAccess.ExpectedCalls = nil
+
+			userId := uuid.New()
 			mockMessengerRepo.On("GetById", c.args.msgId).Return(c.mockReturnMessage, c.mockReturnError).Once()
+			mockAccess.On("CheckMember", c.mockReturnMessage.Chat.Id, userId).Return(c.mockAccessError).Once()
 
-			message, err := service.GetById(c.msgId)
+			message, err := service.GetById(userId, c.msgId)
 			require.Equal(t, c.expectedMessage, message)
-			require.Equal(t, c.expectedError, err)
+			require.ErrorIs(t, err, c.expectedError)
 		})
 	}
 }
@@ -222,7 +605,7 @@ func TestMessenger_Update(t *testing.T) {
 		Level: slog.LevelDebug,
 	})
 
-	mockMessengerRepo := mocks2.NewMessengerRepo(t)
+	mockMessengerRepo := mocks2.NewRepository(t)
 	msgId := uuid.New()
 	msg := domain.MessageUpdate{
 		Id:      msgId,
@@ -230,10 +613,13 @@ func TestMessenger_Update(t *testing.T) {
 		Status:  "not read",
 	}
 
+	mockAccess := mocks2.NewAccessService(t)
+
 	service := &Service{
 		log:        slog.New(logHandler),
 		cache:      nil,
 		repository: mockMessengerRepo,
+		access:     mockAccess,
 	}
 
 	cases := []struct {
@@ -261,9 +647,18 @@ func TestMessenger_Update(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			mockMessengerRepo.ExpectedCalls = nil
+			mockAccess.ExpectedCalls = nil
+
+			userId := uuid.New()
+			chatId := uuid.New()
+			mockMessengerRepo.On("GetById", c.msg.Id).Return(models.Message{
+				Id:   c.msg.Id,
+				Chat: models.Chat{Id: chatId},
+			}, nil).Once()
+			mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
 			mockMessengerRepo.On("Update", c.args.msg).Return(c.mockReturnError).Once()
 
-			err := service.Update(msg)
+			err := service.Update(userId, msg)
 			require.Equal(t, c.expectedError, err)
 		})
 	}
@@ -278,39 +673,742 @@ func TestMessenger_Delete(t *testing.T) {
 		Level: slog.LevelDebug,
 	})
 
-	mockMessengerRepo := mocks2.NewMessengerRepo(t)
+	mockMessengerRepo := mocks2.NewRepository(t)
 	msgId := uuid.New()
 
+	mockAccess := mocks2.NewAccessService(t)
+
 	service := &Service{
 		log:        slog.New(logHandler),
 		cache:      nil,
 		repository: mockMessengerRepo,
+		access:     mockAccess,
 	}
 
 	cases := []struct {
-		name            string
-		input           uuid.UUID
-		args            args
-		mockReturnError error
-		expectedError   error
+		name             string
+		input            uuid.UUID
+		args             args
+		ownMessage       bool
+		status           string
+		mockAuthorizeErr error
+		mockReturnError  error
+		thread           *domain.ThreadSummary
+		expectedError    error
+		expectedRepoCall bool
 	}{
 		{
-			name:  "Успешное удаление",
+			name:  "Успешное удаление своего сообщения",
 			input: msgId,
 			args: args{
 				msgId: msgId,
 			},
-			mockReturnError: nil,
-			expectedError:   nil,
+			ownMessage:       true,
+			mockReturnError:  nil,
+			expectedError:    nil,
+			expectedRepoCall: true,
+		},
+		{
+			name:  "Удаление ответа в треде",
+			input: msgId,
+			args: args{
+				msgId: msgId,
+			},
+			ownMessage:       true,
+			thread:           &domain.ThreadSummary{RootId: uuid.New(), ReplyCount: 1},
+			expectedRepoCall: true,
+		},
+		{
+			name:  "Удаление чужого сообщения администратором",
+			input: msgId,
+			args: args{
+				msgId: msgId,
+			},
+			expectedError:    nil,
+			expectedRepoCall: true,
+		},
+		{
+			name:  "Удаление чужого сообщения без прав",
+			input: msgId,
+			args: args{
+				msgId: msgId,
+			},
+			mockAuthorizeErr: access.ErrForbidden,
+			expectedError:    access.ErrForbidden,
+		},
+		{
+			name:  "Повторное удаление сообщения",
+			input: msgId,
+			args: args{
+				msgId: msgId,
+			},
+			ownMessage:    true,
+			status:        models.MessageStatusDeleted,
+			expectedError: ErrDeleted,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			mockMessengerRepo.ExpectedCalls = nil
-			mockMessengerRepo.On("Delete", c.args.msgId).Return(c.mockReturnError).Once()
-			err := service.Delete(c.input)
-			require.Equal(t, c.expectedError, err)
+			mockAccess.ExpectedCalls = nil
+
+			userId := uuid.New()
+			chatId := uuid.New()
+			authorId := uuid.New()
+			if c.ownMessage {
+				authorId = userId
+			}
+			mockMessengerRepo.On("GetById", c.input).Return(models.Message{
+				Id:       c.input,
+				PersonId: authorId,
+				Chat:     models.Chat{Id: chatId},
+				Status:   c.status,
+			}, nil).Once()
+			mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+			if !c.ownMessage {
+				mockAccess.On("Authorize", chatId, userId, access.PermDeleteMessages).
+					Return(c.mockAuthorizeErr).Once()
+			}
+			if c.expectedRepoCall {
+				mockMessengerRepo.On("Delete", c.args.msgId).Return(domain.DeletedMessage{
+					Message: models.Message{
+						Id:     c.args.msgId,
+						Status: models.MessageStatusDeleted,
+					},
+					Thread: c.thread,
+				}, c.mockReturnError).Once()
+			}
+
+			deleted, err := service.Delete(userId, c.input)
+			require.ErrorIs(t, err, c.expectedError)
+			if c.expectedRepoCall {
+				require.Equal(t, models.MessageStatusDeleted, deleted.Message.Status)
+				require.Equal(t, c.thread, deleted.Thread)
+			}
+		})
+	}
+}
+
+func TestMessenger_Edit(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	editedAt := time.Now().UTC()
+
+	cases := []struct {
+		name             string
+		senderId         uuid.UUID
+		expectedRepoCall bool
+		expectedError    error
+	}{
+		{
+			name:             "Успешное редактирование своего сообщения",
+			senderId:         userId,
+			expectedRepoCall: true,
+		},
+		{
+			name:          "Редактирование чужого сообщения",
+			senderId:      uuid.New(),
+			expectedError: access.ErrForbidden,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			edit := domain.MessageEditPayload{
+				MessageId: uuid.New(),
+				Message:   "Edited msg",
+			}
+			mockMessengerRepo.On("GetById", edit.MessageId).Return(models.Message{
+				Id:       edit.MessageId,
+				PersonId: c.senderId,
+				Chat:     models.Chat{Id: chatId},
+			}, nil).Once()
+			mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+
+			expected := models.Message{}
+			if c.expectedRepoCall {
+				expected = models.Message{
+					Id:          edit.MessageId,
+					PersonId:    userId,
+					Chat:        models.Chat{Id: chatId},
+					MessageText: edit.Message,
+					EditedAt:    &editedAt,
+				}
+				mockMessengerRepo.On("Edit", edit.MessageId, edit.Message).Return(expected, nil).Once()
+			}
+
+			msg, err := service.Edit(userId, edit)
+			require.Equal(t, expected, msg)
+			require.ErrorIs(t, err, c.expectedError)
+		})
+	}
+}
+
+func TestMessenger_GetEdits(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+	edits := []models.MessageEdit{
+		{Id: uuid.New(), MessageId: msgId, MessageText: "first", EditedAt: time.Now().UTC()},
+	}
+
+	mockMessengerRepo.On("GetById", msgId).Return(models.Message{
+		Id:   msgId,
+		Chat: models.Chat{Id: chatId},
+	}, nil).Once()
+	mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+	mockMessengerRepo.On("GetEdits", msgId).Return(edits, nil).Once()
+
+	got, err := service.GetEdits(userId, msgId)
+	require.NoError(t, err)
+	require.Equal(t, edits, got)
+}
+
+func TestMessenger_GetSince(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	since := cursor.New(time.Now().UTC(), uuid.New())
+	messages := []models.Message{{Id: uuid.New()}, {Id: uuid.New()}}
+
+	cases := []struct {
+		name      string
+		limit     uint
+		wantLimit uint
+	}{
+		{
+			name:      "Лимит в пределах максимума",
+			limit:     10,
+			wantLimit: 10,
+		},
+		{
+			name:      "Лимит не задан",
+			limit:     0,
+			wantLimit: MaxHistoryLimit,
+		},
+		{
+			name:      "Лимит больше максимума",
+			limit:     MaxHistoryLimit + 1,
+			wantLimit: MaxHistoryLimit,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			service := &Service{
+				log:        slog.New(logHandler),
+				repository: mockMessengerRepo,
+			}
+
+			mockMessengerRepo.On("GetSince", userId, since, c.wantLimit).Return(messages, nil).Once()
+
+			got, err := service.GetSince(userId, since, c.limit)
+			require.NoError(t, err)
+			require.Equal(t, messages, got)
+		})
+	}
+}
+
+func TestMessenger_GetByIdQuote(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	quoted := models.Quote{Id: uuid.New(), ChatId: chatId, Deleted: true}
+	msg := models.Message{
+		Id:      uuid.New(),
+		Chat:    models.Chat{Id: chatId},
+		ReplyTo: &quoted.Id,
+	}
+
+	mockMessengerRepo.On("GetById", msg.Id).Return(msg, nil).Once()
+	mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+	mockMessengerRepo.On("GetQuotes", []uuid.UUID{quoted.Id}).Return([]models.Quote{quoted}, nil).Once()
+
+	got, err := service.GetById(userId, msg.Id)
+	require.NoError(t, err)
+	require.Equal(t, &quoted, got.Quote)
+}
+
+func TestMessenger_Hide(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	msg := models.Message{
+		Id:       uuid.New(),
+		PersonId: uuid.New(),
+		Chat:     models.Chat{Id: chatId},
+	}
+
+	mockMessengerRepo.On("GetById", msg.Id).Return(msg, nil).Once()
+	mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+	mockMessengerRepo.On("Hide", msg.Id, userId).Return(nil).Once()
+
+	hidden, err := service.Hide(userId, msg.Id)
+	require.NoError(t, err)
+	require.Equal(t, msg, hidden)
+}
+
+func TestMessenger_MarkRead(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	senderId := uuid.New()
+
+	cases := []struct {
+		name             string
+		mockAdvanced     bool
+		mockReadIds      []uuid.UUID
+		mockStatuses     []domain.MessageStatus
+		expectedStatuses []domain.MessageStatus
+	}{
+		{
+			name:         "Указатель прочтения сдвинут",
+			mockAdvanced: true,
+			mockReadIds:  []uuid.UUID{uuid.New()},
+			mockStatuses: []domain.MessageStatus{
+				{ChatId: chatId, SenderId: senderId, Recipients: 2, Delivered: 2, Read: 1},
+			},
+			expectedStatuses: []domain.MessageStatus{
+				{ChatId: chatId, SenderId: senderId, Recipients: 2, Delivered: 2, Read: 1,
+					Status: domain.DeliveryDelivered},
+			},
+		},
+		{
+			name:         "Более позднее сообщение уже прочитано",
+			mockAdvanced: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := models.Message{
+				Id:          uuid.New(),
+				PersonId:    senderId,
+				Chat:        models.Chat{Id: chatId},
+				SendingTime: time.Now().UTC(),
+			}
+			mockMessengerRepo.On("GetById", msg.Id).Return(msg, nil).Once()
+			mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+			mockMessengerRepo.On("MarkRead", chatId, userId, cursor.New(msg.SendingTime, msg.Id)).
+				Return(c.mockAdvanced, c.mockReadIds, nil).Once()
+			if len(c.mockReadIds) > 0 {
+				mockMessengerRepo.On("GetStatuses", c.mockReadIds).Return(c.mockStatuses, nil).Once()
+			}
+
+			result, err := service.MarkRead(userId, msg.Id)
+			require.NoError(t, err)
+			require.Equal(t, domain.ReadResult{
+				Receipt: domain.ReadPayload{
+					ChatId:    chatId,
+					MessageId: msg.Id,
+					PersonId:  userId,
+				},
+				Advanced: c.mockAdvanced,
+				Statuses: c.expectedStatuses,
+			}, result)
+		})
+	}
+}
+
+func TestMessenger_MarkDelivered(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+	}
+
+	userId := uuid.New()
+
+	cases := []struct {
+		name           string
+		status         domain.MessageStatus
+		expectedStatus string
+	}{
+		{
+			name:           "Доставлено не всем получателям",
+			status:         domain.MessageStatus{Recipients: 3, Delivered: 2},
+			expectedStatus: domain.DeliverySent,
+		},
+		{
+			name:           "Доставлено всем получателям",
+			status:         domain.MessageStatus{Recipients: 3, Delivered: 3, Read: 1},
+			expectedStatus: domain.DeliveryDelivered,
+		},
+		{
+			name:           "Прочитано всеми получателями",
+			status:         domain.MessageStatus{Recipients: 3, Delivered: 3, Read: 3},
+			expectedStatus: domain.DeliveryRead,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ids := []uuid.UUID{uuid.New(), uuid.New()}
+			delivered := ids[:1]
+			mockMessengerRepo.On("MarkDelivered", userId, ids).Return(delivered, nil).Once()
+			mockMessengerRepo.On("GetStatuses", delivered).Return([]domain.MessageStatus{c.status}, nil).Once()
+
+			statuses, err := service.MarkDelivered(userId, ids)
+			require.NoError(t, err)
+			require.Len(t, statuses, 1)
+			require.Equal(t, c.expectedStatus, statuses[0].Status)
+		})
+	}
+}
+
+func TestMessenger_GetReceipts(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockMessengerRepo := mocks2.NewRepository(t)
+	mockAccess := mocks2.NewAccessService(t)
+
+	service := &Service{
+		log:        slog.New(logHandler),
+		repository: mockMessengerRepo,
+		access:     mockAccess,
+	}
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	receipts := []models.Receipt{{PersonId: uuid.New(), DeliveredAt: time.Now().UTC()}}
+
+	cases := []struct {
+		name             string
+		senderId         uuid.UUID
+		expectedReceipts []models.Receipt
+		expectedError    error
+	}{
+		{
+			name:             "Отправитель получает квитанции",
+			senderId:         userId,
+			expectedReceipts: receipts,
+		},
+		{
+			name:          "Квитанции чужого сообщения",
+			senderId:      uuid.New(),
+			expectedError: access.ErrForbidden,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msgId := uuid.New()
+			mockMessengerRepo.On("GetById", msgId).Return(models.Message{
+				Id:       msgId,
+				PersonId: c.senderId,
+				Chat:     models.Chat{Id: chatId},
+			}, nil).Once()
+			mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+			if c.expectedError == nil {
+				mockMessengerRepo.On("GetReceipts", msgId).Return(receipts, nil).Once()
+			}
+
+			got, err := service.GetReceipts(userId, msgId)
+			require.Equal(t, c.expectedReceipts, got)
+			require.ErrorIs(t, err, c.expectedError)
+		})
+	}
+}
+
+func TestMessenger_AddReaction(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	chatId := uuid.New()
+	msgId := uuid.New()
+	reactions := models.Reactions{{Emoji: "👍", Count: 2}}
+
+	cases := []struct {
+		name            string
+		emoji           string
+		status          string
+		mockChanged     bool
+		expectedChanged bool
+		expectedError   error
+	}{
+		{
+			name:            "Новая реакция",
+			emoji:           "👍",
+			mockChanged:     true,
+			expectedChanged: true,
+		},
+		{
+			name:  "Повторная реакция",
+			emoji: "👍",
+		},
+		{
+			name:          "Текст вместо эмодзи",
+			emoji:         "ok",
+			expectedError: ErrInvalidReaction,
+		},
+		{
+			name:          "Пустая реакция",
+			expectedError: ErrInvalidReaction,
+		},
+		{
+			name:          "Сообщение удалено",
+			emoji:         "👍",
+			status:        models.MessageStatusDeleted,
+			expectedError: ErrDeleted,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			mockAccess := mocks2.NewAccessService(t)
+
+			service := &Service{
+				log:        slog.New(logHandler),
+				repository: mockMessengerRepo,
+				access:     mockAccess,
+			}
+
+			if !errors.Is(c.expectedError, ErrInvalidReaction) {
+				mockMessengerRepo.On("GetById", msgId).Return(models.Message{
+					Id:     msgId,
+					Chat:   models.Chat{Id: chatId},
+					Status: c.status,
+				}, nil).Once()
+				mockAccess.On("CheckMember", chatId, userId).Return(nil).Once()
+			}
+			if c.expectedError == nil {
+				mockMessengerRepo.On("AddReaction", msgId, userId, c.emoji).Return(c.mockChanged, reactions, nil).Once()
+			}
+
+			payload, changed, err := service.AddReaction(userId, domain.ReactionPayload{MessageId: msgId, Emoji: c.emoji})
+			require.ErrorIs(t, err, c.expectedError)
+			require.Equal(t, c.expectedChanged, changed)
+			if c.expectedError == nil {
+				require.Equal(t, domain.ReactionsPayload{
+					MessageId: msgId,
+					ChatId:    chatId,
+					PersonId:  userId,
+					Emoji:     c.emoji,
+					Added:     true,
+					Reactions: reactions,
+				}, payload)
+			}
+		})
+	}
+}
+
+func TestMessenger_Forward(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	userId := uuid.New()
+	sourceId := uuid.New()
+	targetId := uuid.New()
+	first := models.Message{Id: uuid.New(), Chat: models.Chat{Id: sourceId}, MessageText: "first"}
+	second := models.Message{Id: uuid.New(), Chat: models.Chat{Id: sourceId}, MessageText: "second"}
+	deleted := second
+	deleted.Status = models.MessageStatusDeleted
+	forwarded := []models.Message{
+		{Id: uuid.New(), Chat: models.Chat{Id: targetId}, MessageText: "first", ForwardedFrom: &first.PersonId},
+		{Id: uuid.New(), Chat: models.Chat{Id: targetId}, MessageText: "second", ForwardedFrom: &second.PersonId},
+	}
+
+	cases := []struct {
+		name            string
+		forward         domain.MessageForward
+		mockTargetError error
+		mockMessages    []models.Message
+		mockSourceError error
+		expectedForward bool
+		expectedError   error
+	}{
+		{
+			name: "Успешная пересылка в порядке отправки",
+			forward: domain.MessageForward{
+				MessageIds: []uuid.UUID{second.Id, first.Id, second.Id},
+				ChatIds:    []uuid.UUID{targetId},
+			},
+			mockMessages:    []models.Message{first, second},
+			expectedForward: true,
+		},
+		{
+			name:          "Пересылка без чатов",
+			forward:       domain.MessageForward{MessageIds: []uuid.UUID{first.Id}},
+			expectedError: ErrInvalidForward,
+		},
+		{
+			name:          "Пересылка без сообщений",
+			forward:       domain.MessageForward{ChatIds: []uuid.UUID{targetId}},
+			expectedError: ErrInvalidForward,
+		},
+		{
+			name: "Пересылка в чужой чат",
+			forward: domain.MessageForward{
+				MessageIds: []uuid.UUID{first.Id},
+				ChatIds:    []uuid.UUID{targetId},
+			},
+			mockTargetError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+		{
+			name: "Пересылка несуществующего сообщения",
+			forward: domain.MessageForward{
+				MessageIds: []uuid.UUID{first.Id, uuid.New()},
+				ChatIds:    []uuid.UUID{targetId},
+			},
+			mockMessages:  []models.Message{first},
+			expectedError: ErrInvalidForward,
+		},
+		{
+			name: "Пересылка из чата без доступа",
+			forward: domain.MessageForward{
+				MessageIds: []uuid.UUID{first.Id},
+				ChatIds:    []uuid.UUID{targetId},
+			},
+			mockMessages:    []models.Message{first},
+			mockSourceError: access.ErrForbidden,
+			expectedError:   access.ErrForbidden,
+		},
+		{
+			name: "Пересылка удалённого сообщения",
+			forward: domain.MessageForward{
+				MessageIds: []uuid.UUID{deleted.Id},
+				ChatIds:    []uuid.UUID{targetId},
+			},
+			mockMessages:  []models.Message{deleted},
+			expectedError: ErrDeleted,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockMessengerRepo := mocks2.NewRepository(t)
+			mockAccess := mocks2.NewAccessService(t)
+
+			service := &Service{
+				log:        slog.New(logHandler),
+				repository: mockMessengerRepo,
+				access:     mockAccess,
+			}
+
+			if len(c.forward.MessageIds) > 0 && len(c.forward.ChatIds) > 0 {
+				mockAccess.On("CheckMember", targetId, userId).Return(c.mockTargetError).Once()
+			}
+			if c.mockMessages != nil {
+				mockMessengerRepo.On("GetByIds", unique(c.forward.MessageIds)).Return(c.mockMessages, nil).Once()
+			}
+			if c.mockMessages != nil && len(c.mockMessages) == len(unique(c.forward.MessageIds)) &&
+				c.mockMessages[0].Status != models.MessageStatusDeleted {
+				mockAccess.On("CheckMember", sourceId, userId).Return(c.mockSourceError).Once()
+			}
+			if c.expectedForward {
+				mockMessengerRepo.On("Forward", userId, []uuid.UUID{first.Id, second.Id}, []uuid.UUID{targetId}).
+					Return(forwarded, nil).Once()
+			}
+
+			got, err := service.Forward(userId, c.forward)
+			require.ErrorIs(t, err, c.expectedError)
+			if c.expectedForward {
+				require.Equal(t, forwarded, got)
+			} else {
+				require.Nil(t, got)
+			}
+		})
+	}
+}
+
+func TestValidEmoji(t *testing.T) {
+	cases := []struct {
+		name     string
+		emoji    string
+		expected bool
+	}{
+		{name: "Простой эмодзи", emoji: "🔥", expected: true},
+		{name: "Эмодзи с селектором", emoji: "❤️", expected: true},
+		{name: "Флаг", emoji: "🇷🇺", expected: true},
+		{name: "Последовательность с ZWJ", emoji: "👩‍💻", expected: true},
+		{name: "Кейкап", emoji: "1️⃣", expected: true},
+		{name: "Цифра", emoji: "1", expected: false},
+		{name: "Текст", emoji: "lol", expected: false},
+		{name: "Эмодзи с пробелом", emoji: "🔥 🔥", expected: false},
+		{name: "Слишком длинная строка", emoji: strings.Repeat("🔥", maxEmojiLength+1), expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, validEmoji(c.emoji))
 		})
 	}
 }
@@ -3,10 +3,13 @@
 package mocks
 
 import (
-	models "messenger/internal/domain/models"
+	domain "messenger/internal/domain"
+	cursor "messenger/pkg/cursor"
 
 	mock "github.com/stretchr/testify/mock"
 
+	models "messenger/internal/domain/models"
+
 	uuid "github.com/google/uuid"
 )
 
@@ -43,47 +46,150 @@ func (_m *Repository) Add(_a0 models.Message) (models.Message, error) {
 	return r0, r1
 }
 
+// AddReaction provides a mock function with given fields: id, userId, emoji
+func (_m *Repository) AddReaction(id uuid.UUID, userId uuid.UUID, emoji string) (bool, models.Reactions, error) {
+	ret := _m.Called(id, userId, emoji)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddReaction")
+	}
+
+	var r0 bool
+	var r1 models.Reactions
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, string) (bool, models.Reactions, error)); ok {
+		return rf(id, userId, emoji)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, string) bool); ok {
+		r0 = rf(id, userId, emoji)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, string) models.Reactions); ok {
+		r1 = rf(id, userId, emoji)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(models.Reactions)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, string) error); ok {
+		r2 = rf(id, userId, emoji)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// AddToThread provides a mock function with given fields: _a0
+func (_m *Repository) AddToThread(_a0 models.Message) (domain.ThreadReply, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddToThread")
+	}
+
+	var r0 domain.ThreadReply
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.Message) (domain.ThreadReply, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(models.Message) domain.ThreadReply); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(domain.ThreadReply)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.Message) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: id
-func (_m *Repository) Delete(id uuid.UUID) error {
+func (_m *Repository) Delete(id uuid.UUID) (domain.DeletedMessage, error) {
 	ret := _m.Called(id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) error); ok {
+	var r0 domain.DeletedMessage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) (domain.DeletedMessage, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) domain.DeletedMessage); ok {
 		r0 = rf(id)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(domain.DeletedMessage)
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByChat provides a mock function with given fields: chatId
-func (_m *Repository) GetByChat(chatId uuid.UUID) ([]models.Message, error) {
-	ret := _m.Called(chatId)
+// Edit provides a mock function with given fields: id, text
+func (_m *Repository) Edit(id uuid.UUID, text string) (models.Message, error) {
+	ret := _m.Called(id, text)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByChat")
+		panic("no return value specified for Edit")
+	}
+
+	var r0 models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string) (models.Message, error)); ok {
+		return rf(id, text)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, string) models.Message); ok {
+		r0 = rf(id, text)
+	} else {
+		r0 = ret.Get(0).(models.Message)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, string) error); ok {
+		r1 = rf(id, text)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Forward provides a mock function with given fields: userId, ids, chatIds
+func (_m *Repository) Forward(userId uuid.UUID, ids []uuid.UUID, chatIds []uuid.UUID) ([]models.Message, error) {
+	ret := _m.Called(userId, ids, chatIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Forward")
 	}
 
 	var r0 []models.Message
 	var r1 error
-	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.Message, error)); ok {
-		return rf(chatId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID, []uuid.UUID) ([]models.Message, error)); ok {
+		return rf(userId, ids, chatIds)
 	}
-	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.Message); ok {
-		r0 = rf(chatId)
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID, []uuid.UUID) []models.Message); ok {
+		r0 = rf(userId, ids, chatIds)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.Message)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
-		r1 = rf(chatId)
+	if rf, ok := ret.Get(1).(func(uuid.UUID, []uuid.UUID, []uuid.UUID) error); ok {
+		r1 = rf(userId, ids, chatIds)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -119,6 +225,368 @@ func (_m *Repository) GetById(id uuid.UUID) (models.Message, error) {
 	return r0, r1
 }
 
+// GetByIds provides a mock function with given fields: ids
+func (_m *Repository) GetByIds(ids []uuid.UUID) ([]models.Message, error) {
+	ret := _m.Called(ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByIds")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) ([]models.Message, error)); ok {
+		return rf(ids)
+	}
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) []models.Message); ok {
+		r0 = rf(ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]uuid.UUID) error); ok {
+		r1 = rf(ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEdits provides a mock function with given fields: id
+func (_m *Repository) GetEdits(id uuid.UUID) ([]models.MessageEdit, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetEdits")
+	}
+
+	var r0 []models.MessageEdit
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.MessageEdit, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.MessageEdit); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.MessageEdit)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPage provides a mock function with given fields: userId, chatId, c, after, limit
+func (_m *Repository) GetPage(userId uuid.UUID, chatId uuid.UUID, c *cursor.Cursor, after bool, limit uint) ([]models.Message, error) {
+	ret := _m.Called(userId, chatId, c, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPage")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) ([]models.Message, error)); ok {
+		return rf(userId, chatId, c, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) []models.Message); ok {
+		r0 = rf(userId, chatId, c, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) error); ok {
+		r1 = rf(userId, chatId, c, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetQuotes provides a mock function with given fields: ids
+func (_m *Repository) GetQuotes(ids []uuid.UUID) ([]models.Quote, error) {
+	ret := _m.Called(ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetQuotes")
+	}
+
+	var r0 []models.Quote
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) ([]models.Quote, error)); ok {
+		return rf(ids)
+	}
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) []models.Quote); ok {
+		r0 = rf(ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Quote)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]uuid.UUID) error); ok {
+		r1 = rf(ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetReceipts provides a mock function with given fields: id
+func (_m *Repository) GetReceipts(id uuid.UUID) ([]models.Receipt, error) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetReceipts")
+	}
+
+	var r0 []models.Receipt
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID) ([]models.Receipt, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID) []models.Receipt); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Receipt)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSince provides a mock function with given fields: userId, c, limit
+func (_m *Repository) GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error) {
+	ret := _m.Called(userId, c, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSince")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, cursor.Cursor, uint) ([]models.Message, error)); ok {
+		return rf(userId, c, limit)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, cursor.Cursor, uint) []models.Message); ok {
+		r0 = rf(userId, c, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, cursor.Cursor, uint) error); ok {
+		r1 = rf(userId, c, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStatuses provides a mock function with given fields: ids
+func (_m *Repository) GetStatuses(ids []uuid.UUID) ([]domain.MessageStatus, error) {
+	ret := _m.Called(ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStatuses")
+	}
+
+	var r0 []domain.MessageStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) ([]domain.MessageStatus, error)); ok {
+		return rf(ids)
+	}
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) []domain.MessageStatus); ok {
+		r0 = rf(ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.MessageStatus)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]uuid.UUID) error); ok {
+		r1 = rf(ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetThreadPage provides a mock function with given fields: userId, rootId, c, after, limit
+func (_m *Repository) GetThreadPage(userId uuid.UUID, rootId uuid.UUID, c *cursor.Cursor, after bool, limit uint) ([]models.Message, error) {
+	ret := _m.Called(userId, rootId, c, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetThreadPage")
+	}
+
+	var r0 []models.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) ([]models.Message, error)); ok {
+		return rf(userId, rootId, c, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) []models.Message); ok {
+		r0 = rf(userId, rootId, c, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, *cursor.Cursor, bool, uint) error); ok {
+		r1 = rf(userId, rootId, c, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Hide provides a mock function with given fields: id, userId
+func (_m *Repository) Hide(id uuid.UUID, userId uuid.UUID) error {
+	ret := _m.Called(id, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Hide")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(id, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkDelivered provides a mock function with given fields: userId, ids
+func (_m *Repository) MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error) {
+	ret := _m.Called(userId, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkDelivered")
+	}
+
+	var r0 []uuid.UUID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID) ([]uuid.UUID, error)); ok {
+		return rf(userId, ids)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, []uuid.UUID) []uuid.UUID); ok {
+		r0 = rf(userId, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, []uuid.UUID) error); ok {
+		r1 = rf(userId, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkRead provides a mock function with given fields: chatId, userId, c
+func (_m *Repository) MarkRead(chatId uuid.UUID, userId uuid.UUID, c cursor.Cursor) (bool, []uuid.UUID, error) {
+	ret := _m.Called(chatId, userId, c)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkRead")
+	}
+
+	var r0 bool
+	var r1 []uuid.UUID
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, cursor.Cursor) (bool, []uuid.UUID, error)); ok {
+		return rf(chatId, userId, c)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, cursor.Cursor) bool); ok {
+		r0 = rf(chatId, userId, c)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, cursor.Cursor) []uuid.UUID); ok {
+		r1 = rf(chatId, userId, c)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]uuid.UUID)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, cursor.Cursor) error); ok {
+		r2 = rf(chatId, userId, c)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RemoveReaction provides a mock function with given fields: id, userId, emoji
+func (_m *Repository) RemoveReaction(id uuid.UUID, userId uuid.UUID, emoji string) (bool, models.Reactions, error) {
+	ret := _m.Called(id, userId, emoji)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveReaction")
+	}
+
+	var r0 bool
+	var r1 models.Reactions
+	var r2 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, string) (bool, models.Reactions, error)); ok {
+		return rf(id, userId, emoji)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, string) bool); ok {
+		r0 = rf(id, userId, emoji)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, string) models.Reactions); ok {
+		r1 = rf(id, userId, emoji)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(models.Reactions)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(uuid.UUID, uuid.UUID, string) error); ok {
+		r2 = rf(id, userId, emoji)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // Update provides a mock function with given fields: _a0
 func (_m *Repository) Update(_a0 models.Message) error {
 	ret := _m.Called(_a0)
@@ -0,0 +1,118 @@
+// Code generated by mockery v2.52.2. DO NOT EDIT.
+
+package mocks
+
+import (
+	domain "messenger/internal/domain"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Repository is an autogenerated mock type for the Repository type
+type Repository struct {
+	mock.Mock
+}
+
+// Get provides a mock function with given fields: userIds
+func (_m *Repository) Get(userIds []uuid.UUID) ([]domain.Presence, error) {
+	ret := _m.Called(userIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 []domain.Presence
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) ([]domain.Presence, error)); ok {
+		return rf(userIds)
+	}
+	if rf, ok := ret.Get(0).(func([]uuid.UUID) []domain.Presence); ok {
+		r0 = rf(userIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Presence)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]uuid.UUID) error); ok {
+		r1 = rf(userIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetOffline provides a mock function with given fields: userId, sessionId
+func (_m *Repository) SetOffline(userId uuid.UUID, sessionId uuid.UUID) (bool, error) {
+	ret := _m.Called(userId, sessionId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetOffline")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) (bool, error)); ok {
+		return rf(userId, sessionId)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID) bool); ok {
+		r0 = rf(userId, sessionId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(userId, sessionId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetOnline provides a mock function with given fields: userId, sessionId, ttl
+func (_m *Repository) SetOnline(userId uuid.UUID, sessionId uuid.UUID, ttl time.Duration) (bool, error) {
+	ret := _m.Called(userId, sessionId, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetOnline")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, time.Duration) (bool, error)); ok {
+		return rf(userId, sessionId, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(uuid.UUID, uuid.UUID, time.Duration) bool); ok {
+		r0 = rf(userId, sessionId, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(uuid.UUID, uuid.UUID, time.Duration) error); ok {
+		r1 = rf(userId, sessionId, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Repository {
+	mock := &Repository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,107 @@
+package presence
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"messenger/internal/domain"
+	"time"
+)
+
+//go:generate mockery --name=Repository --output=./mocks --case=underscore
+type Repository interface {
+	// SetOnline keeps the session alive for ttl, updates the last seen time
+	// and reports whether the user had no live session before.
+	SetOnline(userId, sessionId uuid.UUID, ttl time.Duration) (bool, error)
+	// SetOffline drops the session, updates the last seen time and reports
+	// whether the user has no live session left.
+	SetOffline(userId, sessionId uuid.UUID) (bool, error)
+	Get(userIds []uuid.UUID) ([]domain.Presence, error)
+}
+
+//go:generate mockery --name=ContactRepository --output=./mocks --case=underscore
+type ContactRepository interface {
+	// GetContacts returns the users who share at least one chat with the user.
+	GetContacts(userId uuid.UUID) ([]uuid.UUID, error)
+}
+
+// Service tracks which users are online. Every session refreshes its
+// heartbeat before ttl runs out; a session that stops doing so, for example
+// because its instance crashed, turns offline by itself.
+type Service struct {
+	log        *slog.Logger
+	repository Repository
+	contacts   ContactRepository
+	ttl        time.Duration
+}
+
+func NewPresenceService(log *slog.Logger, repository Repository, contacts ContactRepository,
+	ttl time.Duration) *Service {
+	return &Service{
+		log:        log,
+		repository: repository,
+		contacts:   contacts,
+		ttl:        ttl,
+	}
+}
+
+// Heartbeat marks the session as alive and reports whether the user has
+// just come online.
+func (s *Service) Heartbeat(userId, sessionId uuid.UUID) (bool, error) {
+	const op = "services.presence.Heartbeat"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	cameOnline, err := s.repository.SetOnline(userId, sessionId, s.ttl)
+	if err != nil {
+		log.Error("error with setting user online", slog.String("err", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return cameOnline, nil
+}
+
+// Disconnect ends the session and reports whether the user has gone offline.
+func (s *Service) Disconnect(userId, sessionId uuid.UUID) (bool, error) {
+	const op = "services.presence.Disconnect"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	wentOffline, err := s.repository.SetOffline(userId, sessionId)
+	if err != nil {
+		log.Error("error with setting user offline", slog.String("err", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return wentOffline, nil
+}
+
+// Get returns the presence of every user in the order of userIds.
+func (s *Service) Get(userIds []uuid.UUID) ([]domain.Presence, error) {
+	const op = "services.presence.Get"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	presences, err := s.repository.Get(userIds)
+	if err != nil {
+		log.Error("error with getting presence", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return presences, nil
+}
+
+// GetContacts returns the users who should be told about the user's presence.
+func (s *Service) GetContacts(userId uuid.UUID) ([]uuid.UUID, error) {
+	const op = "services.presence.GetContacts"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	contacts, err := s.contacts.GetContacts(userId)
+	if err != nil {
+		log.Error("error with getting contacts", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return contacts, nil
+}
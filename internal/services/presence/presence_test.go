@@ -0,0 +1,115 @@
+package presence
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"messenger/internal/domain"
+	"messenger/internal/services/presence/mocks"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestService_Heartbeat(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	const ttl = time.Minute
+	mockRepository := mocks.NewRepository(t)
+	service := NewPresenceService(slog.New(logHandler), mockRepository, mocks.NewContactRepository(t), ttl)
+
+	repoErr := errors.New("connection refused")
+
+	cases := []struct {
+		name             string
+		mockReturnOnline bool
+		mockReturnError  error
+		expectedOnline   bool
+		expectedError    error
+	}{
+		{
+			name:             "Первая сессия пользователя",
+			mockReturnOnline: true,
+			expectedOnline:   true,
+		},
+		{
+			name:           "Пользователь уже в сети",
+			expectedOnline: false,
+		},
+		{
+			name:            "Ошибка репозитория",
+			mockReturnError: repoErr,
+			expectedError:   repoErr,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			userId, sessionId := uuid.New(), uuid.New()
+			mockRepository.On("SetOnline", userId, sessionId, ttl).
+				Return(tt.mockReturnOnline, tt.mockReturnError).Once()
+
+			cameOnline, err := service.Heartbeat(userId, sessionId)
+			require.ErrorIs(t, err, tt.expectedError)
+			require.Equal(t, tt.expectedOnline, cameOnline)
+		})
+	}
+}
+
+func TestService_Disconnect(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewPresenceService(slog.New(logHandler), mockRepository, mocks.NewContactRepository(t), time.Minute)
+
+	cases := []struct {
+		name              string
+		mockReturnOffline bool
+	}{
+		{
+			name:              "Закрыта последняя сессия",
+			mockReturnOffline: true,
+		},
+		{
+			name: "Осталась другая сессия",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			userId, sessionId := uuid.New(), uuid.New()
+			mockRepository.On("SetOffline", userId, sessionId).Return(tt.mockReturnOffline, nil).Once()
+
+			wentOffline, err := service.Disconnect(userId, sessionId)
+			require.NoError(t, err)
+			require.Equal(t, tt.mockReturnOffline, wentOffline)
+		})
+	}
+}
+
+func TestService_Get(t *testing.T) {
+	logHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})
+
+	mockRepository := mocks.NewRepository(t)
+	service := NewPresenceService(slog.New(logHandler), mockRepository, mocks.NewContactRepository(t), time.Minute)
+
+	lastSeen := time.Now().UTC()
+	presences := []domain.Presence{
+		{UserId: uuid.New(), Online: true, LastSeen: &lastSeen},
+		{UserId: uuid.New()},
+	}
+	userIds := []uuid.UUID{presences[0].UserId, presences[1].UserId}
+
+	mockRepository.On("Get", userIds).Return(presences, nil).Once()
+
+	got, err := service.Get(userIds)
+	require.NoError(t, err)
+	require.Equal(t, presences, got)
+}
@@ -0,0 +1,30 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned when no blob is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs under keys made of slash separated segments.
+type Store interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the blob; deleting a missing blob is not an error.
+	Delete(key string) error
+}
+
+// New returns the store of the configured backend.
+func New(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocalStore(cfg.Local.Dir)
+	case BackendS3:
+		return NewS3Store(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,29 @@
+package blob
+
+// Backends that can hold attachment contents.
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+type Config struct {
+	Backend string      `yaml:"backend" env-default:"local"`
+	Local   LocalConfig `yaml:"local"`
+	S3      S3Config    `yaml:"s3"`
+}
+
+type LocalConfig struct {
+	Dir string `yaml:"dir" env-default:"./data/blobs"`
+}
+
+// S3Config points at an S3-compatible service. Path style addressing puts
+// the bucket in the path rather than the host name, which is what most
+// self-hosted services expect.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region" env-default:"us-east-1"`
+	Bucket    string `yaml:"bucket"`
+	PathStyle bool   `yaml:"path_style" env-default:"true"`
+	AccessKey string `yaml:"-"`
+	SecretKey string `yaml:"-"`
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+// acquireBlob adds a reference to the blob holding the content with the
+// checksum and returns its key. The blob at key, just stored, is recorded if
+// no blob has the same content.
+func acquireBlob(tx *sqlx.Tx, key, checksum string, size int64) (string, error) {
+	query := `INSERT INTO blobs (storage_key, checksum, size, ref_count) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (checksum) WHERE ref_count > 0 DO UPDATE SET ref_count = blobs.ref_count + 1
+		RETURNING storage_key`
+
+	var shared string
+	err := tx.Get(&shared, query, key, checksum, size)
+	return shared, err
+}
+
+// GetUnreferencedBlobs returns the keys of up to limit blobs that no file
+// refers to any longer.
+func (f *FileRepository) GetUnreferencedBlobs(limit uint) ([]string, error) {
+	const op = "postgres.FileRepository.GetUnreferencedBlobs"
+	query := `SELECT storage_key FROM blobs WHERE ref_count = 0 LIMIT $1`
+
+	var keys []string
+	err := f.db.Select(&keys, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+// DeleteBlob forgets an unreferenced blob once it is removed from the blob
+// store.
+func (f *FileRepository) DeleteBlob(key string) error {
+	const op = "postgres.FileRepository.DeleteBlob"
+	query := `DELETE FROM blobs WHERE storage_key = $1 AND ref_count = 0`
+
+	_, err := f.db.Exec(query, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
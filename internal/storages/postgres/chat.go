@@ -1,12 +1,14 @@
 package postgres
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"time"
 )
 
 var ErrNotFound = errors.New("not found")
@@ -21,7 +23,7 @@ func NewChatRepository(db *sqlx.DB) *ChatRepository {
 	}
 }
 
-func (c *ChatRepository) Add(chat models.Chat, personIds []uuid.UUID) (uuid.UUID, error) {
+func (c *ChatRepository) Add(chat models.Chat, ownerId uuid.UUID, personIds []uuid.UUID) (uuid.UUID, error) {
 	const op = "postgres.ChatRepository.Add"
 
 	tx, err := c.db.Beginx()
@@ -43,9 +45,14 @@ func (c *ChatRepository) Add(chat models.Chat, personIds []uuid.UUID) (uuid.UUID
 		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	query = `INSERT INTO chats_persons (chat_id, person_id) VALUES ($1, $2)`
+	query = `INSERT INTO chats_persons (chat_id, person_id, role) VALUES ($1, $2, $3)`
 	for _, personId := range personIds {
-		_, err = tx.Exec(query, chat.Id, personId)
+		role := models.RoleMember
+		if personId == ownerId {
+			role = models.RoleOwner
+		}
+
+		_, err = tx.Exec(query, chat.Id, personId, role)
 		if err != nil {
 			return uuid.Nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -119,6 +126,117 @@ func (c *ChatRepository) GetUserChats(userId uuid.UUID) ([]uuid.UUID, error) {
 	return chats, nil
 }
 
+// GetContacts returns the users who share at least one chat with the user.
+func (c *ChatRepository) GetContacts(userId uuid.UUID) ([]uuid.UUID, error) {
+	const op = `postgres.ChatRepository.GetContacts`
+	query := `SELECT DISTINCT other.person_id FROM chats_persons own
+		JOIN chats_persons other ON other.chat_id = own.chat_id
+		WHERE own.person_id = $1 AND other.person_id <> $1`
+
+	var contacts []uuid.UUID
+	err := c.db.Select(&contacts, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return contacts, nil
+}
+
+func (c *ChatRepository) GetUsers(chatId uuid.UUID) ([]uuid.UUID, error) {
+	const op = `postgres.ChatRepository.GetUsers`
+	query := `SELECT person_id FROM chats_persons WHERE chat_id = $1`
+
+	var users []uuid.UUID
+	err := c.db.Select(&users, query, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return users, nil
+}
+
+func (c *ChatRepository) GetMembers(chatId uuid.UUID) ([]models.ChatMember, error) {
+	const op = `postgres.ChatRepository.GetMembers`
+	query := `SELECT person_id, role, joined_at FROM chats_persons WHERE chat_id = $1 ORDER BY joined_at`
+
+	var members []models.ChatMember
+	err := c.db.Select(&members, query, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (c *ChatRepository) GetRole(chatId, userId uuid.UUID) (models.Role, error) {
+	const op = `postgres.ChatRepository.GetRole`
+	query := `SELECT role FROM chats_persons WHERE chat_id = $1 AND person_id = $2`
+
+	var role models.Role
+	err := c.db.QueryRow(query, chatId, userId).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (c *ChatRepository) SetRole(chatId, userId uuid.UUID, role models.Role) error {
+	const op = `postgres.ChatRepository.SetRole`
+	query := `UPDATE chats_persons SET role = $1 WHERE chat_id = $2 AND person_id = $3`
+
+	res, err := c.db.Exec(query, role, chatId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	return nil
+}
+
+// TransferOwnership makes toId the owner of the chat and demotes fromId to admin.
+func (c *ChatRepository) TransferOwnership(chatId, fromId, toId uuid.UUID) error {
+	const op = `postgres.ChatRepository.TransferOwnership`
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	query := `UPDATE chats_persons SET role = $1 WHERE chat_id = $2 AND person_id = $3`
+	_, err = tx.Exec(query, models.RoleAdmin, chatId, fromId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.Exec(query, models.RoleOwner, chatId, toId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		err = ErrNotFound
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
 func (c *ChatRepository) GetChatIds(userId uuid.UUID, offset, limit uint) ([]uuid.UUID, error) {
 	const op = `postgres.ChatRepository.GetChatIds`
 	tx, err := c.db.Beginx()
@@ -144,7 +262,7 @@ func (c *ChatRepository) GetChatIds(userId uuid.UUID, offset, limit uint) ([]uui
 	return chats, nil
 }
 
-func (c *ChatRepository) GetInfoChat(chatId uuid.UUID) (domain.GetChat, error) {
+func (c *ChatRepository) GetInfoChat(chatId, userId uuid.UUID) (domain.GetChat, error) {
 	const op = `postgres.ChatRepository.GetInfoUserChats`
 	tx, err := c.db.Beginx()
 	if err != nil {
@@ -160,22 +278,140 @@ func (c *ChatRepository) GetInfoChat(chatId uuid.UUID) (domain.GetChat, error) {
 	}()
 
 	var chat domain.GetChat
-	query := `SELECT name FROM chats WHERE id = $1`
-	err = tx.QueryRow(query, chatId).Scan(&chat.Name)
+	query := `SELECT id, name FROM chats WHERE id = $1`
+	err = tx.QueryRow(query, chatId).Scan(&chat.Id, &chat.Name)
 	if err != nil {
 		return domain.GetChat{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	msg, err := c.getLastMessage(chatId)
+	msg, err := c.getLastMessage(tx, chatId, userId)
 	if err != nil {
 		return domain.GetChat{}, fmt.Errorf("%s: %w", op, err)
 	}
 	chat.LastMessage = msg
+
+	count, err := c.getUnreadCount(tx, chatId, userId)
+	if err != nil {
+		return domain.GetChat{}, fmt.Errorf("%s: %w", op, err)
+	}
+	chat.UnreadCount = count
+
+	pin, err := c.getLatestPin(tx, chatId)
+	if err != nil {
+		return domain.GetChat{}, fmt.Errorf("%s: %w", op, err)
+	}
+	chat.LatestPin = pin
 	return chat, nil
 }
 
-func (c *ChatRepository) getLastMessage(chatId uuid.UUID) (models.Message, error) {
-	panic("implement me")
+func (c *ChatRepository) getLastMessage(tx *sqlx.Tx, chatId, userId uuid.UUID) (models.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE chat_id = $1 AND thread_root_id IS NULL AND NOT EXISTS (
+		SELECT 1 FROM message_hides WHERE message_id = messages.id AND person_id = $2)
+		ORDER BY sending_time DESC, id DESC LIMIT 1`
+
+	var msg models.Message
+	err := tx.Get(&msg, query, chatId, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Message{}, nil
+	}
+	return msg, err
+}
+
+// getUnreadCount counts messages from other members after the user's read
+// pointer. Until the user reads anything, messages sent before they joined
+// count as read.
+func (c *ChatRepository) getUnreadCount(tx *sqlx.Tx, chatId, userId uuid.UUID) (uint, error) {
+	query := `SELECT count(*) FROM messages m
+		JOIN chats_persons cp ON cp.chat_id = m.chat_id AND cp.person_id = $2
+		WHERE m.chat_id = $1 AND m.thread_root_id IS NULL AND m.person_id <> $2 AND m.status <> $3
+		AND (m.sending_time, m.id) > (COALESCE(cp.last_read_time, cp.joined_at), COALESCE(cp.last_read_id, $4))
+		AND NOT EXISTS (SELECT 1 FROM message_hides WHERE message_id = m.id AND person_id = $2)`
+
+	var count uint
+	err := tx.Get(&count, query, chatId, userId, models.MessageStatusDeleted, uuid.Nil)
+	return count, err
+}
+
+// pinsQuery selects the pinned messages of the chat along with who pinned
+// them and when.
+const pinsQuery = `WITH pins AS (SELECT message_id AS pin_id, pinned_by, pinned_at FROM pinned_messages WHERE chat_id = $1)
+	SELECT ` + messageColumns + `, pinned_by, pinned_at FROM messages JOIN pins ON pins.pin_id = messages.id`
+
+func (c *ChatRepository) getLatestPin(tx *sqlx.Tx, chatId uuid.UUID) (*models.Pin, error) {
+	query := pinsQuery + ` ORDER BY pinned_at DESC, id DESC LIMIT 1`
+
+	var pin models.Pin
+	err := tx.Get(&pin, query, chatId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// IsPinnable reports whether the message belongs to the main timeline of the
+// chat and has not been deleted.
+func (c *ChatRepository) IsPinnable(chatId, messageId uuid.UUID) (bool, error) {
+	const op = "postgres.ChatRepository.IsPinnable"
+	query := `SELECT EXISTS (SELECT 1 FROM messages
+		WHERE id = $1 AND chat_id = $2 AND thread_root_id IS NULL AND status <> $3)`
+
+	var pinnable bool
+	err := c.db.Get(&pinnable, query, messageId, chatId, models.MessageStatusDeleted)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return pinnable, nil
+}
+
+// Pin pins the message in the chat and reports whether it was not pinned yet.
+func (c *ChatRepository) Pin(chatId, messageId, userId uuid.UUID) (bool, error) {
+	const op = "postgres.ChatRepository.Pin"
+	query := `INSERT INTO pinned_messages (chat_id, message_id, pinned_by, pinned_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id, message_id) DO NOTHING`
+
+	res, err := c.db.Exec(query, chatId, messageId, userId, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return rows > 0, nil
+}
+
+// Unpin unpins the message and reports whether it was pinned.
+func (c *ChatRepository) Unpin(chatId, messageId uuid.UUID) (bool, error) {
+	const op = "postgres.ChatRepository.Unpin"
+	query := `DELETE FROM pinned_messages WHERE chat_id = $1 AND message_id = $2`
+
+	res, err := c.db.Exec(query, chatId, messageId)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return rows > 0, nil
+}
+
+// GetPins returns the pinned messages of the chat, earliest pinned first.
+func (c *ChatRepository) GetPins(chatId uuid.UUID) ([]models.Pin, error) {
+	const op = "postgres.ChatRepository.GetPins"
+	query := pinsQuery + ` ORDER BY pinned_at, id`
+
+	pins := make([]models.Pin, 0)
+	err := c.db.Select(&pins, query, chatId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return pins, nil
 }
 
 func (c *ChatRepository) Update(chat models.Chat) error {
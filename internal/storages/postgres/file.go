@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"messenger/internal/domain/models"
+	"time"
+)
+
+const fileColumns = `id, name, mime_type, size, checksum, storage_key, uploaded_by, created_at,
+	COALESCE(width, 0) AS width, COALESCE(height, 0) AS height, COALESCE(dominant_color, '') AS dominant_color,
+	(SELECT json_agg(json_build_object('size', size, 'width', width, 'height', height) ORDER BY size)
+		FROM file_thumbnails WHERE file_id = files.id) AS thumbnails`
+
+type FileRepository struct {
+	db *sqlx.DB
+}
+
+func NewFileRepository(db *sqlx.DB) *FileRepository {
+	return &FileRepository{
+		db: db,
+	}
+}
+
+// Add stores the metadata of a file whose content is already in the blob
+// store and returns the file as stored, which may share the content of an
+// earlier file.
+func (f *FileRepository) Add(file models.File) (models.File, error) {
+	const op = "postgres.FileRepository.Add"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	stored, err := insertFile(tx, file)
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return stored, nil
+}
+
+// insertFile stores the file and returns it as stored. If a blob with the
+// same content is stored already, the file refers to that blob instead of
+// its own and takes the preview of another file of the blob.
+func insertFile(tx *sqlx.Tx, file models.File) (models.File, error) {
+	key, err := acquireBlob(tx, file.StorageKey, file.Checksum, file.Size)
+	if err != nil {
+		return models.File{}, err
+	}
+
+	query := `INSERT INTO files (id, name, mime_type, size, checksum, storage_key, uploaded_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.Exec(query, file.Id, file.Name, file.MimeType, file.Size, file.Checksum, key,
+		file.UploadedBy, file.CreatedAt)
+	if err != nil {
+		return models.File{}, err
+	}
+
+	if key != file.StorageKey {
+		query = `WITH shared AS (
+				SELECT id, width, height, dominant_color FROM files
+				WHERE storage_key = $1 AND id <> $2 AND width IS NOT NULL LIMIT 1
+			), updated AS (
+				UPDATE files SET width = shared.width, height = shared.height, dominant_color = shared.dominant_color
+				FROM shared WHERE files.id = $2
+			)
+			INSERT INTO file_thumbnails (file_id, size, width, height)
+			SELECT $2, t.size, t.width, t.height FROM file_thumbnails t JOIN shared ON t.file_id = shared.id`
+		if _, err = tx.Exec(query, key, file.Id); err != nil {
+			return models.File{}, err
+		}
+	}
+
+	var stored models.File
+	query = `SELECT ` + fileColumns + ` FROM files WHERE id = $1`
+	if err = tx.Get(&stored, query, file.Id); err != nil {
+		return models.File{}, err
+	}
+	return stored, nil
+}
+
+// SetPreview records the dimensions, dominant color and thumbnails of an
+// image file.
+func (f *FileRepository) SetPreview(file models.File) error {
+	const op = "postgres.FileRepository.SetPreview"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	query := `UPDATE files SET width = $1, height = $2, dominant_color = NULLIF($3, '') WHERE id = $4`
+	_, err = tx.Exec(query, file.Width, file.Height, file.DominantColor, file.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `INSERT INTO file_thumbnails (file_id, size, width, height) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (file_id, size) DO UPDATE SET width = EXCLUDED.width, height = EXCLUDED.height`
+	for _, thumbnail := range file.Thumbnails {
+		_, err = tx.Exec(query, file.Id, thumbnail.Size, thumbnail.Width, thumbnail.Height)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// GetAccessible returns the file and true if the user uploaded it or is a
+// member of a chat with a message that carries it.
+func (f *FileRepository) GetAccessible(id, userId uuid.UUID) (models.File, bool, error) {
+	const op = "postgres.FileRepository.GetAccessible"
+	query := `SELECT ` + fileColumns + ` FROM files WHERE id = $1 AND (uploaded_by = $2 OR EXISTS (
+		SELECT 1 FROM message_files mf
+		JOIN messages m ON m.id = mf.message_id
+		JOIN chats_persons cp ON cp.chat_id = m.chat_id AND cp.person_id = $2
+		WHERE mf.file_id = files.id))`
+
+	var file models.File
+	err := f.db.Get(&file, query, id, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.File{}, false, nil
+	}
+	if err != nil {
+		return models.File{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return file, true, nil
+}
+
+// GetByIds returns the files; ids of files that do not exist are skipped.
+func (f *FileRepository) GetByIds(ids []uuid.UUID) ([]models.File, error) {
+	const op = "postgres.FileRepository.GetByIds"
+	query := `SELECT ` + fileColumns + ` FROM files WHERE id = ANY($1)`
+
+	var files []models.File
+	err := f.db.Select(&files, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return files, nil
+}
+
+// GetLegacyFiles returns the ids of up to limit files whose content is still
+// stored in the database.
+func (f *FileRepository) GetLegacyFiles(limit uint) ([]uuid.UUID, error) {
+	const op = "postgres.FileRepository.GetLegacyFiles"
+	query := `SELECT id FROM files WHERE storage_key = '' AND file IS NOT NULL LIMIT $1`
+
+	var ids []uuid.UUID
+	err := f.db.Select(&ids, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
+
+// GetLegacyContent returns the content of a file stored in the database
+// before contents moved to the blob store.
+func (f *FileRepository) GetLegacyContent(id uuid.UUID) ([]byte, error) {
+	const op = "postgres.FileRepository.GetLegacyContent"
+	query := `SELECT file FROM files WHERE id = $1 AND file IS NOT NULL`
+
+	var content []byte
+	err := f.db.Get(&content, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return content, nil
+}
+
+// SetStorageKey records that the content of the file moved to the blob at
+// key and drops the copy kept in the database. It returns the key of the blob
+// the file refers to, which is a blob stored before if one has the same
+// content, or the blob the file was moved to by another call.
+func (f *FileRepository) SetStorageKey(id uuid.UUID, key string) (string, error) {
+	const op = "postgres.FileRepository.SetStorageKey"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	var file models.File
+	query := `SELECT checksum, size, storage_key FROM files WHERE id = $1 FOR UPDATE`
+	if err = tx.Get(&file, query, id); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if file.StorageKey != "" {
+		return file.StorageKey, nil
+	}
+
+	if key, err = acquireBlob(tx, key, file.Checksum, file.Size); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `UPDATE files SET storage_key = $1, file = NULL WHERE id = $2`
+	_, err = tx.Exec(query, key, id)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
+
+// DeleteUnattachedFiles deletes up to limit files created before the time
+// that no message carries, which releases their blobs. The files are locked
+// before they are checked again, so that a message attaching one at the same
+// time either keeps it or fails to attach it.
+func (f *FileRepository) DeleteUnattachedFiles(before time.Time, limit uint) (int, error) {
+	const op = "postgres.FileRepository.DeleteUnattachedFiles"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	var ids []uuid.UUID
+	query := `SELECT id FROM files
+		WHERE created_at < $1 AND NOT EXISTS (SELECT 1 FROM message_files WHERE file_id = files.id)
+		LIMIT $2 FOR UPDATE SKIP LOCKED`
+	if err = tx.Select(&ids, query, before, limit); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query = `DELETE FROM files
+		WHERE id = ANY($1) AND NOT EXISTS (SELECT 1 FROM message_files WHERE file_id = files.id)`
+	res, err := tx.Exec(query, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return int(rows), nil
+}
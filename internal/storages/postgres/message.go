@@ -1,12 +1,43 @@
 package postgres
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"messenger/internal/domain"
 	"messenger/internal/domain/models"
+	"messenger/pkg/cursor"
+	"time"
 )
 
+const messageColumns = `id, message, person_id, chat_id AS "chat.id", sending_time AS time, status, edited_at, deleted_at, reply_to,
+	thread_root_id, reply_count, last_reply_at, forwarded_from, forwarded_from_chat, forwarded_time, ` +
+	reactionsColumn + `, ` + filesColumn + `, ` + previewsColumn
+
+// reactionsColumn aggregates the reactions of the message in the current
+// row, so every query returning messages returns their reactions too.
+const reactionsColumn = `(SELECT json_agg(json_build_object('emoji', emoji, 'count', count)
+		ORDER BY count DESC, emoji)
+	FROM (SELECT emoji, count(*) AS count FROM message_reactions
+		WHERE message_id = messages.id GROUP BY emoji) counts) AS reactions`
+
+// filesColumn lists the attachments of the message in the current row.
+const filesColumn = `(SELECT json_agg(file_id ORDER BY position, file_id)
+	FROM message_files WHERE message_id = messages.id) AS files`
+
+// previewsColumn describes the image attachments of the message in the
+// current row, so that they can be shown without loading the files.
+const previewsColumn = `(SELECT json_agg(json_build_object('file_id', f.id, 'width', f.width, 'height', f.height,
+		'dominant_color', f.dominant_color,
+		'thumbnails', (SELECT json_agg(json_build_object('size', t.size, 'width', t.width, 'height', t.height)
+			ORDER BY t.size) FROM file_thumbnails t WHERE t.file_id = f.id))
+		ORDER BY mf.position, mf.file_id)
+	FROM message_files mf JOIN files f ON f.id = mf.file_id
+	WHERE mf.message_id = messages.id AND f.width IS NOT NULL) AS previews`
+
 type MessageRepository struct {
 	db *sqlx.DB
 }
@@ -17,95 +48,209 @@ func NewMessageRepository(db *sqlx.DB) *MessageRepository {
 	}
 }
 
+// Add stores the message and links its files in one transaction, so that a
+// message is never seen without its attachments.
 func (m *MessageRepository) Add(message models.Message) (models.Message, error) {
 	const op = "MessengerRepo.Add"
-
 	tx, err := m.db.Beginx()
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback()
+			return
 		}
+		_ = tx.Commit()
 	}()
 
+	query := `INSERT INTO messages (id, message, person_id, chat_id, sending_time, reply_to)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + messageColumns
+
+	var msg models.Message
+	err = tx.Get(&msg, query, uuid.New(), message.MessageText, message.PersonId, message.Chat.Id,
+		time.Now().UTC(), message.ReplyTo)
 	if err != nil {
 		return models.Message{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	chatExists, err := m.checkExistsChat(tx, message.Chat.Id)
-	if err != nil {
+	if err = linkFiles(tx, msg.Id, message.Files); err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	msg.Files = message.Files
+	if msg.Previews, err = getPreviews(tx, msg.Id, msg.Files); err != nil {
 		return models.Message{}, fmt.Errorf("%s: %w", op, err)
 	}
+	return msg, nil
+}
 
-	if !chatExists {
-		err = m.createChat(tx, message.Chat)
-		if err != nil {
-			return models.Message{}, fmt.Errorf("%s: %w", op, err)
-		}
+// linkFiles attaches the files to the message in the given order.
+func linkFiles(tx *sqlx.Tx, messageId uuid.UUID, fileIds []uuid.UUID) error {
+	if len(fileIds) == 0 {
+		return nil
 	}
 
-	query := `INSERT INTO messages (id, message, person_id, chat_id, sending_time) VALUES ($1, $2, $3, $4, $5)`
-	_, err = m.db.Exec(query, message)
-	if err != nil {
-		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	query := `INSERT INTO message_files (message_id, file_id, position)
+		SELECT $1, f.id, f.position FROM unnest($2::uuid[]) WITH ORDINALITY AS f(id, position)`
+	_, err := tx.Exec(query, messageId, pq.Array(fileIds))
+	return err
+}
+
+// getPreviews reads the previews of a message once its files are linked.
+func getPreviews(tx *sqlx.Tx, messageId uuid.UUID, fileIds []uuid.UUID) (models.Previews, error) {
+	if len(fileIds) == 0 {
+		return nil, nil
 	}
 
-	msg, err := m.GetById(message.Id)
-	if err != nil {
-		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	query := `SELECT ` + previewsColumn + ` FROM messages WHERE id = $1`
+	var previews models.Previews
+	err := tx.Get(&previews, query, messageId)
+	return previews, err
+}
+
+func (m *MessageRepository) GetPage(userId, chatId uuid.UUID, c *cursor.Cursor, after bool,
+	limit uint) ([]models.Message, error) {
+	const op = `MessengerRepo.GetPage`
+
+	// The row comparison lets Postgres seek straight to the cursor through
+	// the (chat_id, sending_time, id) index instead of skipping rows.
+	const visible = ` FROM messages WHERE chat_id = $1 AND thread_root_id IS NULL AND NOT EXISTS (
+		SELECT 1 FROM message_hides WHERE message_id = messages.id AND person_id = $2)`
+
+	var query string
+	args := []any{chatId, userId, limit}
+	switch {
+	case c == nil:
+		query = `SELECT ` + messageColumns + visible + ` ORDER BY sending_time DESC, id DESC LIMIT $3`
+	case after:
+		query = `SELECT ` + messageColumns + visible + ` AND (sending_time, id) > ($4, $5)
+			ORDER BY sending_time, id LIMIT $3`
+		args = append(args, c.Time, c.Id)
+	default:
+		query = `SELECT ` + messageColumns + visible + ` AND (sending_time, id) < ($4, $5)
+			ORDER BY sending_time DESC, id DESC LIMIT $3`
+		args = append(args, c.Time, c.Id)
 	}
 
-	err = tx.Commit()
+	var messages []models.Message
+	err := m.db.Select(&messages, query, args...)
 	if err != nil {
-		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
-	return msg, nil
+	return messages, nil
 }
 
-func (m *MessageRepository) checkExistsChat(tx *sqlx.Tx, chatID uuid.UUID) (bool, error) {
-	const op = "MessengerRepo.checkExistsChat"
-	query := `SELECT EXISTS (SELECT 1 FROM chats WHERE id = $1)`
+// GetThreadPage pages through the messages of a thread the same way GetPage
+// pages through a chat.
+func (m *MessageRepository) GetThreadPage(userId, rootId uuid.UUID, c *cursor.Cursor, after bool,
+	limit uint) ([]models.Message, error) {
+	const op = `MessengerRepo.GetThreadPage`
 
-	var exists bool
-	if err := tx.QueryRow(query, chatID).Scan(&exists); err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
+	const visible = ` FROM messages WHERE thread_root_id = $1 AND NOT EXISTS (
+		SELECT 1 FROM message_hides WHERE message_id = messages.id AND person_id = $2)`
+
+	var query string
+	args := []any{rootId, userId, limit}
+	switch {
+	case c == nil:
+		query = `SELECT ` + messageColumns + visible + ` ORDER BY sending_time DESC, id DESC LIMIT $3`
+	case after:
+		query = `SELECT ` + messageColumns + visible + ` AND (sending_time, id) > ($4, $5)
+			ORDER BY sending_time, id LIMIT $3`
+		args = append(args, c.Time, c.Id)
+	default:
+		query = `SELECT ` + messageColumns + visible + ` AND (sending_time, id) < ($4, $5)
+			ORDER BY sending_time DESC, id DESC LIMIT $3`
+		args = append(args, c.Time, c.Id)
 	}
 
-	if !exists {
-		return false, nil
+	var messages []models.Message
+	err := m.db.Select(&messages, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return true, nil
+	return messages, nil
 }
 
-func (m *MessageRepository) createChat(tx *sqlx.Tx, chat models.Chat, personsId ...uuid.UUID) error {
-	const op = "MessengerRepo.CreateChat"
-
-	query := `INSERT INTO chats (id, name) VALUES ($1, $2)`
-	_, err := tx.Exec(query, chat.Id, chat.Name)
+// AddToThread posts the message in its thread: it bumps the counters of the
+// root, makes the sender and the author of the root participants, and
+// returns the participants who are still members of the chat.
+func (m *MessageRepository) AddToThread(message models.Message) (domain.ThreadReply, error) {
+	const op = `MessengerRepo.AddToThread`
+	tx, err := m.db.Beginx()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	query = `INSERT INTO chats_persons (chat_id, person_id) VALUES ($1, $2)`
-	for _, p := range personsId {
-		_, err = tx.Exec(query, p, chat.Id)
+	defer func() {
 		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+			_ = tx.Rollback()
+			return
 		}
+		_ = tx.Commit()
+	}()
+
+	now := time.Now().UTC()
+	var reply domain.ThreadReply
+	query := `INSERT INTO messages (id, message, person_id, chat_id, sending_time, reply_to, thread_root_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + messageColumns
+	err = tx.Get(&reply.Message, query, uuid.New(), message.MessageText, message.PersonId, message.Chat.Id,
+		now, message.ReplyTo, message.ThreadRootId)
+	if err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if err = linkFiles(tx, reply.Message.Id, message.Files); err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return nil
+	reply.Message.Files = message.Files
+	if reply.Message.Previews, err = getPreviews(tx, reply.Message.Id, message.Files); err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `UPDATE messages SET reply_count = reply_count + 1, last_reply_at = $1 WHERE id = $2
+		RETURNING id, chat_id, reply_count, last_reply_at`
+	err = tx.Get(&reply.Thread, query, now, message.ThreadRootId)
+	if err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `INSERT INTO thread_participants (root_id, person_id, joined_at)
+		SELECT id, person_id, $2 FROM messages WHERE id = $1
+		UNION SELECT $1, $3, $2
+		ON CONFLICT (root_id, person_id) DO NOTHING`
+	_, err = tx.Exec(query, message.ThreadRootId, now, message.PersonId)
+	if err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `SELECT tp.person_id FROM thread_participants tp
+		JOIN chats_persons cp ON cp.person_id = tp.person_id AND cp.chat_id = $2
+		WHERE tp.root_id = $1`
+	err = tx.Select(&reply.Participants, query, message.ThreadRootId, message.Chat.Id)
+	if err != nil {
+		return domain.ThreadReply{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return reply, nil
 }
 
-func (m *MessageRepository) GetByChat(chatId uuid.UUID) ([]models.Message, error) {
-	const op = `MessengerRepo.GetByChat`
-	query := `SELECT id, message, person_id, chat_id, sending_time FROM messages WHERE chat_id = $1 AND status <> $2`
+// GetSince returns up to limit messages posted after the cursor in any chat
+// the user is a member of, oldest first, skipping those the user hid. Thread
+// messages are included only for threads the user takes part in.
+func (m *MessageRepository) GetSince(userId uuid.UUID, c cursor.Cursor, limit uint) ([]models.Message, error) {
+	const op = `MessengerRepo.GetSince`
+	query := `SELECT ` + messageColumns + ` FROM messages
+		WHERE chat_id IN (SELECT chat_id FROM chats_persons WHERE person_id = $1)
+			AND (sending_time, id) > ($2, $3)
+			AND (thread_root_id IS NULL OR EXISTS (
+				SELECT 1 FROM thread_participants WHERE root_id = messages.thread_root_id AND person_id = $1))
+			AND NOT EXISTS (
+				SELECT 1 FROM message_hides WHERE message_id = messages.id AND person_id = $1)
+		ORDER BY sending_time, id LIMIT $4`
 
 	var messages []models.Message
-	err := m.db.Select(messages, query, chatId, "deleted")
+	err := m.db.Select(&messages, query, userId, c.Time, c.Id, limit)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -114,7 +259,7 @@ func (m *MessageRepository) GetByChat(chatId uuid.UUID) ([]models.Message, error
 
 func (m *MessageRepository) GetById(id uuid.UUID) (models.Message, error) {
 	const op = `MessengerRepo.GetById`
-	query := `SELECT id, message, person_id, chat_id, sending_time FROM messages WHERE id = $1`
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
 
 	var message models.Message
 	err := m.db.Get(&message, query, id)
@@ -124,22 +269,365 @@ func (m *MessageRepository) GetById(id uuid.UUID) (models.Message, error) {
 	return message, nil
 }
 
+// GetByIds returns the messages in the order they were sent; ids of messages
+// that do not exist are skipped.
+func (m *MessageRepository) GetByIds(ids []uuid.UUID) ([]models.Message, error) {
+	const op = `MessengerRepo.GetByIds`
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = ANY($1) ORDER BY sending_time, id`
+
+	var messages []models.Message
+	err := m.db.Select(&messages, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return messages, nil
+}
+
+// Forward copies the messages into every chat on behalf of the user, keeping
+// their order. A copy links the files of the original and records where the
+// original came from; forwarding a forward keeps the first origin.
+func (m *MessageRepository) Forward(userId uuid.UUID, ids, chatIds []uuid.UUID) ([]models.Message, error) {
+	const op = `MessengerRepo.Forward`
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	copyQuery := `INSERT INTO messages (id, message, person_id, chat_id, sending_time,
+			forwarded_from, forwarded_from_chat, forwarded_time)
+		SELECT $1, message, $2, $3, $4, COALESCE(forwarded_from, person_id),
+			COALESCE(forwarded_from_chat, chat_id), COALESCE(forwarded_time, sending_time)
+		FROM messages src WHERE src.id = $5`
+	linkQuery := `INSERT INTO message_files (message_id, file_id, position)
+		SELECT $1, file_id, position FROM message_files WHERE message_id = $2`
+	selectQuery := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
+
+	// Copies are a microsecond apart, so that they keep their order on the
+	// timeline and in cursors.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	forwarded := make([]models.Message, 0, len(ids)*len(chatIds))
+	for _, chatId := range chatIds {
+		for i, id := range ids {
+			copyId := uuid.New()
+			if _, err = tx.Exec(copyQuery, copyId, userId, chatId, now.Add(time.Duration(i)*time.Microsecond), id); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			if _, err = tx.Exec(linkQuery, copyId, id); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			var msg models.Message
+			if err = tx.Get(&msg, selectQuery, copyId); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			forwarded = append(forwarded, msg)
+		}
+	}
+	return forwarded, nil
+}
+
+// GetQuotes returns the current state of the quoted messages. Ids of
+// messages that no longer exist are skipped.
+func (m *MessageRepository) GetQuotes(ids []uuid.UUID) ([]models.Quote, error) {
+	const op = `MessengerRepo.GetQuotes`
+	query := `SELECT id, person_id, chat_id, message, edited_at IS NOT NULL AS edited,
+			deleted_at IS NOT NULL AS deleted
+		FROM messages WHERE id = ANY($1)`
+
+	var quotes []models.Quote
+	err := m.db.Select(&quotes, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return quotes, nil
+}
+
 func (m *MessageRepository) Update(message models.Message) error {
 	const op = `MessengerRepo.Update`
 	query := `UPDATE messages SET message=$1, status=$2 WHERE id = $3`
-	_, err := m.db.Exec(query, message.MessageText, message, message.Id)
+	_, err := m.db.Exec(query, message.MessageText, message.Status, message.Id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func (m *MessageRepository) Delete(id uuid.UUID) error {
+// Edit replaces the text of the message and keeps the previous version in message_edits.
+func (m *MessageRepository) Edit(id uuid.UUID, text string) (models.Message, error) {
+	const op = `MessengerRepo.Edit`
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	editedAt := time.Now().UTC()
+	query := `INSERT INTO message_edits (id, message_id, message, edited_at)
+		SELECT $1, id, message, $2 FROM messages WHERE id = $3 FOR UPDATE`
+	_, err = tx.Exec(query, uuid.New(), editedAt, id)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var msg models.Message
+	query = `UPDATE messages SET message = $1, edited_at = $2 WHERE id = $3 RETURNING ` + messageColumns
+	err = tx.Get(&msg, query, text, editedAt, id)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return msg, nil
+}
+
+func (m *MessageRepository) GetEdits(id uuid.UUID) ([]models.MessageEdit, error) {
+	const op = `MessengerRepo.GetEdits`
+	query := `SELECT id, message_id, message, edited_at FROM message_edits WHERE message_id = $1 ORDER BY edited_at`
+
+	var edits []models.MessageEdit
+	err := m.db.Select(&edits, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return edits, nil
+}
+
+// Delete turns the message into a tombstone for everyone: the text, its
+// edit history and reactions are dropped, the row stays so history keeps its place.
+// Deleting a thread reply takes it off the summary of the thread.
+func (m *MessageRepository) Delete(id uuid.UUID) (domain.DeletedMessage, error) {
 	const op = `MessengerRepo.Delete`
-	query := `UPDATE messages SET status = $1 WHERE id = $2`
-	_, err := m.db.Exec(query, "deleted", id)
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	query := `DELETE FROM message_reactions WHERE message_id = $1`
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var msg models.Message
+	query = `UPDATE messages SET status = $1, message = '', deleted_at = $2 WHERE id = $3 RETURNING ` + messageColumns
+	err = tx.Get(&msg, query, models.MessageStatusDeleted, time.Now().UTC(), id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `DELETE FROM message_edits WHERE message_id = $1`
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `DELETE FROM pinned_messages WHERE message_id = $1`
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `DELETE FROM message_files WHERE message_id = $1`
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+	}
+	msg.Files = nil
+	msg.Previews = nil
+	deleted := domain.DeletedMessage{Message: msg}
+
+	if msg.ThreadRootId != nil {
+		var thread domain.ThreadSummary
+		query = `UPDATE messages SET reply_count = reply_count - 1,
+			last_reply_at = (SELECT max(sending_time) FROM messages WHERE thread_root_id = $1 AND deleted_at IS NULL)
+			WHERE id = $1 RETURNING id, chat_id, reply_count, last_reply_at`
+		err = tx.Get(&thread, query, *msg.ThreadRootId)
+		if err != nil {
+			return domain.DeletedMessage{}, fmt.Errorf("%s: %w", op, err)
+		}
+		deleted.Thread = &thread
+	}
+	return deleted, nil
+}
+
+// AddReaction records the user's reaction and reports whether it is new,
+// along with the reactions of the message after the change.
+func (m *MessageRepository) AddReaction(id, userId uuid.UUID, emoji string) (bool, models.Reactions, error) {
+	const op = `MessengerRepo.AddReaction`
+	query := `INSERT INTO message_reactions (message_id, person_id, emoji, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id, person_id, emoji) DO NOTHING`
+
+	res, err := m.db.Exec(query, id, userId, emoji, time.Now().UTC())
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return m.reactionsAfter(op, res, id)
+}
+
+// RemoveReaction drops the user's reaction and reports whether there was
+// one, along with the reactions of the message after the change.
+func (m *MessageRepository) RemoveReaction(id, userId uuid.UUID, emoji string) (bool, models.Reactions, error) {
+	const op = `MessengerRepo.RemoveReaction`
+	query := `DELETE FROM message_reactions WHERE message_id = $1 AND person_id = $2 AND emoji = $3`
+
+	res, err := m.db.Exec(query, id, userId, emoji)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return m.reactionsAfter(op, res, id)
+}
+
+func (m *MessageRepository) reactionsAfter(op string, res sql.Result, id uuid.UUID) (bool, models.Reactions, error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var reactions models.Reactions
+	query := `SELECT ` + reactionsColumn + ` FROM messages WHERE id = $1`
+	err = m.db.Get(&reactions, query, id)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected > 0, reactions, nil
+}
+
+// Hide removes the message from the user's history only.
+func (m *MessageRepository) Hide(id, userId uuid.UUID) error {
+	const op = `MessengerRepo.Hide`
+	query := `INSERT INTO message_hides (message_id, person_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := m.db.Exec(query, id, userId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
+
+// MarkRead moves the user's read pointer forward to the cursor and marks
+// every message from others it passed over as read. It returns whether the
+// pointer moved and the ids of the messages that became read.
+func (m *MessageRepository) MarkRead(chatId, userId uuid.UUID, c cursor.Cursor) (bool, []uuid.UUID, error) {
+	const op = `MessengerRepo.MarkRead`
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	var from cursor.Cursor
+	query := `SELECT COALESCE(last_read_time, joined_at), COALESCE(last_read_id, $1) FROM chats_persons
+		WHERE chat_id = $2 AND person_id = $3 FOR UPDATE`
+	err = tx.QueryRow(query, uuid.Nil, chatId, userId).Scan(&from.Time, &from.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !from.Before(c) {
+		return false, nil, nil
+	}
+
+	query = `UPDATE chats_persons SET last_read_id = $1, last_read_time = $2 WHERE chat_id = $3 AND person_id = $4`
+	_, err = tx.Exec(query, c.Id, c.Time, chatId, userId)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var ids []uuid.UUID
+	query = `INSERT INTO message_receipts (message_id, person_id, delivered_at, read_at)
+		SELECT id, $1, $2, $2 FROM messages
+		WHERE chat_id = $3 AND thread_root_id IS NULL AND person_id <> $1 AND status <> $4
+		AND (sending_time, id) > ($5, $6) AND (sending_time, id) <= ($7, $8)
+		ON CONFLICT (message_id, person_id) DO UPDATE SET read_at = EXCLUDED.read_at
+		WHERE message_receipts.read_at IS NULL
+		RETURNING message_id`
+	err = tx.Select(&ids, query, userId, time.Now().UTC(), chatId, models.MessageStatusDeleted,
+		from.Time, from.Id, c.Time, c.Id)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, ids, nil
+}
+
+// MarkDelivered records that the messages reached the user. Messages the user
+// sent, deleted messages and messages from chats the user is not a member of
+// are skipped. It returns the ids of the messages delivered for the first
+// time.
+func (m *MessageRepository) MarkDelivered(userId uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error) {
+	const op = `MessengerRepo.MarkDelivered`
+	query := `INSERT INTO message_receipts (message_id, person_id, delivered_at)
+		SELECT m.id, $1, $2 FROM messages m
+		JOIN chats_persons cp ON cp.chat_id = m.chat_id AND cp.person_id = $1
+		WHERE m.id = ANY($3) AND m.person_id <> $1 AND m.status <> $4
+		ON CONFLICT (message_id, person_id) DO NOTHING
+		RETURNING message_id`
+
+	var delivered []uuid.UUID
+	err := m.db.Select(&delivered, query, userId, time.Now().UTC(), pq.Array(ids), models.MessageStatusDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return delivered, nil
+}
+
+// GetStatuses counts, for each message, its recipients (current members
+// other than the sender) and how many of them received and read it.
+func (m *MessageRepository) GetStatuses(ids []uuid.UUID) ([]domain.MessageStatus, error) {
+	const op = `MessengerRepo.GetStatuses`
+	query := `SELECT m.id AS message_id, m.chat_id, m.person_id AS sender_id,
+		(SELECT count(*) FROM chats_persons cp WHERE cp.chat_id = m.chat_id AND cp.person_id <> m.person_id) AS recipients,
+		count(r.delivered_at) AS delivered, count(r.read_at) AS read
+		FROM messages m LEFT JOIN message_receipts r ON r.message_id = m.id
+		WHERE m.id = ANY($1)
+		GROUP BY m.id`
+
+	var statuses []domain.MessageStatus
+	err := m.db.Select(&statuses, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return statuses, nil
+}
+
+func (m *MessageRepository) GetReceipts(id uuid.UUID) ([]models.Receipt, error) {
+	const op = `MessengerRepo.GetReceipts`
+	query := `SELECT person_id, delivered_at, read_at FROM message_receipts WHERE message_id = $1 ORDER BY delivered_at`
+
+	var receipts []models.Receipt
+	err := m.db.Select(&receipts, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return receipts, nil
+}
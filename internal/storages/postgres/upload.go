@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"messenger/internal/domain/models"
+	"time"
+)
+
+const uploadColumns = `id, person_id, name, upload_length, upload_offset, created_at, expires_at`
+
+func (f *FileRepository) AddUpload(upload models.Upload) error {
+	const op = "postgres.FileRepository.AddUpload"
+	query := `INSERT INTO upload_sessions (` + uploadColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := f.db.Exec(query, upload.Id, upload.PersonId, upload.Name, upload.Length, upload.Offset,
+		upload.CreatedAt, upload.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetUpload returns the upload and true if it belongs to the user.
+func (f *FileRepository) GetUpload(id, userId uuid.UUID) (models.Upload, bool, error) {
+	const op = "postgres.FileRepository.GetUpload"
+	query := `SELECT ` + uploadColumns + ` FROM upload_sessions WHERE id = $1 AND person_id = $2`
+
+	var upload models.Upload
+	err := f.db.Get(&upload, query, id, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Upload{}, false, nil
+	}
+	if err != nil {
+		return models.Upload{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return upload, true, nil
+}
+
+// AddChunk records the chunk and moves the offset of the upload past it,
+// unless another chunk was recorded at that offset first. It reports whether
+// the chunk was recorded.
+func (f *FileRepository) AddChunk(chunk models.UploadChunk, expiresAt time.Time) (bool, error) {
+	const op = "postgres.FileRepository.AddChunk"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	query := `UPDATE upload_sessions SET upload_offset = upload_offset + $1, expires_at = $2
+		WHERE id = $3 AND upload_offset = $4`
+	res, err := tx.Exec(query, chunk.Size, expiresAt, chunk.UploadId, chunk.Start)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	query = `INSERT INTO upload_chunks (upload_id, start_offset, size, storage_key) VALUES ($1, $2, $3, $4)`
+	_, err = tx.Exec(query, chunk.UploadId, chunk.Start, chunk.Size, chunk.StorageKey)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// GetChunks returns the chunks of the upload in order.
+func (f *FileRepository) GetChunks(id uuid.UUID) ([]models.UploadChunk, error) {
+	const op = "postgres.FileRepository.GetChunks"
+	query := `SELECT upload_id, start_offset, size, storage_key FROM upload_chunks
+		WHERE upload_id = $1 ORDER BY start_offset`
+
+	var chunks []models.UploadChunk
+	err := f.db.Select(&chunks, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return chunks, nil
+}
+
+// CompleteUpload stores the file assembled from the upload and drops the
+// upload in one transaction. It returns the file as stored, which may share
+// the content of an earlier file.
+func (f *FileRepository) CompleteUpload(id uuid.UUID, file models.File) (models.File, error) {
+	const op = "postgres.FileRepository.CompleteUpload"
+	tx, err := f.db.Beginx()
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		_ = tx.Commit()
+	}()
+
+	stored, err := insertFile(tx, file)
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `DELETE FROM upload_sessions WHERE id = $1`
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return models.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return stored, nil
+}
+
+func (f *FileRepository) DeleteUpload(id uuid.UUID) error {
+	const op = "postgres.FileRepository.DeleteUpload"
+	query := `DELETE FROM upload_sessions WHERE id = $1`
+
+	_, err := f.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetExpiredUploads returns up to limit uploads that expired before now.
+func (f *FileRepository) GetExpiredUploads(now time.Time, limit uint) ([]models.Upload, error) {
+	const op = "postgres.FileRepository.GetExpiredUploads"
+	query := `SELECT ` + uploadColumns + ` FROM upload_sessions WHERE expires_at < $1 ORDER BY expires_at LIMIT $2`
+
+	var uploads []models.Upload
+	err := f.db.Select(&uploads, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return uploads, nil
+}
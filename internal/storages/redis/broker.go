@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	"log/slog"
+	"messenger/internal/domain"
+)
+
+const (
+	chatChannelPrefix = "messenger:chat:"
+	userChannelPrefix = "messenger:user:"
+)
+
+// Broker fans chat events out to every messenger instance through Redis
+// pub/sub. Each chat and each user has its own channel; instances subscribe
+// to all of them with patterns and deliver events to their locally connected users.
+type Broker struct {
+	log *slog.Logger
+	db  *redis.Client
+}
+
+func NewBroker(log *slog.Logger, client *redis.Client) *Broker {
+	return &Broker{
+		log: log,
+		db:  client,
+	}
+}
+
+func eventChannel(event *domain.Event) string {
+	if event.UserId != uuid.Nil {
+		return userChannelPrefix + event.UserId.String()
+	}
+	return chatChannelPrefix + event.ChatId.String()
+}
+
+func (b *Broker) Publish(event *domain.Event) error {
+	const op = "redis.Broker.Publish"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = b.db.Publish(eventChannel(event), data).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (b *Broker) Subscribe() (<-chan *domain.Event, error) {
+	const op = "redis.Broker.Subscribe"
+	log := b.log.With(
+		slog.String("op", op),
+	)
+
+	pubsub := b.db.PSubscribe(chatChannelPrefix+"*", userChannelPrefix+"*")
+	if _, err := pubsub.Receive(); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events := make(chan *domain.Event)
+	go func() {
+		defer close(events)
+		for msg := range pubsub.Channel() {
+			event := new(domain.Event)
+			if err := json.Unmarshal([]byte(msg.Payload), event); err != nil {
+				log.Warn("skipping malformed event", slog.String("channel", msg.Channel),
+					slog.String("err", err.Error()))
+				continue
+			}
+			events <- event
+		}
+	}()
+	return events, nil
+}
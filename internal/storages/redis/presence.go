@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	"messenger/internal/domain"
+	"strconv"
+	"time"
+)
+
+const (
+	presencePrefix = "messenger:presence:"
+	lastSeenPrefix = "messenger:lastseen:"
+)
+
+// PresenceRepository keeps the live sessions of each user in a sorted set
+// scored by the moment each session expires, so sessions of a crashed
+// instance fall out without anyone removing them. The last seen time is kept
+// in a separate key that never expires.
+type PresenceRepository struct {
+	db *redis.Client
+}
+
+func NewPresenceRepository(client *redis.Client) *PresenceRepository {
+	return &PresenceRepository{
+		db: client,
+	}
+}
+
+func presenceKey(userId uuid.UUID) string {
+	return presencePrefix + userId.String()
+}
+
+func lastSeenKey(userId uuid.UUID) string {
+	return lastSeenPrefix + userId.String()
+}
+
+func unixMilli(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func (p *PresenceRepository) SetOnline(userId, sessionId uuid.UUID, ttl time.Duration) (bool, error) {
+	const op = "redis.PresenceRepository.SetOnline"
+
+	now := time.Now()
+	key := presenceKey(userId)
+
+	pipe := p.db.TxPipeline()
+	pipe.ZRemRangeByScore(key, "-inf", unixMilli(now))
+	live := pipe.ZCard(key)
+	pipe.ZAdd(key, redis.Z{Score: float64(now.Add(ttl).UnixMilli()), Member: sessionId.String()})
+	pipe.Expire(key, ttl)
+	pipe.Set(lastSeenKey(userId), now.UnixMilli(), 0)
+	if _, err := pipe.Exec(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return live.Val() == 0, nil
+}
+
+func (p *PresenceRepository) SetOffline(userId, sessionId uuid.UUID) (bool, error) {
+	const op = "redis.PresenceRepository.SetOffline"
+
+	now := time.Now()
+	key := presenceKey(userId)
+
+	pipe := p.db.TxPipeline()
+	pipe.ZRem(key, sessionId.String())
+	pipe.ZRemRangeByScore(key, "-inf", unixMilli(now))
+	live := pipe.ZCard(key)
+	pipe.Set(lastSeenKey(userId), now.UnixMilli(), 0)
+	if _, err := pipe.Exec(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return live.Val() == 0, nil
+}
+
+func (p *PresenceRepository) Get(userIds []uuid.UUID) ([]domain.Presence, error) {
+	const op = "redis.PresenceRepository.Get"
+
+	now := unixMilli(time.Now())
+	live := make([]*redis.IntCmd, len(userIds))
+	lastSeen := make([]*redis.StringCmd, len(userIds))
+
+	pipe := p.db.Pipeline()
+	for i, userId := range userIds {
+		live[i] = pipe.ZCount(presenceKey(userId), "("+now, "+inf")
+		lastSeen[i] = pipe.Get(lastSeenKey(userId))
+	}
+	// A missing last seen key is not an error, so the pipeline error is
+	// ignored in favour of the errors of the individual commands.
+	_, _ = pipe.Exec()
+
+	presences := make([]domain.Presence, len(userIds))
+	for i, userId := range userIds {
+		presences[i].UserId = userId
+
+		count, err := live[i].Result()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		presences[i].Online = count > 0
+
+		millis, err := lastSeen[i].Int64()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		default:
+			seen := time.UnixMilli(millis).UTC()
+			presences[i].LastSeen = &seen
+		}
+	}
+	return presences, nil
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upChatRoles, downChatRoles)
+}
+
+// upChatRoles gives every member a role. Chats created before had no owner:
+// the member who sent the first message in the chat, most likely the one who
+// created it, becomes the owner. In chats where no member has sent a message
+// the member with the lowest id does, which is an arbitrary choice.
+func upChatRoles(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DO $$
+		BEGIN
+		    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'chat_role') THEN
+				CREATE TYPE chat_role as ENUM ('owner', 'admin', 'member');
+			END IF;
+	END $$;
+
+	ALTER TABLE chats_persons
+		ADD COLUMN IF NOT EXISTS role chat_role NOT NULL DEFAULT 'member',
+		ADD COLUMN IF NOT EXISTS joined_at TIMESTAMP NOT NULL DEFAULT now();
+
+	UPDATE chats_persons SET role = 'owner'
+	WHERE (chat_id, person_id) IN (
+		SELECT DISTINCT ON (cp.chat_id) cp.chat_id, cp.person_id FROM chats_persons cp
+		LEFT JOIN LATERAL (SELECT min(sending_time) AS first_sent FROM messages m
+			WHERE m.chat_id = cp.chat_id AND m.person_id = cp.person_id) sent ON true
+		ORDER BY cp.chat_id, sent.first_sent NULLS LAST, cp.person_id
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downChatRoles(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE chats_persons DROP COLUMN IF EXISTS role, DROP COLUMN IF EXISTS joined_at;
+	DROP TYPE IF EXISTS chat_role`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
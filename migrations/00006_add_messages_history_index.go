@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessagesHistoryIndex, downMessagesHistoryIndex)
+}
+
+func upMessagesHistoryIndex(ctx context.Context, tx *sql.Tx) error {
+	query := `CREATE INDEX IF NOT EXISTS messages_chat_id_sending_time_id_idx
+		ON messages (chat_id, sending_time, id)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessagesHistoryIndex(ctx context.Context, tx *sql.Tx) error {
+	query := `DROP INDEX IF EXISTS messages_chat_id_sending_time_id_idx`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
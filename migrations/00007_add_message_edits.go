@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageEdits, downMessageEdits)
+}
+
+func upMessageEdits(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS message_edits (
+		id UUID PRIMARY KEY NOT NULL,
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		message TEXT NOT NULL,
+		edited_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS message_edits_message_id_edited_at_idx ON message_edits (message_id, edited_at)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageEdits(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE IF EXISTS message_edits;
+	ALTER TABLE messages DROP COLUMN IF EXISTS edited_at`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageHides, downMessageHides)
+}
+
+func upMessageHides(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS message_hides (
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		person_id UUID NOT NULL,
+		hidden_at TIMESTAMP NOT NULL DEFAULT now(),
+		PRIMARY KEY (message_id, person_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS message_hides_person_id_idx ON message_hides (person_id)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageHides(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE IF EXISTS message_hides;
+	ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upReadPointers, downReadPointers)
+}
+
+func upReadPointers(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE chats_persons
+		ADD COLUMN IF NOT EXISTS last_read_id UUID,
+		ADD COLUMN IF NOT EXISTS last_read_time TIMESTAMP`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downReadPointers(ctx context.Context, tx *sql.Tx) error {
+	query := `ALTER TABLE chats_persons DROP COLUMN IF EXISTS last_read_id, DROP COLUMN IF EXISTS last_read_time`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
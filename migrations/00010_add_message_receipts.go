@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageReceipts, downMessageReceipts)
+}
+
+func upMessageReceipts(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS message_receipts (
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		person_id UUID NOT NULL,
+		delivered_at TIMESTAMP NOT NULL,
+		read_at TIMESTAMP,
+		PRIMARY KEY (message_id, person_id)
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageReceipts(ctx context.Context, tx *sql.Tx) error {
+	query := `DROP TABLE IF EXISTS message_receipts`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageReplies, downMessageReplies)
+}
+
+func upMessageReplies(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+	CREATE INDEX IF NOT EXISTS messages_reply_to_idx ON messages (reply_to)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageReplies(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP INDEX IF EXISTS messages_reply_to_idx;
+	ALTER TABLE messages DROP COLUMN IF EXISTS reply_to`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
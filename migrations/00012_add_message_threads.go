@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageThreads, downMessageThreads)
+}
+
+func upMessageThreads(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE messages
+		ADD COLUMN IF NOT EXISTS thread_root_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+		ADD COLUMN IF NOT EXISTS reply_count INT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS last_reply_at TIMESTAMP;
+
+	CREATE INDEX IF NOT EXISTS messages_thread_root_id_sending_time_id_idx
+		ON messages (thread_root_id, sending_time, id) WHERE thread_root_id IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS thread_participants (
+		root_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		person_id UUID NOT NULL,
+		joined_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (root_id, person_id)
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageThreads(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE IF EXISTS thread_participants;
+	DROP INDEX IF EXISTS messages_thread_root_id_sending_time_id_idx;
+	ALTER TABLE messages
+		DROP COLUMN IF EXISTS last_reply_at,
+		DROP COLUMN IF EXISTS reply_count,
+		DROP COLUMN IF EXISTS thread_root_id`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
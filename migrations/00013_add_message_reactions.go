@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageReactions, downMessageReactions)
+}
+
+func upMessageReactions(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS message_reactions (
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		person_id UUID NOT NULL,
+		emoji TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (message_id, person_id, emoji)
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageReactions(ctx context.Context, tx *sql.Tx) error {
+	query := `DROP TABLE IF EXISTS message_reactions`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
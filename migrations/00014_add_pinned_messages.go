@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upPinnedMessages, downPinnedMessages)
+}
+
+func upPinnedMessages(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS pinned_messages (
+		chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		pinned_by UUID NOT NULL,
+		pinned_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS pinned_messages_chat_id_pinned_at_idx ON pinned_messages (chat_id, pinned_at)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downPinnedMessages(ctx context.Context, tx *sql.Tx) error {
+	query := `DROP TABLE IF EXISTS pinned_messages`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
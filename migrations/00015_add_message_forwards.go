@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMessageForwards, downMessageForwards)
+}
+
+// upMessageForwards records where forwarded messages come from and moves
+// attachments to a link table, so that a forwarded copy shares the files of
+// the original instead of duplicating them. A file is deleted together with
+// the last link to it, as it was with the message it belonged to before.
+func upMessageForwards(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE messages
+		ADD COLUMN IF NOT EXISTS forwarded_from UUID,
+		ADD COLUMN IF NOT EXISTS forwarded_from_chat UUID,
+		ADD COLUMN IF NOT EXISTS forwarded_time TIMESTAMP;
+
+	CREATE TABLE IF NOT EXISTS message_files (
+		message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+		file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		position INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (message_id, file_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS message_files_file_id_idx ON message_files (file_id);
+
+	INSERT INTO message_files (message_id, file_id) SELECT message_id, id FROM files;
+
+	ALTER TABLE files DROP COLUMN IF EXISTS message_id;
+
+	CREATE OR REPLACE FUNCTION drop_unlinked_file() RETURNS trigger AS $$
+	BEGIN
+		DELETE FROM files WHERE id = OLD.file_id
+			AND NOT EXISTS (SELECT 1 FROM message_files WHERE file_id = OLD.file_id);
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER message_files_drop_unlinked_file AFTER DELETE ON message_files
+		FOR EACH ROW EXECUTE FUNCTION drop_unlinked_file()`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downMessageForwards(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE files ADD COLUMN IF NOT EXISTS message_id UUID REFERENCES messages(id) ON DELETE CASCADE;
+
+	UPDATE files SET message_id = (SELECT message_id FROM message_files mf
+		JOIN messages m ON m.id = mf.message_id
+		WHERE mf.file_id = files.id ORDER BY m.sending_time LIMIT 1);
+
+	DELETE FROM files WHERE message_id IS NULL;
+	ALTER TABLE files ALTER COLUMN message_id SET NOT NULL;
+
+	DROP TABLE IF EXISTS message_files;
+	DROP FUNCTION IF EXISTS drop_unlinked_file();
+	ALTER TABLE messages
+		DROP COLUMN IF EXISTS forwarded_time,
+		DROP COLUMN IF EXISTS forwarded_from_chat,
+		DROP COLUMN IF EXISTS forwarded_from`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
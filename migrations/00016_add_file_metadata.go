@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upFileMetadata, downFileMetadata)
+}
+
+func upFileMetadata(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE files
+		ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS mime_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+		ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS uploaded_by UUID,
+		ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+
+	UPDATE files SET size = octet_length(file), uploaded_by = (SELECT m.person_id FROM message_files mf
+		JOIN messages m ON m.id = mf.message_id
+		WHERE mf.file_id = files.id ORDER BY m.sending_time LIMIT 1);
+
+	DELETE FROM files WHERE uploaded_by IS NULL;
+	ALTER TABLE files ALTER COLUMN uploaded_by SET NOT NULL`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downFileMetadata(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE files
+		DROP COLUMN IF EXISTS created_at,
+		DROP COLUMN IF EXISTS uploaded_by,
+		DROP COLUMN IF EXISTS size,
+		DROP COLUMN IF EXISTS mime_type,
+		DROP COLUMN IF EXISTS name`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
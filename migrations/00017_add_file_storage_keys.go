@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upFileStorageKeys, downFileStorageKeys)
+}
+
+// upFileStorageKeys moves file contents out of the database: a file now
+// names the blob holding its content. Files stored before keep their bytes
+// until they are first opened and moved to the blob store.
+func upFileStorageKeys(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE files
+		ADD COLUMN IF NOT EXISTS checksum TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS storage_key TEXT NOT NULL DEFAULT '',
+		ALTER COLUMN file DROP NOT NULL;
+
+	UPDATE files SET checksum = encode(sha256(file), 'hex') WHERE file IS NOT NULL`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downFileStorageKeys(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DELETE FROM files WHERE file IS NULL;
+	ALTER TABLE files
+		ALTER COLUMN file SET NOT NULL,
+		DROP COLUMN IF EXISTS storage_key,
+		DROP COLUMN IF EXISTS checksum`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
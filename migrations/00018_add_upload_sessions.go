@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upUploadSessions, downUploadSessions)
+}
+
+// upUploadSessions adds resumable uploads. Every chunk received is kept as a
+// blob of its own until the upload is finished into a file.
+func upUploadSessions(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS upload_sessions (
+		id UUID PRIMARY KEY NOT NULL,
+		person_id UUID NOT NULL,
+		name TEXT NOT NULL,
+		upload_length BIGINT NOT NULL,
+		upload_offset BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS upload_sessions_expires_at_idx ON upload_sessions (expires_at);
+
+	CREATE TABLE IF NOT EXISTS upload_chunks (
+		upload_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+		start_offset BIGINT NOT NULL,
+		size BIGINT NOT NULL,
+		storage_key TEXT NOT NULL,
+		PRIMARY KEY (upload_id, start_offset)
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downUploadSessions(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE IF EXISTS upload_chunks;
+	DROP TABLE IF EXISTS upload_sessions`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upImagePreviews, downImagePreviews)
+}
+
+// upImagePreviews records the dimensions and dominant color of image files
+// and the thumbnails made of them. Files uploaded before have no preview.
+func upImagePreviews(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE files
+		ADD COLUMN IF NOT EXISTS width INT,
+		ADD COLUMN IF NOT EXISTS height INT,
+		ADD COLUMN IF NOT EXISTS dominant_color TEXT;
+
+	CREATE TABLE IF NOT EXISTS file_thumbnails (
+		file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		size INT NOT NULL,
+		width INT NOT NULL,
+		height INT NOT NULL,
+		PRIMARY KEY (file_id, size)
+	)`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downImagePreviews(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE IF EXISTS file_thumbnails;
+	ALTER TABLE files
+		DROP COLUMN IF EXISTS dominant_color,
+		DROP COLUMN IF EXISTS height,
+		DROP COLUMN IF EXISTS width`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upSharedBlobs, downSharedBlobs)
+}
+
+// upSharedBlobs stores each distinct file content once: files with the same
+// checksum share a blob, which counts the files that refer to it. Deleting a
+// file releases its blob; blobs nothing refers to are left for the file
+// service to remove from the blob store.
+//
+// Files stored before are grouped by checksum onto the blob of one of them,
+// preferring one with a preview, and the blobs of the others are left
+// unreferenced.
+func upSharedBlobs(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS blobs (
+		storage_key TEXT PRIMARY KEY,
+		checksum TEXT NOT NULL,
+		size BIGINT NOT NULL,
+		ref_count INT NOT NULL
+	);
+
+	INSERT INTO blobs (storage_key, checksum, size, ref_count)
+	SELECT storage_key, checksum, size, 0 FROM files WHERE storage_key <> '';
+
+	UPDATE files SET storage_key = shared.storage_key
+	FROM (SELECT DISTINCT ON (checksum) checksum, storage_key FROM files WHERE storage_key <> ''
+		ORDER BY checksum, width IS NULL, created_at) shared
+	WHERE files.checksum = shared.checksum AND files.storage_key <> '';
+
+	UPDATE blobs SET ref_count = (SELECT count(*) FROM files WHERE files.storage_key = blobs.storage_key);
+
+	-- Once unreferenced, a blob is never shared again, so that it can be
+	-- removed while the same content is uploaded anew.
+	CREATE UNIQUE INDEX IF NOT EXISTS blobs_checksum_idx ON blobs (checksum) WHERE ref_count > 0;
+	CREATE INDEX IF NOT EXISTS blobs_unreferenced_idx ON blobs (storage_key) WHERE ref_count = 0;
+	CREATE INDEX IF NOT EXISTS files_storage_key_idx ON files (storage_key);
+
+	CREATE OR REPLACE FUNCTION release_blob() RETURNS trigger AS $$
+	BEGIN
+		UPDATE blobs SET ref_count = ref_count - 1 WHERE storage_key = OLD.storage_key;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER files_release_blob AFTER DELETE ON files
+		FOR EACH ROW EXECUTE FUNCTION release_blob();
+
+	-- The file is locked first, so that a message linking it at the same
+	-- time is either seen or fails to link it.
+	CREATE OR REPLACE FUNCTION drop_unlinked_file() RETURNS trigger AS $$
+	BEGIN
+		PERFORM 1 FROM files WHERE id = OLD.file_id FOR UPDATE;
+		DELETE FROM files WHERE id = OLD.file_id
+			AND NOT EXISTS (SELECT 1 FROM message_files WHERE file_id = OLD.file_id);
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE TRIGGER message_files_drop_unlinked_file AFTER DELETE ON message_files
+		FOR EACH ROW EXECUTE FUNCTION drop_unlinked_file()`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downSharedBlobs(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	CREATE OR REPLACE FUNCTION drop_unlinked_file() RETURNS trigger AS $$
+	BEGIN
+		DELETE FROM files WHERE id = OLD.file_id
+			AND NOT EXISTS (SELECT 1 FROM message_files WHERE file_id = OLD.file_id);
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS files_release_blob ON files;
+	DROP FUNCTION IF EXISTS release_blob();
+	DROP INDEX IF EXISTS files_storage_key_idx;
+	DROP TABLE IF EXISTS blobs`
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,65 @@
+// Package cursor encodes positions in time ordered lists as opaque strings
+// for keyset pagination.
+package cursor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+const size = 8 + 16
+
+// Cursor points at an item ordered by (Time, Id). The id breaks ties
+// between items created at the same instant.
+type Cursor struct {
+	Time time.Time
+	Id   uuid.UUID
+}
+
+func New(t time.Time, id uuid.UUID) Cursor {
+	return Cursor{
+		Time: t,
+		Id:   id,
+	}
+}
+
+// Before reports whether c comes before o in (Time, Id) order. Ids compare
+// byte by byte, the same way Postgres orders uuid values.
+func (c Cursor) Before(o Cursor) bool {
+	if !c.Time.Equal(o.Time) {
+		return c.Time.Before(o.Time)
+	}
+	return bytes.Compare(c.Id[:], o.Id[:]) < 0
+}
+
+// Encode returns the cursor as a URL safe string. Time is kept with
+// microsecond precision, which is what Postgres stores.
+func (c Cursor) Encode() string {
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint64(buf, uint64(c.Time.UnixMicro()))
+	copy(buf[8:], c.Id[:])
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func Decode(s string) (Cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(buf) != size {
+		return Cursor{}, ErrInvalid
+	}
+
+	id, err := uuid.FromBytes(buf[8:])
+	if err != nil {
+		return Cursor{}, ErrInvalid
+	}
+
+	return Cursor{
+		Time: time.UnixMicro(int64(binary.BigEndian.Uint64(buf))).UTC(),
+		Id:   id,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package cursor
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	c := New(time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC), uuid.New())
+
+	decoded, err := Decode(c.Encode())
+	require.NoError(t, err)
+	require.True(t, c.Time.Equal(decoded.Time))
+	require.Equal(t, c.Id, decoded.Id)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "Пустая строка",
+			input: "",
+		},
+		{
+			name:  "Не base64",
+			input: "not a cursor!",
+		},
+		{
+			name:  "Неверная длина",
+			input: "AAAA",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.input)
+			require.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestCursor_Before(t *testing.T) {
+	now := time.Now().UTC()
+	low := uuid.UUID{0x01}
+	high := uuid.UUID{0x02}
+
+	cases := []struct {
+		name     string
+		a, b     Cursor
+		expected bool
+	}{
+		{
+			name:     "Более раннее время",
+			a:        New(now, high),
+			b:        New(now.Add(time.Second), low),
+			expected: true,
+		},
+		{
+			name:     "Одинаковое время, меньший id",
+			a:        New(now, low),
+			b:        New(now, high),
+			expected: true,
+		},
+		{
+			name:     "Один и тот же курсор",
+			a:        New(now, low),
+			b:        New(now, low),
+			expected: false,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.a.Before(tt.b))
+		})
+	}
+}
@@ -11,7 +11,10 @@ func MessageAddToMessage(message domain.MessageAdd) models.Message {
 		Chat: models.Chat{
 			Id: message.ChatId,
 		},
-		MessageText: message.Message,
+		MessageText:  message.Message,
+		ReplyTo:      message.ReplyTo,
+		ThreadRootId: message.ThreadRootId,
+		Files:        message.Files,
 	}
 }
 